@@ -0,0 +1,283 @@
+// Package alert evaluates the results of the checks before they reach the sinks. It keeps the state of every site, decides which changes are worth telling someone about, and marks the results accordingly.
+package alert
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/maintenance"
+	"github.com/pbabbicola/go-monitor/monitor"
+	"github.com/pbabbicola/go-monitor/slo"
+)
+
+// Evaluator keeps the state of every site and evaluates every result against it. Notifications are logged, so they end up wherever the logs do.
+type Evaluator struct {
+	mut          *sync.Mutex
+	maintenance  *maintenance.Windows
+	dependencies map[string][]string // by site, as [config.SiteElement.String] shows it, which is the URL of its messages
+	flapPolicies map[string]config.FlapPolicy
+	states       map[string]*state
+}
+
+// state is what the evaluator knows about a site, from its previous results.
+type state struct {
+	down         bool
+	suppressedBy string
+	alerted      bool // whether we told someone that the site is down, so we also tell them when it recovers
+	anomalous    bool // whether we told someone that the site is slower than usual, so we also tell them when it's not
+
+	history       []bool // whether the site was down in its last checks, oldest first, as many as the window of its flap policy
+	flapping      bool
+	flappingSince time.Time
+	flapChanges   int // state changes since it started flapping, for the summary when it stops
+}
+
+// New creates a new Evaluator. It knows no dependencies until [Evaluator.SetSites] is called.
+// Results in one of the maintenance windows are flagged and not notified. The windows can be nil if there are none.
+func New(windows *maintenance.Windows) *Evaluator {
+	return &Evaluator{
+		mut:          &sync.Mutex{},
+		maintenance:  windows,
+		dependencies: map[string][]string{},
+		flapPolicies: map[string]config.FlapPolicy{},
+		states:       map[string]*state{},
+	}
+}
+
+// SetSites sets the site list, eg. after it's reloaded. The state of the sites that are still in the list is kept.
+// The dependencies must be resolved already, as [config.Normalize] does.
+func (e *Evaluator) SetSites(sites []config.SiteElement) {
+	keys := make(map[string]string, len(sites))
+	for _, site := range sites {
+		keys[site.URL] = site.String()
+	}
+
+	dependencies := make(map[string][]string, len(sites))
+	flapPolicies := make(map[string]config.FlapPolicy, len(sites))
+	states := make(map[string]*state, len(sites))
+
+	for _, site := range sites {
+		key := site.String()
+		flapPolicies[key] = site.Flapping.WithDefaults()
+
+		for _, dependency := range site.DependsOn {
+			if dependencyKey, ok := keys[dependency]; ok {
+				dependencies[key] = append(dependencies[key], dependencyKey)
+			}
+		}
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	for _, key := range keys {
+		if siteState, ok := e.states[key]; ok {
+			states[key] = siteState
+		}
+	}
+
+	e.dependencies = dependencies
+	e.flapPolicies = flapPolicies
+	e.states = states
+}
+
+// upstreamDown returns the site that the site depends on and that is down, if there is any. If that one is suppressed too, it returns the site that suppressed it, so it's always the root cause.
+func (e *Evaluator) upstreamDown(key string) string {
+	for _, dependency := range e.dependencies[key] {
+		dependencyState, ok := e.states[dependency]
+		if !ok || !dependencyState.down {
+			continue
+		}
+
+		if dependencyState.suppressedBy != "" {
+			return dependencyState.suppressedBy
+		}
+
+		return dependency
+	}
+
+	return ""
+}
+
+// Evaluate updates the state of the site with a result, notifies about what changed, and returns the result with what the evaluation found.
+//
+// A result that the anomaly detector flagged is notified once, until the site is back to its usual speed, and marked with [monitor.Message.Notified] like a failure,
+// so the incident it opens says so. Failures don't change that, as they are notified on their own.
+//
+// A site that keeps going up and down is flapping, see [config.FlapPolicy]. Only when it starts and when it stops flapping is notified, and not every change in between.
+//
+// A failure of a site while a site it depends on is down is marked with [monitor.Message.SuppressedBy] and is not notified, as it's most likely the same problem.
+// Only the latest result of the upstream site is used, so a failure that is checked before the upstream one is notified anyway.
+func (e *Evaluator) Evaluate(ctx context.Context, message monitor.Message) monitor.Message {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	siteState, ok := e.states[message.URL]
+	if !ok {
+		siteState = &state{}
+		e.states[message.URL] = siteState
+	}
+
+	down := message.ErrorClass != monitor.ErrorClassNone
+	if down {
+		message.SuppressedBy = e.upstreamDown(message.URL)
+	}
+
+	if e.maintenance != nil {
+		_, message.InMaintenance = e.maintenance.Active(message.URL, message.Group, message.Labels, message.Timestamp)
+	}
+
+	wasFlapping := siteState.flapping
+	if !message.InMaintenance { // results in maintenance windows are expected to change, so they don't count
+		e.flap(ctx, siteState, message, down)
+	}
+
+	message.Flapping = siteState.flapping
+
+	if wasFlapping && !siteState.flapping && down { // nobody was told it's down while it was flapping, so it's notified like any other site that goes down
+		siteState.alerted = false
+	}
+
+	switch {
+	case message.InMaintenance: // the state is still updated, so a site that is down after the maintenance is notified then
+	case siteState.flapping, wasFlapping && !down: // the summary is notified instead, and a site that stops flapping while up is not notified as recovered
+		siteState.alerted = down && message.SuppressedBy == ""
+	case down && message.SuppressedBy != "":
+		if siteState.suppressedBy == "" && !siteState.alerted {
+			notify(ctx, slog.LevelInfo, "Site is down, but it's suppressed by an upstream site that is down too.", message)
+		}
+	case down && !siteState.alerted:
+		notify(ctx, slog.LevelWarn, "Site is down.", message)
+
+		message.Notified = true
+		siteState.alerted = true
+	case !down && siteState.alerted:
+		notify(ctx, slog.LevelInfo, "Site recovered.", message)
+
+		siteState.alerted = false
+	}
+
+	switch {
+	case message.InMaintenance:
+	case message.Anomalous && !siteState.anomalous:
+		notify(ctx, slog.LevelWarn, "Site is slower than usual.", message,
+			slog.Duration("duration", message.Duration), slog.Duration("baseline", message.Baseline))
+
+		message.Notified = true
+		siteState.anomalous = true
+	case !down && !message.Anomalous && siteState.anomalous:
+		notify(ctx, slog.LevelInfo, "Site is back to its usual speed.", message, slog.Duration("duration", message.Duration))
+
+		siteState.anomalous = false
+	}
+
+	if message.ContentChanged && !message.InMaintenance { // a deploy in a maintenance window is expected to change the page
+		notify(ctx, slog.LevelWarn, "Content changed.", message, slog.String("hash", message.BodyHash), slog.String("diff", message.ContentDiff))
+	}
+
+	siteState.down = down
+	siteState.suppressedBy = message.SuppressedBy
+
+	return message
+}
+
+// flap adds a result to the history of the site, and starts or stops flapping if the state changed often enough in its window.
+func (e *Evaluator) flap(ctx context.Context, siteState *state, message monitor.Message, down bool) {
+	policy, ok := e.flapPolicies[message.URL]
+	if !ok {
+		policy = config.FlapPolicy{}.WithDefaults()
+	}
+
+	if policy.Disabled {
+		siteState.history = nil
+		siteState.flapping = false
+
+		return
+	}
+
+	siteState.history = append(siteState.history, down)
+	if len(siteState.history) > policy.WindowChecks {
+		siteState.history = siteState.history[len(siteState.history)-policy.WindowChecks:]
+	}
+
+	history := siteState.history
+	changes := 0
+
+	for i := 1; i < len(history); i++ {
+		if history[i] != history[i-1] {
+			changes++
+		}
+	}
+
+	if siteState.flapping && len(history) > 1 && history[len(history)-1] != history[len(history)-2] {
+		siteState.flapChanges++
+	}
+
+	percent := 100 * float64(changes) / float64(policy.WindowChecks-1) //nolint:mnd // It's a percentage.
+
+	switch {
+	case !siteState.flapping && len(history) == policy.WindowChecks && percent >= policy.StartPercent:
+		siteState.flapping = true
+		siteState.flappingSince = message.Timestamp
+		siteState.flapChanges = changes
+
+		notify(ctx, slog.LevelWarn, "Site is flapping.", message,
+			slog.Int("state_changes", changes), slog.Int("window_checks", policy.WindowChecks))
+	case siteState.flapping && percent <= policy.StopPercent:
+		siteState.flapping = false
+
+		notify(ctx, slog.LevelInfo, "Site stopped flapping.", message,
+			slog.Duration("flapped_for", message.Timestamp.Sub(siteState.flappingSince)), slog.Int("state_changes", siteState.flapChanges))
+	}
+}
+
+// notify tells someone about a site. For now, it's a log line with everything needed to route it, like the group and the owner.
+func notify(ctx context.Context, level slog.Level, text string, message monitor.Message, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("url", message.URL),
+		slog.String("check_type", string(message.CheckType)),
+		slog.String("group", message.Group),
+		slog.String("owner", message.Owner),
+		slog.String("error_class", string(message.ErrorClass)),
+		slog.String("suppressed_by", message.SuppressedBy),
+	}, attrs...)
+
+	slog.LogAttrs(ctx, level, text, attrs...)
+}
+
+// NotifySLO tells someone that the error budget of an SLO is burning too fast, or that it stopped. It's the [slo.Notifier] of the calculator.
+func NotifySLO(ctx context.Context, status slo.Status) {
+	level, text := slog.LevelInfo, "SLO error budget is not burning too fast anymore."
+	if status.Alert != slo.AlertNone {
+		level, text = slog.LevelWarn, "SLO error budget is burning too fast."
+	}
+
+	slog.LogAttrs(ctx, level, text,
+		slog.String("slo", status.Name),
+		slog.String("url", status.URL),
+		slog.String("group", status.Group),
+		slog.String("owner", status.Owner),
+		slog.String("alert", string(status.Alert)),
+		slog.Float64("error_budget_remaining", status.ErrorBudgetRemaining),
+		slog.Any("burn_rates", status.BurnRates),
+	)
+}
+
+// Consume evaluates every message of the message queue and sends it to the output.
+func (e *Evaluator) Consume(ctx context.Context, messageQueue chan monitor.Message, output chan monitor.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messageQueue:
+			select {
+			case <-ctx.Done():
+				return
+			case output <- e.Evaluate(ctx, msg):
+			}
+		}
+	}
+}
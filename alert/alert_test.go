@@ -0,0 +1,304 @@
+package alert_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/pbabbicola/go-monitor/alert"
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/maintenance"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+const (
+	cdn     = "https://cdn.example.org"
+	gateway = "https://gateway.example.org"
+	shop    = "https://shop.example.org"
+)
+
+var sites = []config.SiteElement{
+	{URL: cdn},
+	{URL: gateway, DependsOn: []string{cdn}},
+	{URL: shop, DependsOn: []string{gateway}},
+}
+
+func up(url string) monitor.Message {
+	return monitor.Message{URL: url}
+}
+
+func down(url string) monitor.Message {
+	return monitor.Message{URL: url, ErrorClass: monitor.ErrorClassTimeout}
+}
+
+func TestEvaluator_Evaluate_DependsOn(t *testing.T) {
+	tests := []struct {
+		name             string
+		messages         []monitor.Message
+		wantSuppressedBy []string // of every message
+	}{
+		{
+			name:             "upstream is up",
+			messages:         []monitor.Message{up(gateway), down(shop)},
+			wantSuppressedBy: []string{"", ""},
+		},
+		{
+			name:             "upstream is down",
+			messages:         []monitor.Message{down(gateway), down(shop), up(shop)},
+			wantSuppressedBy: []string{"", gateway, ""},
+		},
+		{
+			name:             "root cause",
+			messages:         []monitor.Message{down(cdn), down(gateway), down(shop)},
+			wantSuppressedBy: []string{"", cdn, cdn},
+		},
+		{
+			name:             "upstream recovers",
+			messages:         []monitor.Message{down(gateway), down(shop), up(gateway), down(shop)},
+			wantSuppressedBy: []string{"", gateway, "", ""},
+		},
+		{
+			name:             "upstream is checked later",
+			messages:         []monitor.Message{down(shop), down(gateway)},
+			wantSuppressedBy: []string{"", ""},
+		},
+		{
+			name:             "unknown site",
+			messages:         []monitor.Message{down(cdn), down("https://elsewhere.example.org")},
+			wantSuppressedBy: []string{"", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := alert.New(nil)
+			evaluator.SetSites(sites)
+
+			suppressedBy := make([]string, 0, len(tt.messages))
+			for _, message := range tt.messages {
+				suppressedBy = append(suppressedBy, evaluator.Evaluate(context.Background(), message).SuppressedBy)
+			}
+
+			assert.Equal(t, tt.wantSuppressedBy, suppressedBy)
+		})
+	}
+}
+
+// notifications returns the messages of everything that was logged while f ran.
+func notifications(t *testing.T, f func()) []string {
+	t.Helper()
+
+	var logs bytes.Buffer
+
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	f()
+
+	got := []string{}
+
+	decoder := json.NewDecoder(&logs)
+	for decoder.More() {
+		var line struct {
+			Msg string `json:"msg"`
+		}
+
+		require.NoError(t, decoder.Decode(&line))
+
+		got = append(got, line.Msg)
+	}
+
+	return got
+}
+
+func TestEvaluator_Evaluate_ContentChanged(t *testing.T) {
+	now := time.Now()
+
+	windows := maintenance.New()
+	windows.SetSites([]config.SiteElement{
+		{URL: shop, Maintenance: []config.MaintenanceWindow{{Start: now, End: now.Add(time.Hour)}}},
+	})
+
+	evaluator := alert.New(windows)
+
+	changed := func(timestamp time.Time) monitor.Message {
+		return monitor.Message{URL: shop, Timestamp: timestamp, ContentChanged: true, ContentDiff: "- Our shop\n+ Hacked!"}
+	}
+
+	got := notifications(t, func() {
+		evaluator.Evaluate(context.Background(), up(shop))
+		evaluator.Evaluate(context.Background(), changed(now.Add(-time.Minute)))
+		evaluator.Evaluate(context.Background(), changed(now.Add(time.Minute)))
+	})
+
+	assert.Equal(t, []string{"Content changed."}, got, "changes in a maintenance window are not notified")
+}
+
+func TestEvaluator_Evaluate_Anomalous(t *testing.T) {
+	evaluator := alert.New(nil)
+
+	slow := up(shop)
+	slow.Anomalous = true
+	slow.Duration = 2 * time.Second
+	slow.Baseline = 200 * time.Millisecond
+
+	var gotNotified []bool
+
+	got := notifications(t, func() {
+		for _, message := range []monitor.Message{up(shop), slow, slow, up(shop), slow} {
+			gotNotified = append(gotNotified, evaluator.Evaluate(context.Background(), message).Notified)
+		}
+	})
+
+	assert.Equal(t, []string{"Site is slower than usual.", "Site is back to its usual speed.", "Site is slower than usual."}, got)
+	assert.Equal(t, []bool{false, true, false, false, true}, gotNotified, "only the first anomalous result is notified")
+}
+
+func TestEvaluator_Evaluate_Maintenance(t *testing.T) {
+	now := time.Now()
+
+	windows := maintenance.New()
+	windows.SetSites([]config.SiteElement{
+		{URL: shop, Maintenance: []config.MaintenanceWindow{{Start: now, End: now.Add(time.Hour)}}},
+	})
+
+	evaluator := alert.New(windows)
+
+	before := down(shop)
+	before.Timestamp = now.Add(-time.Minute)
+	assert.False(t, evaluator.Evaluate(context.Background(), before).InMaintenance)
+
+	during := down(shop)
+	during.Timestamp = now.Add(time.Minute)
+	assert.True(t, evaluator.Evaluate(context.Background(), during).InMaintenance)
+
+	other := down(cdn)
+	other.Timestamp = now.Add(time.Minute)
+	assert.False(t, evaluator.Evaluate(context.Background(), other).InMaintenance)
+}
+
+func TestEvaluator_Evaluate_Flapping(t *testing.T) {
+	flapping := config.FlapPolicy{WindowChecks: 4, StartPercent: 60, StopPercent: 30}
+
+	tests := []struct {
+		name             string
+		sites            []config.SiteElement
+		messages         []monitor.Message
+		wantFlapping     []bool // of every message
+		wantNotified     []bool // of every message, if it's set
+		wantNotification []string
+	}{
+		{
+			name:             "flapping stops while down",
+			sites:            []config.SiteElement{{URL: shop, Flapping: flapping}},
+			messages:         []monitor.Message{up(shop), down(shop), up(shop), down(shop), down(shop), down(shop), down(shop), up(shop)},
+			wantFlapping:     []bool{false, false, false, true, true, true, false, false},
+			wantNotified:     []bool{false, true, false, false, false, false, true, false},
+			wantNotification: []string{"Site is down.", "Site recovered.", "Site is flapping.", "Site stopped flapping.", "Site is down.", "Site recovered."},
+		},
+		{
+			name:             "flapping stops while up",
+			sites:            []config.SiteElement{{URL: shop, Flapping: flapping}},
+			messages:         []monitor.Message{up(shop), down(shop), up(shop), down(shop), up(shop), up(shop), up(shop), up(shop), down(shop)},
+			wantFlapping:     []bool{false, false, false, true, true, true, true, false, false},
+			wantNotification: []string{"Site is down.", "Site recovered.", "Site is flapping.", "Site stopped flapping.", "Site is down."},
+		},
+		{
+			name:             "changes are not notified while flapping",
+			sites:            []config.SiteElement{{URL: shop, Flapping: flapping}},
+			messages:         []monitor.Message{up(shop), down(shop), up(shop), down(shop), up(shop), down(shop), up(shop)},
+			wantFlapping:     []bool{false, false, false, true, true, true, true},
+			wantNotification: []string{"Site is down.", "Site recovered.", "Site is flapping."},
+		},
+		{
+			name:             "not enough checks",
+			sites:            []config.SiteElement{{URL: shop, Flapping: flapping}},
+			messages:         []monitor.Message{down(shop), up(shop), down(shop)},
+			wantFlapping:     []bool{false, false, false},
+			wantNotification: []string{"Site is down.", "Site recovered.", "Site is down."},
+		},
+		{
+			name:             "disabled",
+			sites:            []config.SiteElement{{URL: shop, Flapping: config.FlapPolicy{Disabled: true, WindowChecks: 4}}},
+			messages:         []monitor.Message{up(shop), down(shop), up(shop), down(shop)},
+			wantFlapping:     []bool{false, false, false, false},
+			wantNotification: []string{"Site is down.", "Site recovered.", "Site is down."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := alert.New(nil)
+			evaluator.SetSites(tt.sites)
+
+			gotFlapping := make([]bool, 0, len(tt.messages))
+			gotNotified := make([]bool, 0, len(tt.messages))
+
+			gotNotification := notifications(t, func() {
+				for _, message := range tt.messages {
+					evaluated := evaluator.Evaluate(context.Background(), message)
+
+					gotFlapping = append(gotFlapping, evaluated.Flapping)
+					gotNotified = append(gotNotified, evaluated.Notified)
+				}
+			})
+
+			assert.Equal(t, tt.wantFlapping, gotFlapping)
+
+			if tt.wantNotified != nil {
+				assert.Equal(t, tt.wantNotified, gotNotified)
+			}
+			assert.Equal(t, tt.wantNotification, gotNotification)
+		})
+	}
+}
+
+func TestEvaluator_SetSites(t *testing.T) {
+	evaluator := alert.New(nil)
+	evaluator.SetSites(sites)
+
+	evaluator.Evaluate(context.Background(), down(gateway))
+
+	// the gateway is still down after the reload, but the shop doesn't depend on it anymore
+	evaluator.SetSites([]config.SiteElement{{URL: gateway}, {URL: shop}})
+	assert.Empty(t, evaluator.Evaluate(context.Background(), down(shop)).SuppressedBy)
+
+	evaluator.SetSites(sites)
+	assert.Equal(t, gateway, evaluator.Evaluate(context.Background(), down(shop)).SuppressedBy)
+}
+
+func TestEvaluator_Consume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evaluator := alert.New(nil)
+	evaluator.SetSites(sites)
+
+	messageQueue := make(chan monitor.Message)
+	output := make(chan monitor.Message)
+
+	go evaluator.Consume(ctx, messageQueue, output)
+
+	messageQueue <- down(gateway)
+
+	evaluated := <-output
+	assert.Empty(t, evaluated.SuppressedBy)
+	assert.True(t, evaluated.Notified)
+
+	messageQueue <- down(shop)
+
+	evaluated = <-output
+	assert.Equal(t, gateway, evaluated.SuppressedBy)
+	assert.False(t, evaluated.Notified)
+}
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
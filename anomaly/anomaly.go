@@ -0,0 +1,233 @@
+// Package anomaly learns how fast every site usually is at every hour of the week, from its results in Postgres, and flags the results that are much slower than that.
+// A site that slowly goes from 200ms to 2s never fails, but it's not fine either.
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+const (
+	// madScale makes the median absolute deviation comparable to a standard deviation, if the durations were normally distributed. They aren't, but it makes the sensitivity easier to reason about.
+	madScale = 1.4826
+	// minDeviation is the smallest deviation, as a part of the median, so a site that always takes about the same isn't anomalous on every bit of jitter.
+	minDeviation = 0.1
+)
+
+// baselineQuery learns the baseline of every site ($1) from its successful checks since a time ($2): the median duration at every hour of the week, in UTC, and the median absolute deviation from it.
+// Hours of the week start on Monday at 0. Checks in maintenance windows are left out, as they are most likely not usual.
+const baselineQuery = `with checks as (
+	select url, (extract(isodow from ts at time zone 'UTC')::int - 1) * 24 + extract(hour from ts at time zone 'UTC')::int as hour_of_week, duration_milliseconds
+	from logs
+	where url = any($1) and ts >= $2 and not in_maintenance and coalesce(error_class, '') = '' and coalesce(error, '') = ''
+),
+medians as (
+	select url, hour_of_week, percentile_cont(0.5) within group (order by duration_milliseconds) as median, count(*) as samples
+	from checks
+	group by url, hour_of_week
+)
+select medians.url, medians.hour_of_week, medians.median, percentile_cont(0.5) within group (order by abs(checks.duration_milliseconds - medians.median)), medians.samples
+from medians
+join checks on checks.url = medians.url and checks.hour_of_week = medians.hour_of_week
+group by medians.url, medians.hour_of_week, medians.median, medians.samples`
+
+// baseline is how fast a site usually is at an hour of the week, in milliseconds.
+type baseline struct {
+	median  float64
+	mad     float64
+	samples int64
+}
+
+// Detector keeps the baselines of the sites that want them, and flags the results that are much slower.
+type Detector struct {
+	db *sql.DB
+
+	mut       *sync.Mutex
+	policies  map[string]config.AnomalyPolicy // by site, as [config.SiteElement.String] shows it, which is the URL of its messages
+	baselines map[string]map[int]baseline     // by site, and then by hour of the week
+}
+
+// New creates a new Detector, without any site until [Detector.SetSites] is called.
+func New(db *sql.DB) *Detector {
+	return &Detector{
+		db:        db,
+		mut:       &sync.Mutex{},
+		policies:  map[string]config.AnomalyPolicy{},
+		baselines: map[string]map[int]baseline{},
+	}
+}
+
+// SetSites sets the sites that want their anomalies detected, eg. after the site list is reloaded. The baselines of the sites that are still there are kept until the next update.
+func (d *Detector) SetSites(sites []config.SiteElement) {
+	policies := map[string]config.AnomalyPolicy{}
+
+	for _, site := range sites {
+		if !site.Anomaly.Enabled {
+			continue
+		}
+
+		policy := site.Anomaly
+
+		if policy.Sensitivity <= 0 {
+			policy.Sensitivity = config.DefaultAnomalySensitivity
+		}
+
+		if policy.MinSamples <= 0 {
+			policy.MinSamples = config.DefaultAnomalyMinSamples
+		}
+
+		if policy.BaselineDays <= 0 {
+			policy.BaselineDays = config.DefaultAnomalyBaselineDays
+		}
+
+		policies[site.String()] = policy
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.policies = policies
+}
+
+// Update learns the baselines again. Sites are queried together if they learn from the same number of days, which is most likely all of them.
+func (d *Detector) Update(ctx context.Context) error {
+	d.mut.Lock()
+	policies := maps.Clone(d.policies)
+	d.mut.Unlock()
+
+	urlsByDays := map[int][]string{}
+	for url, policy := range policies {
+		urlsByDays[policy.BaselineDays] = append(urlsByDays[policy.BaselineDays], url)
+	}
+
+	now := time.Now()
+	baselines := map[string]map[int]baseline{}
+
+	for _, days := range slices.Sorted(maps.Keys(urlsByDays)) {
+		rows, err := d.db.QueryContext(ctx, baselineQuery, pq.Array(urlsByDays[days]), now.AddDate(0, 0, -days))
+		if err != nil {
+			return fmt.Errorf("querying baselines: %w", err)
+		}
+
+		err = scanBaselines(rows, baselines)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.baselines = baselines
+
+	return nil
+}
+
+// scanBaselines reads the rows of [baselineQuery] into the baselines, and closes them.
+func scanBaselines(rows *sql.Rows, baselines map[string]map[int]baseline) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			url        string
+			hourOfWeek int
+			learned    baseline
+		)
+
+		err := rows.Scan(&url, &hourOfWeek, &learned.median, &learned.mad, &learned.samples)
+		if err != nil {
+			return fmt.Errorf("scanning baseline: %w", err)
+		}
+
+		if baselines[url] == nil {
+			baselines[url] = map[int]baseline{}
+		}
+
+		baselines[url][hourOfWeek] = learned
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return fmt.Errorf("reading baselines: %w", err)
+	}
+
+	return nil
+}
+
+// Run learns the baselines right away and then every interval, until the context is done. Errors are logged, and the previous baselines are kept.
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := d.Update(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed learning latency baselines.", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hourOfWeek returns the hour of the week of a time in UTC, starting on Monday at 0, like [baselineQuery] does.
+func hourOfWeek(at time.Time) int {
+	at = at.UTC()
+	day := (int(at.Weekday()) + 6) % 7 //nolint:mnd // Weekday starts on Sunday, and we start on Monday.
+
+	return day*24 + at.Hour() //nolint:mnd // Hours in a day.
+}
+
+// Evaluate sets the baseline of a result, and flags it if it's much slower. Failed checks are not flagged, as they are alerted on anyway, and neither are sites without enough samples at that hour.
+func (d *Detector) Evaluate(message monitor.Message) monitor.Message {
+	if message.ErrorClass != monitor.ErrorClassNone {
+		return message
+	}
+
+	d.mut.Lock()
+	policy, enabled := d.policies[message.URL]
+	learned, found := d.baselines[message.URL][hourOfWeek(message.Timestamp)]
+	d.mut.Unlock()
+
+	if !enabled || !found || learned.samples < int64(policy.MinSamples) {
+		return message
+	}
+
+	duration := float64(message.Duration) / float64(time.Millisecond)
+	deviation := max(madScale*learned.mad, minDeviation*learned.median, 1)
+
+	message.Baseline = time.Duration(learned.median * float64(time.Millisecond))
+	message.Anomalous = (duration-learned.median)/deviation > policy.Sensitivity
+
+	return message
+}
+
+// Consume evaluates every message of the message queue and sends it to the output.
+func (d *Detector) Consume(ctx context.Context, messageQueue chan monitor.Message, output chan monitor.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messageQueue:
+			select {
+			case <-ctx.Done():
+				return
+			case output <- d.Evaluate(msg):
+			}
+		}
+	}
+}
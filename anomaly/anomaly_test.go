@@ -0,0 +1,166 @@
+package anomaly_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/anomaly"
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+const (
+	shop = "https://shop.example.org"
+	blog = "https://blog.example.org"
+)
+
+var (
+	baselineQuery = regexp.QuoteMeta("percentile_cont(0.5) within group (order by abs(")
+	columns       = []string{"url", "hour_of_week", "median", "mad", "samples"}
+
+	monday  = time.Date(2026, time.October, 19, 10, 30, 0, 0, time.UTC) // hour 10 of the week
+	sunday  = time.Date(2026, time.October, 18, 23, 0, 0, 0, time.UTC)  // hour 167 of the week
+	sites   = []config.SiteElement{{URL: shop, Anomaly: config.AnomalyPolicy{Enabled: true}}, {URL: blog}}
+	learned = func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(baselineQuery).WithArgs(`{"https://shop.example.org"}`, sqlmock.AnyArg()).WillReturnRows(
+			sqlmock.NewRows(columns).
+				AddRow(shop, 10, 200.0, 20.0, 100).
+				AddRow(shop, 167, 200.0, 0.0, 100).
+				AddRow(shop, 11, 200.0, 20.0, 5),
+		)
+	}
+)
+
+func TestDetector_Evaluate(t *testing.T) {
+	tests := []struct {
+		name           string
+		sites          []config.SiteElement
+		dbExpectations func(mock sqlmock.Sqlmock)
+		message        monitor.Message
+		wantAnomalous  bool
+		wantBaseline   time.Duration
+		wantErr        bool
+	}{
+		{
+			name:           "usual",
+			sites:          sites,
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: monday, Duration: 300 * time.Millisecond},
+			wantBaseline:   200 * time.Millisecond,
+		},
+		{
+			name:           "much slower",
+			sites:          sites,
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: monday, Duration: 400 * time.Millisecond}, // (400-200)/(1.4826*20) is about 6.7
+			wantAnomalous:  true,
+			wantBaseline:   200 * time.Millisecond,
+		},
+		{
+			name:           "jitter of a site that always takes the same",
+			sites:          sites,
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: sunday, Duration: 250 * time.Millisecond}, // the deviation is 10% of the median at least
+			wantBaseline:   200 * time.Millisecond,
+		},
+		{
+			name: "more sensitive",
+			sites: []config.SiteElement{
+				{URL: shop, Anomaly: config.AnomalyPolicy{Enabled: true, Sensitivity: 2}},
+			},
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: monday, Duration: 300 * time.Millisecond},
+			wantAnomalous:  true,
+			wantBaseline:   200 * time.Millisecond,
+		},
+		{
+			name:           "not enough samples",
+			sites:          sites,
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: monday.Add(time.Hour), Duration: 2 * time.Second},
+		},
+		{
+			name:           "failed check",
+			sites:          sites,
+			dbExpectations: learned,
+			message:        monitor.Message{URL: shop, Timestamp: monday, Duration: 10 * time.Second, ErrorClass: monitor.ErrorClassTimeout},
+		},
+		{
+			name:           "disabled",
+			sites:          sites[1:],
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			message:        monitor.Message{URL: blog, Timestamp: monday, Duration: 10 * time.Second},
+		},
+		{
+			name:  "failed query",
+			sites: sites,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(baselineQuery).WillReturnError(assert.AnError)
+			},
+			message: monitor.Message{URL: shop, Timestamp: monday, Duration: 10 * time.Second},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			detector := anomaly.New(db)
+			detector.SetSites(tt.sites)
+
+			err = detector.Update(context.Background())
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			got := detector.Evaluate(tt.message)
+			assert.Equal(t, tt.wantAnomalous, got.Anomalous)
+			assert.Equal(t, tt.wantBaseline, got.Baseline)
+		})
+	}
+}
+
+func TestDetector_SetSites(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	learned(mock)
+
+	detector := anomaly.New(db)
+	detector.SetSites(sites)
+	require.NoError(t, detector.Update(context.Background()))
+
+	slow := monitor.Message{URL: shop, Timestamp: monday, Duration: 2 * time.Second}
+	assert.True(t, detector.Evaluate(slow).Anomalous)
+
+	// the shop doesn't want its anomalies detected anymore, so the baseline it learned is not used either
+	detector.SetSites([]config.SiteElement{{URL: shop}})
+	assert.False(t, detector.Evaluate(slow).Anomalous)
+}
+
+func TestDetector_Consume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	detector := anomaly.New(nil)
+
+	messageQueue := make(chan monitor.Message)
+	output := make(chan monitor.Message)
+
+	go detector.Consume(ctx, messageQueue, output)
+
+	messageQueue <- monitor.Message{URL: shop}
+	assert.Equal(t, shop, (<-output).URL)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/pbabbicola/go-monitor/httpjson"
+)
+
+var (
+	// errReadOnly is returned when something is changed through the API, but there is no API token to check.
+	errReadOnly = errors.New("API_TOKEN is not set, so the API is read-only")
+	// errUnauthorized is returned when the request doesn't have the API token as a bearer token.
+	errUnauthorized = errors.New("a valid bearer token is needed")
+)
+
+// authorized only lets the requests with the API token as a bearer token through to the handler, eg. for the routes that change something.
+// If there is no token, nobody is let through, so the API can't be changed by anyone who can reach it.
+func authorized(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		switch {
+		case token == "":
+			httpjson.WriteError(r.Context(), w, http.StatusForbidden, errReadOnly)
+		case !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpjson.WriteError(r.Context(), w, http.StatusUnauthorized, errUnauthorized)
+		default:
+			handler(w, r)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+		wantBody      string
+	}{
+		{
+			name:          "valid token",
+			token:         "s3cr3t-t0k3n",
+			authorization: "Bearer s3cr3t-t0k3n",
+			wantStatus:    http.StatusNoContent,
+		},
+		{
+			name:          "wrong token",
+			token:         "s3cr3t-t0k3n",
+			authorization: "Bearer guessed",
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"error":"a valid bearer token is needed"}`,
+		},
+		{
+			name:          "not a bearer token",
+			token:         "s3cr3t-t0k3n",
+			authorization: "s3cr3t-t0k3n",
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"error":"a valid bearer token is needed"}`,
+		},
+		{
+			name:       "no token",
+			token:      "s3cr3t-t0k3n",
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   `{"error":"a valid bearer token is needed"}`,
+		},
+		{
+			name:          "API_TOKEN is not set",
+			authorization: "Bearer ",
+			wantStatus:    http.StatusForbidden,
+			wantBody:      `{"error":"API_TOKEN is not set, so the API is read-only"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := authorized(tt.token, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/api/maintenance", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,13 +2,14 @@ package config
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -21,9 +22,58 @@ type EnvConfig struct {
 	LogLevel    slog.Level `env:"LOG_LEVEL" envDefault:"Debug"`
 	DatabaseURL string     `env:"DATABASE_URL,required"`
 	BatchSize   int        `env:"BATCH_SIZE" envDefault:"100"`
+	HTTPAddress string     `env:"HTTP_ADDRESS"` // Where to serve metrics. Nothing is served if it's empty.
+	// APIToken is the bearer token the routes of the API that change something need. If it's empty, the API is read-only.
+	APIToken string `env:"API_TOKEN"`
+	// Remote configurations are fetched again every ConfigRefreshSeconds, if it's set. The last good one is kept in ConfigCacheFile, if it's set, to start even if the server is down.
+	ConfigRefreshSeconds int    `env:"CONFIG_REFRESH_SECONDS"`
+	ConfigCacheFile      string `env:"CONFIG_CACHE_FILE"`
+	ConfigMaxBytes       int64  `env:"CONFIG_MAX_BYTES" envDefault:"10485760"`
+	// ConfigPublicKey is an ed25519 public key, in base64 or PEM. If it's set, remote configurations must be signed with its private key.
+	ConfigPublicKey string `env:"CONFIG_PUBLIC_KEY"`
+	NormalizationConfig
+	// The SLOs are computed again every SLORefreshSeconds. They are not computed at all if it's 0.
+	SLORefreshSeconds int `env:"SLO_REFRESH_SECONDS" envDefault:"60"`
+	// The status page is served alone on StatusPageAddress, so it can be public without the API. Nothing is served if it's empty.
+	StatusPageAddress        string `env:"STATUS_PAGE_ADDRESS"`
+	StatusPageTitle          string `env:"STATUS_PAGE_TITLE" envDefault:"Status"`
+	StatusPageRefreshSeconds int    `env:"STATUS_PAGE_REFRESH_SECONDS" envDefault:"60"`
+	// The latency baselines are learned again every AnomalyRefreshSeconds. Nothing is flagged if it's 0, as they are never learned.
+	AnomalyRefreshSeconds int `env:"ANOMALY_REFRESH_SECONDS" envDefault:"3600"`
 }
 
-// ParseEnv parses the configuration from the environment. If it fails, it returns a wrapped error from the env package.
+// NormalizationConfig is the part of [EnvConfig] that says how the site list is normalized, so the lint subcommand can parse it without the rest with [ParseNormalizationEnv].
+type NormalizationConfig struct {
+	// DuplicatePolicy says which site is kept when a URL is in the site list more than once. See [Normalize].
+	DuplicatePolicy DuplicatePolicy `env:"DUPLICATE_POLICY" envDefault:"first"`
+	// The intervals of the sites are clamped between these, see [Limits].
+	MinIntervalSeconds int `env:"MIN_INTERVAL_SECONDS" envDefault:"5"`
+	MaxIntervalSeconds int `env:"MAX_INTERVAL_SECONDS" envDefault:"300"`
+}
+
+// Limits returns the interval limits of the environment.
+func (n *NormalizationConfig) Limits() Limits {
+	return Limits{MinIntervalSeconds: n.MinIntervalSeconds, MaxIntervalSeconds: n.MaxIntervalSeconds}
+}
+
+// ParseNormalizationEnv parses only how the site list is normalized from the environment, like [ParseEnv] does, so it doesn't need a database. It fails like ParseEnv does.
+func ParseNormalizationEnv() (*NormalizationConfig, error) {
+	normalizationConfig := &NormalizationConfig{}
+
+	err := env.Parse(normalizationConfig)
+	if err != nil {
+		return nil, fmt.Errorf("parsing environment config: %w", err)
+	}
+
+	err = normalizationConfig.Limits().Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizationConfig, nil
+}
+
+// ParseEnv parses the configuration from the environment. If it fails, it returns a wrapped error from the env package, or [ErrInvalidLimits].
 func ParseEnv() (*EnvConfig, error) {
 	envConfig := &EnvConfig{}
 
@@ -32,60 +82,272 @@ func ParseEnv() (*EnvConfig, error) {
 		return nil, fmt.Errorf("parsing environment config: %w", err)
 	}
 
+	err = envConfig.Limits().Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return envConfig, nil
 }
 
-// SiteElement is a unit of configuration that describes the URL we need to monitor, the regexp that we want to check for, and the interval in which we should do so.
-type SiteElement struct {
-	URL             string         `json:"url"`
-	Regexp          *regexp.Regexp `json:"regexp"`
-	IntervalSeconds int            `json:"interval_seconds"`
+// CheckType describes what kind of check is done against a site. The zero value is an HTTP check.
+type CheckType string
+
+const (
+	CheckTypeHTTP      CheckType = "http"
+	CheckTypeGRPC      CheckType = "grpc"
+	CheckTypeWebSocket CheckType = "websocket"
+)
+
+// ErrUnknownCheckType is returned when a site element has a type that we don't know how to check.
+var ErrUnknownCheckType = errors.New("unknown check type")
+
+// UnmarshalText validates the check type, so a typo in the configuration fails at parse time instead of silently becoming an HTTP check.
+func (c *CheckType) UnmarshalText(text []byte) error {
+	switch checkType := CheckType(text); checkType {
+	case "", CheckTypeHTTP, CheckTypeGRPC, CheckTypeWebSocket:
+		*c = checkType
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownCheckType, checkType)
+	}
+
+	return nil
+}
+
+// GRPCCheck keeps the options of a gRPC health check. The URL of the site element is used as the target address (eg. localhost:50051).
+type GRPCCheck struct {
+	Service            string `json:"service"`              // Service name sent in the health check request. Empty checks the overall server health.
+	TLS                bool   `json:"tls"`                  // Whether to use TLS for the connection.
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // Skips certificate verification. Only for testing or self-signed certificates.
 }
 
-// Parse reads a filename, parses the json, and returns, if successful, a []SiteElement configuration.
+// WebSocketCheck keeps the options of a WebSocket check. The URL of the site element must be a ws:// or wss:// URL.
 //
-// If it fails, it returns a wrapped error. Underlying errors can be from [regexp.Compile], [json.Unmarshal], or [os.ReadFile].
-func Parse(filename string) ([]SiteElement, error) {
-	fileContents, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("reading file %v: %w", filename, err)
+// If Send is set, it's sent as a text message after the handshake. If Send or the site Regexp are set, the check waits for a reply matching the Regexp (or any reply if there is no Regexp) within the timeout.
+type WebSocketCheck struct {
+	Send string `json:"send"`
+}
+
+// RetryPolicy describes whether a failed check is attempted again before recording it as failed.
+//
+// RetryOn is a list of error classes that can be retried, as classified by the monitor (eg. timeout or connection_refused). If it's empty, connection errors, DNS errors and timeouts are retried.
+// RetryStatusCodes are the status codes of otherwise successful checks that are retried, like 502 or 503. None are retried by default.
+type RetryPolicy struct {
+	Attempts            int      `json:"attempts"`             // Total number of attempts, including the first one. Zero or one means no retries.
+	BackoffMilliseconds int      `json:"backoff_milliseconds"` // Wait before the first retry. It doubles after every retry.
+	RetryOn             []string `json:"retry_on"`
+	RetryStatusCodes    []int    `json:"retry_status_codes"`
+}
+
+// Extraction describes a number that is extracted from the body, and optionally the thresholds it must be within.
+//
+// If Regexp is set, the value is its capture group with the same name, or its first capture group. Otherwise, the value is the named capture group of the site regexp with the same name.
+type Extraction struct {
+	Name   string         `json:"name"`
+	Regexp *regexp.Regexp `json:"regexp"`
+	Min    *float64       `json:"min"`
+	Max    *float64       `json:"max"`
+}
+
+// RedirectPolicy describes assertions on the redirects of an HTTP check. Redirects are always followed (up to 10), and loops always fail the check.
+type RedirectPolicy struct {
+	MaxRedirects   *int   `json:"max_redirects"`    // How many redirects are allowed. Not set means any.
+	FinalURLPrefix string `json:"final_url_prefix"` // What the final URL must start with, eg. https://.
+}
+
+// AdaptivePolicy shortens the interval while a site is failing, to confirm the failure quickly and to know when exactly it recovers.
+// After a failed check, the interval is divided by Factor, down to FloorSeconds. After it recovers, it's multiplied by Factor after every successful check, until it's back to the normal schedule.
+type AdaptivePolicy struct {
+	Enabled      bool    `json:"enabled"`
+	FloorSeconds int     `json:"floor_seconds"` // Defaults to the minimum interval, and it can't be shorter.
+	Factor       float64 `json:"factor"`        // Defaults to 2.
+}
+
+// Defaults of [AnomalyPolicy].
+const (
+	DefaultAnomalySensitivity  = 5
+	DefaultAnomalyMinSamples   = 20
+	DefaultAnomalyBaselineDays = 28
+)
+
+// AnomalyPolicy flags checks that are much slower than usual for the site at that hour of the week, even if they don't fail.
+// The baseline is the median duration of the successful checks of the last BaselineDays at every hour of the week, and a check is anomalous if it's more than Sensitivity times the median absolute deviation above it.
+type AnomalyPolicy struct {
+	Enabled      bool    `json:"enabled"`
+	Sensitivity  float64 `json:"sensitivity"`   // Defaults to [DefaultAnomalySensitivity]. Lower is more sensitive.
+	MinSamples   int     `json:"min_samples"`   // Checks needed in an hour of the week to have a baseline. Defaults to [DefaultAnomalyMinSamples].
+	BaselineDays int     `json:"baseline_days"` // Defaults to [DefaultAnomalyBaselineDays].
+}
+
+// Defaults of [FlapPolicy].
+const (
+	DefaultFlapWindowChecks = 20
+	DefaultFlapStartPercent = 50
+	DefaultFlapStopPercent  = 25
+)
+
+// FlapPolicy detects sites that keep going up and down, from the percentage of their last WindowChecks checks that changed the state.
+// A site starts flapping when it's at least StartPercent, and stops when it's at most StopPercent, so it doesn't flap between flapping and not.
+type FlapPolicy struct {
+	Disabled     bool    `json:"disabled"`
+	WindowChecks int     `json:"window_checks"` // Defaults to [DefaultFlapWindowChecks].
+	StartPercent float64 `json:"start_percent"` // Defaults to [DefaultFlapStartPercent].
+	StopPercent  float64 `json:"stop_percent"`  // Defaults to [DefaultFlapStopPercent].
+}
+
+// WithDefaults returns the policy with the defaults of what isn't set.
+func (p FlapPolicy) WithDefaults() FlapPolicy {
+	if p.WindowChecks < 2 { //nolint:mnd // There are no state changes in less than two checks.
+		p.WindowChecks = DefaultFlapWindowChecks
 	}
 
-	siteConfiguration := []SiteElement{}
+	if p.StartPercent <= 0 {
+		p.StartPercent = DefaultFlapStartPercent
+	}
 
-	err = json.Unmarshal(fileContents, &siteConfiguration)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshaling file %v: %w", filename, err)
+	if p.StopPercent <= 0 {
+		p.StopPercent = DefaultFlapStopPercent
 	}
 
-	return siteConfiguration, nil
+	return p
 }
 
-func ParseRemote(ctx context.Context, client *http.Client, url string) ([]SiteElement, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+// SLOScope says which results an SLO is computed from.
+type SLOScope string
+
+const (
+	SLOScopeSite  SLOScope = "site"  // The results of the site. It's the default.
+	SLOScopeGroup SLOScope = "group" // The results of every site in the group of the site. It only needs to be in one of them.
+)
+
+// ErrUnknownSLOScope is returned when an SLO scope is not one of the known ones.
+var ErrUnknownSLOScope = errors.New("unknown slo scope")
+
+// UnmarshalText validates the scope, so a typo fails at parse time.
+func (s *SLOScope) UnmarshalText(text []byte) error {
+	switch scope := SLOScope(text); scope {
+	case "":
+		*s = SLOScopeSite
+	case SLOScopeSite, SLOScopeGroup:
+		*s = scope
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownSLOScope, scope)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("performing request: %w", err)
+	return nil
+}
+
+// DefaultSLOWindowDays is the rolling window of an SLO, if it doesn't say.
+const DefaultSLOWindowDays = 30
+
+// SLO is a service level objective: the percentage of checks that must be good within a rolling window. A check is good if it didn't fail and, if there is a latency threshold, it wasn't slower than it.
+// Checks in maintenance windows don't count.
+type SLO struct {
+	Name                         string   `json:"name"` // Defaults to availability, or latency if there is a threshold.
+	TargetPercent                float64  `json:"target_percent"`
+	LatencyThresholdMilliseconds int      `json:"latency_threshold_milliseconds"`
+	WindowDays                   int      `json:"window_days"` // Defaults to [DefaultSLOWindowDays].
+	Scope                        SLOScope `json:"scope"`
+}
+
+// DefaultMaxBodyBytes is how much of a response body is read if the site does not set a limit.
+const DefaultMaxBodyBytes = 10 << 20 // 10 MiB
+
+// SiteElement is a unit of configuration that describes the URL we need to monitor, the regexp that we want to check for, and the interval in which we should do so.
+type SiteElement struct {
+	URL             string         `json:"url"`
+	Regexp          *regexp.Regexp `json:"regexp"`
+	IntervalSeconds int            `json:"interval_seconds"`
+	TimeoutSeconds  int            `json:"timeout_seconds"` // Defaults to the interval if not set.
+	MaxBodyBytes    int64          `json:"max_body_bytes"`  // Defaults to [DefaultMaxBodyBytes] if not set.
+	Type            CheckType      `json:"type"`
+	GRPC            GRPCCheck      `json:"grpc"`
+	WebSocket       WebSocketCheck `json:"websocket"`
+	Retry           RetryPolicy    `json:"retry"`
+	// Schedule, if it's set, is when the site is checked instead of every interval, eg. */15 * * * * for every 15 minutes. Intervals change the interval during part of the week, eg. to check more often during business hours.
+	// Both are in TimeZone, which defaults to UTC.
+	Schedule  *Cron            `json:"schedule"`
+	Intervals []IntervalWindow `json:"intervals"`
+	TimeZone  *Location        `json:"time_zone"`
+	Adaptive  AdaptivePolicy   `json:"adaptive"`
+	// DetectChanges compares every body with the previous one, to find unexpected changes even if the regexp still matches.
+	// Everything matching ChangeIgnoreRegexps (eg. timestamps or nonces) is removed before comparing.
+	DetectChanges       bool             `json:"detect_changes"`
+	ChangeIgnoreRegexps []*regexp.Regexp `json:"change_ignore_regexps"`
+	// Extract lists numbers to extract from the body. Named capture groups of Regexp are always extracted, so this is only needed for thresholds or separate regexps.
+	Extract   []Extraction   `json:"extract"`
+	Redirects RedirectPolicy `json:"redirects"`
+	// Headers are sent with every request of the check: the HTTP request, the WebSocket handshake or the gRPC metadata. Use ${NAME} or ${file:/path} for secrets.
+	Headers map[string]string `json:"headers"`
+	// Labels, Group and Owner are not used for checking, but they are carried to every result, eg. to filter or aggregate by team or product.
+	Labels map[string]string `json:"labels"`
+	Group  string            `json:"group"`
+	Owner  string            `json:"owner"`
+	// DependsOn lists the URLs of other sites this one needs, eg. the CDN or the API gateway in front of it. While one of them is down, failures of this site are suppressed instead of alerted on.
+	// [Normalize] resolves them to the URLs of the sites they reference, and rejects the ones that can't be found or that form a cycle.
+	DependsOn []string `json:"depends_on"`
+	// Maintenance lists the times when the site is expected to be down. More can be added at runtime through the API.
+	Maintenance []MaintenanceWindow `json:"maintenance"`
+	// SLOs are the availability targets of the site, or of its group.
+	SLOs []SLO `json:"slos"`
+	// Public sites are listed in the status page, as PublicName, or as their URL if it's empty. Sites are not public unless they say so, so internal URLs are never shown.
+	Public     bool   `json:"public"`
+	PublicName string `json:"public_name"`
+	// Anomaly learns how fast the site usually is, and flags the checks that are much slower.
+	Anomaly AnomalyPolicy `json:"anomaly"`
+	// Flapping says when the site is flapping, which is detected for every site unless it's disabled.
+	Flapping FlapPolicy `json:"flapping"`
+
+	secrets []string // values that were interpolated into the site, see [SiteElement.Redact].
+}
+
+// Timeout returns how long a single check may take. If no timeout is configured, the interval is used so a check never overlaps the next tick.
+// A site with only a schedule uses [DefaultMaxIntervalSeconds], so a check can't hang forever. It returns zero if nothing is set, which means no timeout.
+func (s SiteElement) Timeout() time.Duration {
+	switch {
+	case s.TimeoutSeconds > 0:
+		return time.Duration(s.TimeoutSeconds) * time.Second
+	case s.IntervalSeconds == 0 && s.Schedule != nil:
+		return DefaultMaxIntervalSeconds * time.Second
+	default:
+		return time.Duration(s.IntervalSeconds) * time.Second
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading body: %w", err)
+// BodyLimit returns how many bytes of the response body may be read.
+func (s SiteElement) BodyLimit() int64 {
+	if s.MaxBodyBytes > 0 {
+		return s.MaxBodyBytes
+	}
+
+	return DefaultMaxBodyBytes
+}
+
+// ExtractsValues returns whether any number has to be extracted from the body, either because of the extract list or because the regexp has named capture groups.
+func (s SiteElement) ExtractsValues() bool {
+	if len(s.Extract) > 0 {
+		return true
 	}
 
-	siteConfiguration := []SiteElement{}
+	return s.Regexp != nil && slices.ContainsFunc(s.Regexp.SubexpNames(), func(name string) bool { return name != "" })
+}
 
-	err = json.Unmarshal(body, &siteConfiguration)
+// Parse reads a filename, parses it, and returns, if successful, a []SiteElement configuration.
+// The format (JSON, YAML or TOML) is chosen by the extension, and it defaults to JSON.
+//
+// If the site list is not valid, it returns [ValidationErrors] with every problem found. Otherwise, it returns a wrapped error, eg. from [os.ReadFile].
+func Parse(filename string) ([]SiteElement, error) {
+	fileContents, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("unmarshaling file: %w", err)
+		return nil, fmt.Errorf("reading file %v: %w", filename, err)
 	}
 
-	slog.DebugContext(ctx, "Configuration successfully read.")
+	return parse(fileContents, FormatFromName(filename), filename)
+}
 
-	return siteConfiguration, nil
+// ParseRemote downloads a site list and parses it like [Parse]. The format is chosen by the Content-Type of the response, or by the extension of the URL if the Content-Type is not one we know (eg. text/plain).
+//
+// It fails if the status code is not 2xx or if the site list is bigger than [DefaultMaxConfigBytes]. Use [Remote] to refresh it or to cache it.
+func ParseRemote(ctx context.Context, client *http.Client, url string) ([]SiteElement, error) {
+	return NewRemote(client, url, "", DefaultMaxConfigBytes, nil).Fetch(ctx)
 }
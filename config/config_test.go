@@ -1,10 +1,19 @@
 package config_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/pbabbicola/go-monitor/config"
 )
@@ -28,6 +37,88 @@ func TestParse(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:     "grpc check",
+			filename: "testdata/grpc.json",
+			want: []config.SiteElement{
+				{
+					URL:             "localhost:50051",
+					IntervalSeconds: 10,
+					TimeoutSeconds:  2,
+					Type:            config.CheckTypeGRPC,
+					GRPC: config.GRPCCheck{
+						Service: "payments",
+						TLS:     true,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "yaml",
+			filename: "testdata/correct.yaml",
+			want: []config.SiteElement{
+				{
+					URL:             "https://duckduckgo.com",
+					Regexp:          regexp.MustCompile("duck"),
+					IntervalSeconds: 5,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "toml",
+			filename: "testdata/correct.toml",
+			want: []config.SiteElement{
+				{
+					URL:             "https://duckduckgo.com",
+					Regexp:          regexp.MustCompile("duck"),
+					IntervalSeconds: 5,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "grpc check in yaml",
+			filename: "testdata/grpc.yaml",
+			want: []config.SiteElement{
+				{
+					URL:             "localhost:50051",
+					IntervalSeconds: 10,
+					TimeoutSeconds:  2,
+					Type:            config.CheckTypeGRPC,
+					GRPC: config.GRPCCheck{
+						Service: "payments",
+						TLS:     true,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "invalid yaml",
+			filename: "testdata/invalid.yaml",
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "bad yaml",
+			filename: "testdata/bad_yaml.yaml",
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "toml without a site list",
+			filename: "testdata/not_a_list.toml",
+			want:     nil,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown check type",
+			filename: "testdata/unknown_type.json",
+			want:     nil,
+			wantErr:  true,
+		},
 		{
 			name:     "bad regexp",
 			filename: "testdata/bad_regexp.json",
@@ -55,3 +146,165 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParse_ValidationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     config.ValidationErrors
+	}{
+		{
+			name:     "yaml",
+			filename: "testdata/invalid.yaml",
+			want: config.ValidationErrors{
+				{File: "testdata/invalid.yaml", Line: 2, Index: 0, Field: "regexp", Message: "error parsing regexp: missing closing ): `duck(`"},
+				{File: "testdata/invalid.yaml", Line: 3, Index: 0, Field: "interval_seconds", Message: "got string, want integer"},
+				{File: "testdata/invalid.yaml", Line: 4, Index: 1, Message: "missing property 'url'"},
+				{File: "testdata/invalid.yaml", Line: 4, Index: 1, Message: "additional properties 'colour' not allowed"},
+				{File: "testdata/invalid.yaml", Line: 6, Index: 1, Field: "retry.attempts", Message: "minimum: got -1, want 0"},
+			},
+		},
+		{
+			name:     "toml",
+			filename: "testdata/invalid.toml",
+			want: config.ValidationErrors{
+				{File: "testdata/invalid.toml", Line: 3, Index: 0, Field: "regexp", Message: "error parsing regexp: missing closing ): `duck(`"},
+				{File: "testdata/invalid.toml", Line: 8, Index: 1, Field: "type", Message: "value must be one of '', 'http', 'grpc', 'websocket'"},
+				{File: "testdata/invalid.toml", Line: 10, Index: 1, Field: "retry.attempts", Message: "minimum: got -1, want 0"},
+			},
+		},
+		{
+			name:     "json syntax error",
+			filename: "testdata/bad_json.json",
+			want: config.ValidationErrors{
+				{File: "testdata/bad_json.json", Line: 4, Index: -1, Message: "invalid character '\"' after object key:value pair"},
+			},
+		},
+		{
+			name:     "json",
+			filename: "testdata/bad_regexp.json",
+			want: config.ValidationErrors{
+				{File: "testdata/bad_regexp.json", Line: 4, Index: 0, Field: "regexp", Message: "error parsing regexp: missing argument to repetition operator: `*`"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse(tt.filename)
+
+			var got config.ValidationErrors
+
+			require.ErrorAs(t, err, &got)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	files := map[string]string{
+		"/sites.json": "testdata/correct.json",
+		"/sites.yaml": "testdata/correct.yaml",
+		"/sites":      "testdata/correct.json",
+		"/toml":       "testdata/correct.toml",
+		"/sites.toml": "testdata/correct.toml",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, err := os.ReadFile(files[r.URL.Path])
+		if err != nil {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", r.URL.Query().Get("content_type"))
+		w.Write(contents) //nolint:errcheck // The test fails anyway if the write fails.
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name: "json by content type",
+			path: "/sites?content_type=application/json",
+		},
+		{
+			name: "json by default",
+			path: "/sites.json?content_type=text/plain",
+		},
+		{
+			name: "yaml by extension",
+			path: "/sites.yaml?content_type=text/plain",
+		},
+		{
+			name: "toml by content type",
+			path: "/toml?content_type=application/toml%3Bcharset=utf-8",
+		},
+		{
+			name: "toml by extension",
+			path: "/sites.toml",
+		},
+		{
+			name:    "content type wins over the extension",
+			path:    "/sites.yaml?content_type=application/json",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.ParseRemote(context.Background(), server.Client(), server.URL+tt.path)
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+
+			if !tt.wantErr {
+				assert.Equal(t, []config.SiteElement{{URL: "https://duckduckgo.com", Regexp: regexp.MustCompile("duck"), IntervalSeconds: 5}}, got)
+			}
+		})
+	}
+}
+
+// TestSchema makes sure the schema is not forgotten when a field is added to the site element.
+func TestSchema(t *testing.T) {
+	schema := struct {
+		Defs struct {
+			Site struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"site"`
+		} `json:"$defs"` //nolint:tagliatelle // It's the JSON Schema keyword.
+	}{}
+
+	require.NoError(t, json.Unmarshal(config.Schema, &schema))
+
+	fields := 0
+
+	siteElement := reflect.TypeFor[config.SiteElement]()
+	for i := range siteElement.NumField() {
+		if !siteElement.Field(i).IsExported() {
+			continue
+		}
+
+		fields++
+
+		name, _, _ := strings.Cut(siteElement.Field(i).Tag.Get("json"), ",")
+		assert.Containsf(t, schema.Defs.Site.Properties, name, "%v is missing from the schema", siteElement.Field(i).Name)
+	}
+
+	assert.Len(t, schema.Defs.Site.Properties, fields, "the schema has properties that the site element does not")
+}
+
+func TestValidationError_Error(t *testing.T) {
+	err := config.ValidationErrors{
+		{File: "sites.yaml", Line: 3, Index: 1, Field: "retry.attempts", Message: "minimum: got -1, want 0"},
+		{File: "sites.yaml", Index: -1, Message: "got object, want array"},
+	}
+
+	assert.Equal(t, "sites.yaml:3: site 1: retry.attempts: minimum: got -1, want 0\nsites.yaml: got object, want array", err.Error())
+
+	var validationError *config.ValidationError
+
+	require.ErrorAs(t, err, &validationError)
+	assert.Equal(t, "retry.attempts", validationError.Field)
+	assert.False(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the format of a site list.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// tomlSitesKey is the key of the site list in TOML, as TOML documents can't be an array.
+const tomlSitesKey = "sites"
+
+// ErrNotATOMLSiteList is returned when a TOML document has no list of sites.
+var ErrNotATOMLSiteList = errors.New("expected a [[sites]] array of tables")
+
+// FormatFromName returns the format of a file name or URL path by its extension. It defaults to JSON.
+func FormatFromName(name string) Format {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// FormatFromContentType returns the format of a Content-Type header, and whether it was recognized.
+func FormatFromContentType(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+
+	switch mediaType {
+	case "application/json":
+		return FormatJSON, true
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML, true
+	case "application/toml", "text/toml":
+		return FormatTOML, true
+	default:
+		return "", false
+	}
+}
+
+// positions keeps the line of every value in a document, by its JSON pointer (eg. /0/retry/attempts).
+type positions map[string]int
+
+// line returns the line of the value at the pointer, or of the closest parent that has one. It returns 0 if it's unknown.
+func (p positions) line(pointer string) int {
+	for {
+		if line, ok := p[pointer]; ok {
+			return line
+		}
+
+		index := strings.LastIndex(pointer, "/")
+		if index < 0 {
+			return 0
+		}
+
+		pointer = pointer[:index]
+	}
+}
+
+// pointerEscaper escapes JSON pointer tokens.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// decode decodes a site list into generic values (maps, slices and scalars, like [json.Unmarshal] into an any), and finds out the line of every value.
+func decode(format Format, contents []byte) (any, positions, error) {
+	switch format {
+	case FormatYAML:
+		return decodeYAML(contents)
+	case FormatTOML:
+		return decodeTOML(contents)
+	default:
+		return decodeJSON(contents)
+	}
+}
+
+// lineAt returns the line of the next token after the offset, skipping whitespace and separators.
+func lineAt(contents []byte, offset int64) int {
+	for offset < int64(len(contents)) && strings.ContainsRune(" \t\r\n,:", rune(contents[offset])) {
+		offset++
+	}
+
+	return bytes.Count(contents[:offset], []byte("\n")) + 1
+}
+
+func decodeJSON(contents []byte) (any, positions, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+
+	lines := positions{}
+
+	value, err := decodeJSONValue(decoder, contents, "", lines)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return nil, nil, &ValidationError{Line: lineAt(contents, syntaxError.Offset), Index: -1, Message: syntaxError.Error()}
+		}
+
+		return nil, nil, &ValidationError{Line: lineAt(contents, decoder.InputOffset()), Index: -1, Message: err.Error()}
+	}
+
+	return value, lines, nil
+}
+
+// decodeJSONValue decodes the next value of the decoder token by token, keeping track of where every value is.
+func decodeJSONValue(decoder *json.Decoder, contents []byte, pointer string, lines positions) (any, error) {
+	if _, ok := lines[pointer]; !ok { // object values keep the line of their key
+		lines[pointer] = lineAt(contents, decoder.InputOffset())
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("reading token: %w", err)
+	}
+
+	delimiter, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delimiter {
+	case '[':
+		array := []any{}
+
+		for index := 0; decoder.More(); index++ {
+			value, err := decodeJSONValue(decoder, contents, pointer+"/"+strconv.Itoa(index), lines)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		_, err = decoder.Token() // the closing ]
+
+		return array, err //nolint:wrapcheck // It's a syntax error that is handled by the caller.
+	default: // it can only be {, as the decoder fails for unexpected closing delimiters
+		object := map[string]any{}
+
+		for decoder.More() {
+			line := lineAt(contents, decoder.InputOffset())
+
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("reading key: %w", err)
+			}
+
+			childPointer := pointer + "/" + pointerEscaper.Replace(fmt.Sprint(key))
+			lines[childPointer] = line
+
+			value, err := decodeJSONValue(decoder, contents, childPointer, lines)
+			if err != nil {
+				return nil, err
+			}
+
+			object[fmt.Sprint(key)] = value
+		}
+
+		_, err = decoder.Token() // the closing }
+
+		return object, err //nolint:wrapcheck // It's a syntax error that is handled by the caller.
+	}
+}
+
+func decodeYAML(contents []byte) (any, positions, error) {
+	document := yaml.Node{}
+
+	err := yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return nil, nil, &ValidationError{Index: -1, Message: err.Error()} // yaml errors already say the line
+	}
+
+	if len(document.Content) == 0 { // empty document
+		return nil, positions{}, nil
+	}
+
+	lines := positions{}
+
+	value, err := decodeYAMLNode(document.Content[0], "", lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return value, lines, nil
+}
+
+// decodeYAMLNode converts a YAML node into generic values, keeping track of where every value is.
+func decodeYAMLNode(node *yaml.Node, pointer string, lines positions) (any, error) {
+	lines[pointer] = node.Line
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return decodeYAMLNode(node.Alias, pointer, lines)
+	case yaml.SequenceNode:
+		array := make([]any, 0, len(node.Content))
+
+		for index, child := range node.Content {
+			value, err := decodeYAMLNode(child, pointer+"/"+strconv.Itoa(index), lines)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		return array, nil
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2) //nolint:mnd // Keys and values.
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+
+			value, err := decodeYAMLNode(node.Content[i+1], pointer+"/"+pointerEscaper.Replace(key), lines)
+			if err != nil {
+				return nil, err
+			}
+
+			lines[pointer+"/"+pointerEscaper.Replace(key)] = node.Content[i].Line
+			object[key] = value
+		}
+
+		return object, nil
+	default:
+		var value any
+
+		err := node.Decode(&value)
+		if err != nil {
+			return nil, &ValidationError{Line: node.Line, Index: -1, Message: err.Error()}
+		}
+
+		if timestamp, ok := value.(time.Time); ok { // unquoted timestamps are decoded as times, but JSON only has strings
+			return timestamp.Format(time.RFC3339Nano), nil
+		}
+
+		return value, nil
+	}
+}
+
+// tomlTableHeader matches the header of an element of the site list.
+var tomlTableHeader = regexp.MustCompile(`^\s*\[\[\s*` + tomlSitesKey + `\s*\]\]`)
+
+// tomlKey matches a key, or the header of a sub-table, at the beginning of a line.
+var tomlKey = regexp.MustCompile(`^\s*(?:\[\s*` + tomlSitesKey + `\.)?"?([A-Za-z0-9_-]+)"?\s*(?:=|\])`)
+
+func decodeTOML(contents []byte) (any, positions, error) {
+	document := map[string]any{}
+
+	_, err := toml.Decode(string(contents), &document)
+	if err != nil {
+		var parseError toml.ParseError
+		if errors.As(err, &parseError) {
+			return nil, nil, &ValidationError{Line: parseError.Position.Line, Index: -1, Message: parseError.Message}
+		}
+
+		return nil, nil, &ValidationError{Index: -1, Message: err.Error()}
+	}
+
+	sites, ok := document[tomlSitesKey].([]map[string]any)
+	if !ok {
+		return nil, nil, &ValidationError{Index: -1, Message: ErrNotATOMLSiteList.Error()}
+	}
+
+	// The TOML decoder does not tell us where anything is, so we look for the [[sites]] headers and the keys under them.
+	// It's only for error messages, so nested keys get the line of their parent.
+	lines := positions{}
+	index := -1
+
+	for number, line := range strings.Split(string(contents), "\n") {
+		if tomlTableHeader.MatchString(line) {
+			index++
+			lines["/"+strconv.Itoa(index)] = number + 1
+
+			continue
+		}
+
+		if match := tomlKey.FindStringSubmatch(line); match != nil && index >= 0 {
+			if _, ok := lines["/"+strconv.Itoa(index)+"/"+match[1]]; !ok {
+				lines["/"+strconv.Itoa(index)+"/"+match[1]] = number + 1
+			}
+		}
+	}
+
+	array := make([]any, 0, len(sites))
+	for _, site := range sites {
+		array = append(array, normalizeTOML(site))
+	}
+
+	return array, lines, nil
+}
+
+// normalizeTOML converts the arrays of tables of the TOML decoder into generic slices, so they look like the JSON and YAML ones.
+func normalizeTOML(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			typed[key] = normalizeTOML(child)
+		}
+
+		return typed
+	case []map[string]any:
+		array := make([]any, 0, len(typed))
+		for _, child := range typed {
+			array = append(array, normalizeTOML(child))
+		}
+
+		return array
+	case []any:
+		for i, child := range typed {
+			typed[i] = normalizeTOML(child)
+		}
+
+		return typed
+	case time.Time: // JSON only has strings
+		return typed.Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the secrets of a site in everything that leaves the monitor: logs, errors and results.
+const Redacted = "[REDACTED]"
+
+// ErrInvalidReference is returned when a ${...} in a site list can't be resolved.
+var ErrInvalidReference = errors.New("invalid reference")
+
+// filePrefix marks a reference to a file, eg. ${file:/run/secrets/token}.
+const filePrefix = "file:"
+
+// minSecretLength is how long a resolved value must be, unless it's empty. Every value is redacted, and shorter ones, like a port or "true", would be redacted everywhere they appear.
+const minSecretLength = 8
+
+// reference matches ${NAME} and ${file:/path}, and also $${...}, which is how a literal ${...} is written.
+var reference = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// environmentVariable is what a variable name may look like.
+var environmentVariable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolate replaces the references to environment variables and files in every string value of the site list (but not in the keys).
+// It returns the resolved values of every site, to redact them later, and an error for every reference that can't be resolved.
+func interpolate(value any, lines positions) (map[int][]string, ValidationErrors) {
+	secrets := map[int][]string{}
+	errs := ValidationErrors{}
+
+	sites, _ := value.([]any)
+	for i, site := range sites {
+		sites[i] = interpolateValue(site, []string{strconv.Itoa(i)}, func(location []string, text string) string {
+			return reference.ReplaceAllStringFunc(text, func(match string) string {
+				if strings.HasPrefix(match, "$$") { // escaped
+					return match[1:]
+				}
+
+				resolved, err := resolve(match[2 : len(match)-1])
+				if err != nil {
+					errs = append(errs, newValidationError(location, lines, err.Error()))
+
+					return match
+				}
+
+				if resolved != "" && len(resolved) < minSecretLength { // it would be sent but not redacted, so it's better not to start
+					errs = append(errs, newValidationError(location, lines, fmt.Sprintf("%v: %v is shorter than %d characters, so it can't be redacted", ErrInvalidReference, match, minSecretLength)))
+
+					return match
+				}
+
+				if resolved != "" {
+					secrets[i] = append(secrets[i], resolved)
+				}
+
+				return resolved
+			})
+		})
+	}
+
+	return secrets, errs
+}
+
+// interpolateValue calls replace for every string in the value, and puts the result in its place.
+func interpolateValue(value any, location []string, replace func(location []string, text string) string) any {
+	switch typed := value.(type) {
+	case string:
+		return replace(location, typed)
+	case map[string]any:
+		for key, child := range typed {
+			typed[key] = interpolateValue(child, append(slices.Clip(location), key), replace)
+		}
+
+		return typed
+	case []any:
+		for i, child := range typed {
+			typed[i] = interpolateValue(child, append(slices.Clip(location), strconv.Itoa(i)), replace)
+		}
+
+		return typed
+	default:
+		return value
+	}
+}
+
+// resolve returns the value of a reference, without the ${ and }. Files are read whole, without the trailing newline that most tools (and editors) add.
+func resolve(name string) (string, error) {
+	if path, ok := strings.CutPrefix(name, filePrefix); ok {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading ${%v}: %w", name, err)
+		}
+
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	}
+
+	if !environmentVariable.MatchString(name) {
+		return "", fmt.Errorf("%w: ${%v} is neither an environment variable nor a file", ErrInvalidReference, name)
+	}
+
+	resolved, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: ${%v} is not set", ErrInvalidReference, name)
+	}
+
+	return resolved, nil
+}
+
+// redact replaces every secret in the text. Longer secrets are replaced first, so a secret that contains another one is not half redacted.
+func redact(text string, secrets []string) string {
+	if len(secrets) == 0 {
+		return text
+	}
+
+	sorted := slices.SortedFunc(slices.Values(secrets), func(a, b string) int { return len(b) - len(a) })
+	for _, secret := range sorted {
+		text = strings.ReplaceAll(text, secret, Redacted)
+	}
+
+	return text
+}
+
+// Redact replaces the secrets that were interpolated into the site in the text, eg. an error message. It's safe to call with any text, as it does nothing for sites without secrets.
+func (s SiteElement) Redact(text string) string {
+	return redact(text, s.secrets)
+}
+
+// String returns the URL of the site, without secrets. It's what is shown when a site is formatted, eg. in errors, so the whole element (and its headers) is never printed.
+func (s SiteElement) String() string {
+	return s.Redact(s.URL)
+}
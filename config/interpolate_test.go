@@ -0,0 +1,79 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// writeSites writes a site list to a temporary file, and returns its name.
+func writeSites(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, []byte(contents), 0o600))
+
+	return filename
+}
+
+func TestParse_Interpolation(t *testing.T) {
+	secretFile := writeSites(t, "token", "file-secret\n")
+
+	t.Setenv("GOMONITOR_TEST_HOST", "example.org")
+	t.Setenv("GOMONITOR_TEST_TOKEN", "env-secret")
+
+	filename := writeSites(t, "sites.yaml", `
+- url: https://${GOMONITOR_TEST_HOST}/health?token=${GOMONITOR_TEST_TOKEN}
+  regexp: price $${literal}
+  headers:
+    Authorization: Bearer ${file:`+secretFile+`}
+`)
+
+	got, err := config.Parse(filename)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	assert.Equal(t, "https://example.org/health?token=env-secret", got[0].URL)
+	assert.Equal(t, "price ${literal}", got[0].Regexp.String())
+	assert.Equal(t, map[string]string{"Authorization": "Bearer file-secret"}, got[0].Headers)
+
+	assert.Equal(t, "https://[REDACTED]/health?token=[REDACTED]", got[0].String())
+	assert.Equal(t, "Bearer [REDACTED] was sent", got[0].Redact("Bearer file-secret was sent"))
+}
+
+func TestParse_Interpolation_Errors(t *testing.T) {
+	t.Setenv("GOMONITOR_TEST_REGEXP", "secret-regexp(")
+	t.Setenv("GOMONITOR_TEST_PORT", "8080")
+
+	filename := writeSites(t, "sites.json", `[
+    {
+        "url": "https://example.org:${GOMONITOR_TEST_PORT}",
+        "regexp": "${GOMONITOR_TEST_REGEXP}",
+        "headers": {
+            "Authorization": "${GOMONITOR_TEST_NOT_SET}",
+            "X-Token": "${file:/does/not/exist}",
+            "X-Other": "${not a variable}"
+        }
+    }
+]`)
+
+	_, err := config.Parse(filename)
+
+	var got config.ValidationErrors
+
+	require.ErrorAs(t, err, &got)
+	require.Len(t, got, 5)
+
+	assert.ErrorContains(t, err, "site 0: regexp: error parsing regexp: missing closing ): `[REDACTED]`")
+	assert.ErrorContains(t, err, "site 0: headers.Authorization: invalid reference: ${GOMONITOR_TEST_NOT_SET} is not set")
+	assert.ErrorContains(t, err, "site 0: headers.X-Token: reading ${file:/does/not/exist}")
+	assert.ErrorContains(t, err, "site 0: headers.X-Other: invalid reference: ${not a variable} is neither an environment variable nor a file")
+	assert.ErrorContains(t, err, "site 0: url: invalid reference: ${GOMONITOR_TEST_PORT} is shorter than 8 characters, so it can't be redacted")
+	assert.NotContains(t, err.Error(), "secret-regexp(")
+	assert.NotContains(t, err.Error(), "8080")
+}
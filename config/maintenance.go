@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaintenanceMode says what happens to the checks of a site during a maintenance window.
+type MaintenanceMode string
+
+const (
+	MaintenanceModeFlag MaintenanceMode = "flag" // The site is still checked, but the results are marked as in maintenance and they are not alerted on. It's the default.
+	MaintenanceModeSkip MaintenanceMode = "skip" // The site is not checked at all.
+)
+
+var (
+	// ErrUnknownMaintenanceMode is returned when a maintenance mode is not one of the known ones.
+	ErrUnknownMaintenanceMode = errors.New("unknown maintenance mode")
+	// ErrInvalidMaintenanceWindow is returned when a maintenance window has neither start and end nor cron and duration, or when it ends before it starts.
+	ErrInvalidMaintenanceWindow = errors.New("invalid maintenance window")
+)
+
+// UnmarshalText validates the mode, so a typo fails at parse time.
+func (m *MaintenanceMode) UnmarshalText(text []byte) error {
+	switch mode := MaintenanceMode(text); mode {
+	case "":
+		*m = MaintenanceModeFlag
+	case MaintenanceModeFlag, MaintenanceModeSkip:
+		*m = mode
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMaintenanceMode, mode)
+	}
+
+	return nil
+}
+
+// MaintenanceWindow is a time when a site is expected to be down. It's either a one-off window, from Start to End, or a recurring one, that starts at every time of Cron and lasts DurationSeconds.
+type MaintenanceWindow struct {
+	Start           time.Time       `json:"start,omitzero"`
+	End             time.Time       `json:"end,omitzero"`
+	Cron            *Cron           `json:"cron,omitzero"`
+	DurationSeconds int             `json:"duration_seconds,omitzero"`
+	TimeZone        *Location       `json:"time_zone,omitzero"` // Time zone of Cron. Defaults to UTC.
+	Mode            MaintenanceMode `json:"mode"`
+	Reason          string          `json:"reason"` // Why there is a maintenance, for whoever sees the results.
+}
+
+// Validate checks that the window is either one-off or recurring, and that it doesn't end before it starts.
+func (w MaintenanceWindow) Validate() error {
+	if w.Cron != nil {
+		if w.DurationSeconds <= 0 {
+			return fmt.Errorf("%w: a recurring window needs a positive duration_seconds", ErrInvalidMaintenanceWindow)
+		}
+
+		return nil
+	}
+
+	if w.Start.IsZero() || w.End.IsZero() {
+		return fmt.Errorf("%w: it needs start and end, or cron and duration_seconds", ErrInvalidMaintenanceWindow)
+	}
+
+	if !w.End.After(w.Start) {
+		return fmt.Errorf("%w: it ends before it starts", ErrInvalidMaintenanceWindow)
+	}
+
+	return nil
+}
+
+// Active returns whether the window is active at a time.
+func (w MaintenanceWindow) Active(at time.Time) bool {
+	if w.Cron == nil {
+		return !at.Before(w.Start) && at.Before(w.End)
+	}
+
+	location := time.UTC
+	if w.TimeZone != nil {
+		location = w.TimeZone.Location
+	}
+
+	// the window is active if it started in the last duration, so the first start after that is not after now.
+	duration := time.Duration(w.DurationSeconds) * time.Second
+
+	return !w.Cron.Next(at.In(location).Add(-duration)).After(at)
+}
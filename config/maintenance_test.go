@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+
+	return parsed
+}
+
+func TestMaintenanceWindow_Active(t *testing.T) {
+	nightly, err := config.ParseCron("0 2 * * *")
+	require.NoError(t, err)
+
+	berlin := &config.Location{}
+	require.NoError(t, berlin.UnmarshalText([]byte("Europe/Berlin")))
+
+	oneOff := config.MaintenanceWindow{Start: mustParseTime(t, "2026-10-18T10:00:00Z"), End: mustParseTime(t, "2026-10-18T12:00:00Z")}
+	recurring := config.MaintenanceWindow{Cron: nightly, DurationSeconds: 3600}
+	recurringInBerlin := config.MaintenanceWindow{Cron: nightly, DurationSeconds: 3600, TimeZone: berlin}
+
+	tests := []struct {
+		name   string
+		window config.MaintenanceWindow
+		at     string
+		want   bool
+	}{
+		{name: "before a one-off window", window: oneOff, at: "2026-10-18T09:59:59Z", want: false},
+		{name: "start of a one-off window", window: oneOff, at: "2026-10-18T10:00:00Z", want: true},
+		{name: "end of a one-off window", window: oneOff, at: "2026-10-18T12:00:00Z", want: false},
+		{name: "start of a recurring window", window: recurring, at: "2026-10-18T02:00:00Z", want: true},
+		{name: "during a recurring window", window: recurring, at: "2026-10-20T02:59:59Z", want: true},
+		{name: "end of a recurring window", window: recurring, at: "2026-10-18T03:00:00Z", want: false},
+		{name: "recurring window in a time zone", window: recurringInBerlin, at: "2026-10-18T00:30:00Z", want: true},
+		{name: "recurring window in another time zone", window: recurringInBerlin, at: "2026-10-18T02:30:00Z", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.window.Active(mustParseTime(t, tt.at)))
+		})
+	}
+}
+
+func TestMaintenanceWindow_Validate(t *testing.T) {
+	daily, err := config.ParseCron("@daily")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		window  config.MaintenanceWindow
+		wantErr error
+	}{
+		{
+			name:   "one-off",
+			window: config.MaintenanceWindow{Start: mustParseTime(t, "2026-10-18T10:00:00Z"), End: mustParseTime(t, "2026-10-18T12:00:00Z")},
+		},
+		{
+			name:   "recurring",
+			window: config.MaintenanceWindow{Cron: daily, DurationSeconds: 60},
+		},
+		{
+			name:    "ends before it starts",
+			window:  config.MaintenanceWindow{Start: mustParseTime(t, "2026-10-18T12:00:00Z"), End: mustParseTime(t, "2026-10-18T10:00:00Z")},
+			wantErr: config.ErrInvalidMaintenanceWindow,
+		},
+		{
+			name:    "recurring without duration",
+			window:  config.MaintenanceWindow{Cron: daily},
+			wantErr: config.ErrInvalidMaintenanceWindow,
+		},
+		{
+			name:    "empty",
+			window:  config.MaintenanceWindow{},
+			wantErr: config.ErrInvalidMaintenanceWindow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, tt.window.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestParse_MaintenanceWindows(t *testing.T) {
+	tests := []struct {
+		name       string
+		filename   string
+		contents   string
+		wantErrors []string
+	}{
+		{
+			name:     "yaml timestamps",
+			filename: "sites.yaml",
+			contents: "- url: https://example.org\n  maintenance:\n    - start: 2026-10-18T10:00:00Z\n      end: 2026-10-18T14:00:00+02:00\n      mode: skip\n    - cron: '0 2 * * SUN'\n      duration_seconds: 3600\n      time_zone: Europe/Berlin\n",
+		},
+		{
+			name:     "toml datetimes",
+			filename: "sites.toml",
+			contents: "[[sites]]\nurl = \"https://example.org\"\n[[sites.maintenance]]\nstart = 2026-10-18T10:00:00Z\nend = 2026-10-18T13:00:00+02:00\n",
+		},
+		{
+			name:     "invalid",
+			filename: "sites.yaml",
+			contents: "- url: https://example.org\n  maintenance:\n    - start: 2026-10-18T12:00:00Z\n      end: 2026-10-18T10:00:00Z\n    - cron: '61 * * * *'\n      duration_seconds: 60\n      time_zone: Mars/Olympus\n",
+			wantErrors: []string{
+				"4: site 0: maintenance[0].end: the window ends before it starts",
+				`5: site 0: maintenance[1].cron: parsing cron expression "61 * * * *": end of range (61) above maximum (59): 61`,
+				"7: site 0: maintenance[1].time_zone: loading time zone: unknown time zone Mars/Olympus",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.filename)
+			require.NoError(t, os.WriteFile(filename, []byte(tt.contents), 0o600))
+
+			sites, err := config.Parse(filename)
+			if len(tt.wantErrors) > 0 {
+				var validationErrors config.ValidationErrors
+				require.ErrorAs(t, err, &validationErrors)
+
+				messages := []string{}
+				for _, validationError := range validationErrors {
+					messages = append(messages, validationError.Error())
+				}
+
+				for i, want := range tt.wantErrors {
+					tt.wantErrors[i] = filename + ":" + want
+				}
+
+				assert.Equal(t, tt.wantErrors, messages)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, sites, 1)
+			require.NotEmpty(t, sites[0].Maintenance)
+			assert.True(t, sites[0].Maintenance[0].Active(mustParseTime(t, "2026-10-18T10:30:00Z")))
+			assert.NoError(t, sites[0].Maintenance[0].Validate())
+		})
+	}
+}
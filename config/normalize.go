@@ -0,0 +1,374 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DuplicatePolicy says what to do when the same site is in the list more than once.
+type DuplicatePolicy string
+
+const (
+	DuplicatePolicyFirst DuplicatePolicy = "first" // Keep the first one. It's the default.
+	DuplicatePolicyLast  DuplicatePolicy = "last"  // Keep the last one, eg. for a list where later rows override earlier ones.
+	DuplicatePolicyError DuplicatePolicy = "error" // Don't start at all.
+)
+
+var (
+	// ErrUnknownDuplicatePolicy is returned when a duplicate policy is not one of the known ones.
+	ErrUnknownDuplicatePolicy = errors.New("unknown duplicate policy")
+	// ErrUnreachableScheme is found when a URL has a scheme that the check can't use, eg. ftp:// for an HTTP check.
+	ErrUnreachableScheme = errors.New("unreachable scheme")
+	// ErrMissingHost is found when a URL has no host.
+	ErrMissingHost = errors.New("missing host")
+)
+
+// UnmarshalText validates the policy, so a typo fails at parse time.
+func (d *DuplicatePolicy) UnmarshalText(text []byte) error {
+	switch policy := DuplicatePolicy(text); policy {
+	case "":
+		*d = DuplicatePolicyFirst
+	case DuplicatePolicyFirst, DuplicatePolicyLast, DuplicatePolicyError:
+		*d = policy
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownDuplicatePolicy, policy)
+	}
+
+	return nil
+}
+
+// String returns the policy, so it can be used as a flag.
+func (d DuplicatePolicy) String() string {
+	return string(d)
+}
+
+// Set parses the policy, so it can be used as a flag.
+func (d *DuplicatePolicy) Set(value string) error {
+	return d.UnmarshalText([]byte(value))
+}
+
+// Severity is how bad a finding is. Warnings are fixed by [Normalize] itself, errors are not.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is a problem with a site that is valid, but probably not what was meant, eg. a duplicate or an interval that is too short.
+type Finding struct {
+	Index    int    // Index of the site in the list, before removing duplicates.
+	Site     string // The site, as [SiteElement.String] shows it.
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: site %d (%v): %v", f.Severity, f.Index, f.Site, f.Message)
+}
+
+// Findings are all the findings of a site list. It's an error, so the ones with [SeverityError] can be returned as one.
+type Findings []Finding
+
+func (f Findings) Error() string {
+	lines := make([]string, 0, len(f))
+	for _, finding := range f {
+		lines = append(lines, finding.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Errors returns only the findings that are errors.
+func (f Findings) Errors() Findings {
+	errs := Findings{}
+
+	for _, finding := range f {
+		if finding.Severity == SeverityError {
+			errs = append(errs, finding)
+		}
+	}
+
+	return errs
+}
+
+// schemes are the URL schemes that each check type can reach. gRPC targets are not URLs (eg. localhost:50051 or dns:///service:443), so they are not checked.
+var schemes = map[CheckType][]string{
+	"":                 {"http", "https"},
+	CheckTypeHTTP:      {"http", "https"},
+	CheckTypeWebSocket: {"ws", "wss"},
+}
+
+// defaultPorts are dropped when canonicalizing, so https://example.org:443 and https://example.org are the same site.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// canonicalize returns the canonical form of a URL: lowercase scheme and host, no default port and no fragment.
+// It also returns the key to find duplicates, which also treats an empty path as /. The URL itself keeps the empty path, so it's still stored as it was written.
+// It returns an error if the scheme can't be reached by the check type.
+func canonicalize(checkType CheckType, rawURL string) (string, string, error) {
+	allowed, ok := schemes[checkType]
+	if !ok {
+		return rawURL, rawURL, nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing url: %w", err)
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+
+	if !slices.Contains(allowed, parsed.Scheme) {
+		return "", "", fmt.Errorf("%w: %q can't be checked by a %v check, it must be one of %v", ErrUnreachableScheme, parsed.Scheme, orHTTP(checkType), strings.Join(allowed, ", "))
+	}
+
+	if parsed.Hostname() == "" {
+		return "", "", ErrMissingHost
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if port := parsed.Port(); port != "" && port != defaultPorts[parsed.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") { // IPv6
+		host = "[" + host + "]"
+	}
+
+	parsed.Host = host
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	canonical := parsed.String()
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	return canonical, parsed.String(), nil
+}
+
+// orHTTP returns the check type, with the empty one shown as http.
+func orHTTP(checkType CheckType) CheckType {
+	if checkType == "" {
+		return CheckTypeHTTP
+	}
+
+	return checkType
+}
+
+// Normalize canonicalizes the URLs, clamps the intervals within the limits, removes duplicates according to the policy and resolves the dependencies, and returns what it found.
+// Sites with errors (eg. a URL that can't be reached or a dependency cycle) are kept, so the caller decides what to do with them; [Findings.Errors] returns them.
+//
+// Two sites are duplicates if they have the same check type and the same canonical URL.
+func Normalize(sites []SiteElement, policy DuplicatePolicy, limits Limits) ([]SiteElement, Findings) {
+	findings := Findings{}
+	normalized := make([]SiteElement, 0, len(sites))
+	origins := make([]int, 0, len(sites)) // index in sites of every normalized site, for the findings of the dependencies
+	references := references{}
+
+	type key struct {
+		checkType CheckType
+		url       string
+	}
+
+	seen := map[key]int{} // index in normalized
+
+	for i, site := range sites {
+		written := site.String() // findings show the site as it was written, so it can be found in the list
+
+		finding := func(severity Severity, format string, args ...any) {
+			findings = append(findings, Finding{Index: i, Site: written, Severity: severity, Message: site.Redact(fmt.Sprintf(format, args...))})
+		}
+
+		canonical, duplicateKey, err := canonicalize(site.Type, site.URL)
+		if err != nil {
+			finding(SeverityError, "%v", err)
+
+			duplicateKey = site.URL
+		} else {
+			site.URL = canonical
+		}
+
+		clamp := func(prefix string, seconds int) int {
+			switch clamped := limits.Clamp(seconds); {
+			case clamped > seconds:
+				finding(SeverityWarning, "%sinterval of %ds is below the minimum, it's checked every %ds", prefix, seconds, clamped)
+
+				return clamped
+			case clamped < seconds:
+				finding(SeverityWarning, "%sinterval of %ds is above the maximum, it's checked every %ds", prefix, seconds, clamped)
+
+				return clamped
+			default:
+				return seconds
+			}
+		}
+
+		if site.Schedule == nil || site.IntervalSeconds != 0 { // a site with a schedule doesn't need an interval
+			site.IntervalSeconds = clamp("", site.IntervalSeconds)
+		}
+
+		if len(site.Intervals) > 0 {
+			site.Intervals = slices.Clone(site.Intervals) // the list is shared with the site that was passed to Normalize
+			for j := range site.Intervals {
+				site.Intervals[j].IntervalSeconds = clamp(fmt.Sprintf("intervals[%d]: ", j), site.Intervals[j].IntervalSeconds)
+			}
+		}
+
+		for j, objective := range site.SLOs {
+			if objective.Scope == SLOScopeGroup && site.Group == "" {
+				finding(SeverityError, "slos[%d]: a group SLO needs the site to be in a group", j)
+			}
+		}
+
+		if flapping := site.Flapping.WithDefaults(); flapping.StopPercent >= flapping.StartPercent {
+			finding(SeverityError, "flapping: stop_percent (%v) must be lower than start_percent (%v)", flapping.StopPercent, flapping.StartPercent)
+		}
+
+		siteKey := key{checkType: orHTTP(site.Type), url: duplicateKey}
+
+		first, duplicate := seen[siteKey]
+		if !duplicate {
+			seen[siteKey] = len(normalized)
+			references.add(len(normalized), sites[i].URL, duplicateKey)
+			origins = append(origins, i)
+			normalized = append(normalized, site)
+
+			continue
+		}
+
+		references.add(first, sites[i].URL)
+
+		switch policy {
+		case DuplicatePolicyError:
+			finding(SeverityError, "duplicate of site %d", origins[first])
+		case DuplicatePolicyLast:
+			finding(SeverityWarning, "duplicate of site %d, which is replaced by this one", origins[first])
+			normalized[first] = site
+			origins[first] = i
+		default:
+			finding(SeverityWarning, "duplicate of site %d, so it's skipped", origins[first])
+		}
+	}
+
+	findings = append(findings, resolveDependencies(normalized, origins, references)...)
+
+	return normalized, findings
+}
+
+// references finds sites by URL, as written or canonical, for [SiteElement.DependsOn].
+type references map[string]int
+
+// add adds the URLs of the site at index. A URL that is already there is kept, so the first site wins like it does for duplicates.
+func (r references) add(index int, urls ...string) {
+	for _, url := range urls {
+		if _, ok := r[url]; !ok {
+			r[url] = index
+		}
+	}
+}
+
+// find returns the index of the site a URL references. The URL may be written in any way the site could, eg. with a default port.
+func (r references) find(url string) (int, bool) {
+	if index, ok := r[url]; ok {
+		return index, true
+	}
+
+	for _, checkType := range []CheckType{CheckTypeHTTP, CheckTypeWebSocket} {
+		_, duplicateKey, err := canonicalize(checkType, url)
+		if err == nil {
+			index, ok := r[duplicateKey]
+
+			return index, ok
+		}
+	}
+
+	return 0, false
+}
+
+// resolveDependencies replaces every dependency with the URL of the site it references, and finds the ones that can't be found or that form a cycle.
+func resolveDependencies(sites []SiteElement, origins []int, references references) Findings {
+	findings := Findings{}
+	dependencies := make([][]int, len(sites))
+
+	for i := range sites {
+		site := &sites[i]
+		if len(site.DependsOn) == 0 {
+			continue
+		}
+
+		site.DependsOn = slices.Clone(site.DependsOn) // the list is shared with the site that was passed to Normalize
+
+		for j, dependency := range site.DependsOn {
+			index, ok := references.find(dependency)
+			if !ok {
+				findings = append(findings, Finding{Index: origins[i], Site: site.String(), Severity: SeverityError, Message: site.Redact(fmt.Sprintf("depends on %q, which is not in the site list", dependency))})
+
+				continue
+			}
+
+			site.DependsOn[j] = sites[index].URL
+			dependencies[i] = append(dependencies[i], index)
+		}
+	}
+
+	for _, cycle := range cycles(dependencies) {
+		path := make([]string, 0, len(cycle))
+		for _, index := range cycle {
+			path = append(path, sites[index].String())
+		}
+
+		findings = append(findings, Finding{Index: origins[cycle[0]], Site: sites[cycle[0]].String(), Severity: SeverityError, Message: "dependency cycle: " + strings.Join(path, " -> ")})
+	}
+
+	return findings
+}
+
+// cycles returns the cycles of a graph, given as the edges of every node. Every cycle starts and ends with the same node, and it's only returned once.
+func cycles(edges [][]int) [][]int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	found := [][]int{}
+	states := make([]int, len(edges))
+	path := []int{}
+
+	var visit func(node int)
+
+	visit = func(node int) {
+		states[node] = visiting
+		path = append(path, node)
+
+		for _, next := range edges[node] {
+			switch states[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				start := slices.Index(path, next)
+				found = append(found, append(slices.Clone(path[start:]), next))
+			}
+		}
+
+		path = path[:len(path)-1]
+		states[node] = visited
+	}
+
+	for node := range edges {
+		if states[node] == unvisited {
+			visit(node)
+		}
+	}
+
+	return found
+}
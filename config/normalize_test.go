@@ -0,0 +1,258 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+func TestNormalize(t *testing.T) {
+	hourly, err := config.ParseCron("@hourly")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		sites        []config.SiteElement
+		policy       config.DuplicatePolicy
+		limits       *config.Limits // defaults to config.DefaultLimits
+		want         []config.SiteElement
+		wantFindings config.Findings
+	}{
+		{
+			name: "canonical urls",
+			sites: []config.SiteElement{
+				{URL: "HTTPS://Example.ORG:443/Path?q=1#top", IntervalSeconds: 60},
+				{URL: "http://[::1]:80", IntervalSeconds: 60},
+				{URL: "wss://example.org:8443/socket", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "localhost:50051", Type: config.CheckTypeGRPC, IntervalSeconds: 60},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org/Path?q=1", IntervalSeconds: 60},
+				{URL: "http://[::1]", IntervalSeconds: 60},
+				{URL: "wss://example.org:8443/socket", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "localhost:50051", Type: config.CheckTypeGRPC, IntervalSeconds: 60},
+			},
+			wantFindings: config.Findings{},
+		},
+		{
+			name: "unreachable schemes",
+			sites: []config.SiteElement{
+				{URL: "ftp://example.org", IntervalSeconds: 60},
+				{URL: "https://example.org", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "example.org", IntervalSeconds: 60},
+				{URL: "https:///path", IntervalSeconds: 60},
+			},
+			want: []config.SiteElement{
+				{URL: "ftp://example.org", IntervalSeconds: 60},
+				{URL: "https://example.org", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "example.org", IntervalSeconds: 60},
+				{URL: "https:///path", IntervalSeconds: 60},
+			},
+			wantFindings: config.Findings{
+				{Index: 0, Site: "ftp://example.org", Severity: config.SeverityError, Message: `unreachable scheme: "ftp" can't be checked by a http check, it must be one of http, https`},
+				{Index: 1, Site: "https://example.org", Severity: config.SeverityError, Message: `unreachable scheme: "https" can't be checked by a websocket check, it must be one of ws, wss`},
+				{Index: 2, Site: "example.org", Severity: config.SeverityError, Message: `unreachable scheme: "" can't be checked by a http check, it must be one of http, https`},
+				{Index: 3, Site: "https:///path", Severity: config.SeverityError, Message: "missing host"},
+			},
+		},
+		{
+			name: "intervals",
+			sites: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 1},
+				{URL: "https://b.example", IntervalSeconds: 3600},
+				{URL: "https://c.example"},
+			},
+			want: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 5},
+				{URL: "https://b.example", IntervalSeconds: 300},
+				{URL: "https://c.example", IntervalSeconds: 5},
+			},
+			wantFindings: config.Findings{
+				{Index: 0, Site: "https://a.example", Severity: config.SeverityWarning, Message: "interval of 1s is below the minimum, it's checked every 5s"},
+				{Index: 1, Site: "https://b.example", Severity: config.SeverityWarning, Message: "interval of 3600s is above the maximum, it's checked every 300s"},
+				{Index: 2, Site: "https://c.example", Severity: config.SeverityWarning, Message: "interval of 0s is below the minimum, it's checked every 5s"},
+			},
+		},
+		{
+			name:   "configured limits",
+			limits: &config.Limits{MinIntervalSeconds: 1, MaxIntervalSeconds: 3600},
+			sites: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 1},
+				{URL: "https://b.example", IntervalSeconds: 7200},
+			},
+			want: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 1},
+				{URL: "https://b.example", IntervalSeconds: 3600},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://b.example", Severity: config.SeverityWarning, Message: "interval of 7200s is above the maximum, it's checked every 3600s"},
+			},
+		},
+		{
+			name: "schedules and interval windows",
+			sites: []config.SiteElement{
+				{URL: "https://a.example", Schedule: hourly},
+				{URL: "https://b.example", IntervalSeconds: 60, Intervals: []config.IntervalWindow{{From: 9 * 60, To: 18 * 60, IntervalSeconds: 1}}},
+			},
+			want: []config.SiteElement{
+				{URL: "https://a.example", Schedule: hourly},
+				{URL: "https://b.example", IntervalSeconds: 60, Intervals: []config.IntervalWindow{{From: 9 * 60, To: 18 * 60, IntervalSeconds: 5}}},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://b.example", Severity: config.SeverityWarning, Message: "intervals[0]: interval of 1s is below the minimum, it's checked every 5s"},
+			},
+		},
+		{
+			name:   "duplicates keep the first",
+			policy: config.DuplicatePolicyFirst,
+			sites: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 10},
+				{URL: "https://EXAMPLE.org/", IntervalSeconds: 20},
+				{URL: "wss://example.org", Type: config.CheckTypeWebSocket, IntervalSeconds: 30},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 10},
+				{URL: "wss://example.org", Type: config.CheckTypeWebSocket, IntervalSeconds: 30},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://EXAMPLE.org/", Severity: config.SeverityWarning, Message: "duplicate of site 0, so it's skipped"},
+			},
+		},
+		{
+			name:   "duplicates keep the last",
+			policy: config.DuplicatePolicyLast,
+			sites: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 10},
+				{URL: "https://example.org", Type: config.CheckTypeHTTP, IntervalSeconds: 20},
+				{URL: "https://example.org:443", IntervalSeconds: 30},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 30},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://example.org", Severity: config.SeverityWarning, Message: "duplicate of site 0, which is replaced by this one"},
+				{Index: 2, Site: "https://example.org:443", Severity: config.SeverityWarning, Message: "duplicate of site 1, which is replaced by this one"},
+			},
+		},
+		{
+			name:   "duplicates are errors",
+			policy: config.DuplicatePolicyError,
+			sites: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 10},
+				{URL: "https://example.org", IntervalSeconds: 20},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 10},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://example.org", Severity: config.SeverityError, Message: "duplicate of site 0"},
+			},
+		},
+		{
+			name: "dependencies",
+			sites: []config.SiteElement{
+				{URL: "https://cdn.example.org", IntervalSeconds: 60},
+				{URL: "https://shop.example.org", IntervalSeconds: 60, DependsOn: []string{"HTTPS://CDN.example.org:443/", "wss://gateway.example.org"}},
+				{URL: "wss://gateway.example.org/", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "https://blog.example.org", IntervalSeconds: 60, DependsOn: []string{"https://nope.example.org"}},
+			},
+			want: []config.SiteElement{
+				{URL: "https://cdn.example.org", IntervalSeconds: 60},
+				{URL: "https://shop.example.org", IntervalSeconds: 60, DependsOn: []string{"https://cdn.example.org", "wss://gateway.example.org/"}},
+				{URL: "wss://gateway.example.org/", Type: config.CheckTypeWebSocket, IntervalSeconds: 60},
+				{URL: "https://blog.example.org", IntervalSeconds: 60, DependsOn: []string{"https://nope.example.org"}},
+			},
+			wantFindings: config.Findings{
+				{Index: 3, Site: "https://blog.example.org", Severity: config.SeverityError, Message: `depends on "https://nope.example.org", which is not in the site list`},
+			},
+		},
+		{
+			name: "dependency cycles",
+			sites: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 60, DependsOn: []string{"https://b.example"}},
+				{URL: "https://b.example", IntervalSeconds: 60, DependsOn: []string{"https://c.example"}},
+				{URL: "https://c.example", IntervalSeconds: 60, DependsOn: []string{"https://a.example"}},
+				{URL: "https://d.example", IntervalSeconds: 60, DependsOn: []string{"https://d.example"}},
+				{URL: "https://e.example", IntervalSeconds: 60, DependsOn: []string{"https://a.example"}},
+			},
+			want: []config.SiteElement{
+				{URL: "https://a.example", IntervalSeconds: 60, DependsOn: []string{"https://b.example"}},
+				{URL: "https://b.example", IntervalSeconds: 60, DependsOn: []string{"https://c.example"}},
+				{URL: "https://c.example", IntervalSeconds: 60, DependsOn: []string{"https://a.example"}},
+				{URL: "https://d.example", IntervalSeconds: 60, DependsOn: []string{"https://d.example"}},
+				{URL: "https://e.example", IntervalSeconds: 60, DependsOn: []string{"https://a.example"}},
+			},
+			wantFindings: config.Findings{
+				{Index: 0, Site: "https://a.example", Severity: config.SeverityError, Message: "dependency cycle: https://a.example -> https://b.example -> https://c.example -> https://a.example"},
+				{Index: 3, Site: "https://d.example", Severity: config.SeverityError, Message: "dependency cycle: https://d.example -> https://d.example"},
+			},
+		},
+		{
+			name: "group slos need a group",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 60, Group: "website", SLOs: []config.SLO{{TargetPercent: 99.9, Scope: config.SLOScopeGroup}}},
+				{URL: "https://example.com", IntervalSeconds: 60, SLOs: []config.SLO{{TargetPercent: 99.9}, {TargetPercent: 99.9, Scope: config.SLOScopeGroup}}},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 60, Group: "website", SLOs: []config.SLO{{TargetPercent: 99.9, Scope: config.SLOScopeGroup}}},
+				{URL: "https://example.com", IntervalSeconds: 60, SLOs: []config.SLO{{TargetPercent: 99.9}, {TargetPercent: 99.9, Scope: config.SLOScopeGroup}}},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://example.com", Severity: config.SeverityError, Message: "slos[1]: a group SLO needs the site to be in a group"},
+			},
+		},
+		{
+			name: "flapping stops below where it starts",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 60, Flapping: config.FlapPolicy{StartPercent: 40, StopPercent: 20}},
+				{URL: "https://example.com", IntervalSeconds: 60, Flapping: config.FlapPolicy{StartPercent: 20}},
+			},
+			want: []config.SiteElement{
+				{URL: "https://example.org", IntervalSeconds: 60, Flapping: config.FlapPolicy{StartPercent: 40, StopPercent: 20}},
+				{URL: "https://example.com", IntervalSeconds: 60, Flapping: config.FlapPolicy{StartPercent: 20}},
+			},
+			wantFindings: config.Findings{
+				{Index: 1, Site: "https://example.com", Severity: config.SeverityError, Message: "flapping: stop_percent (25) must be lower than start_percent (20)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := config.DefaultLimits
+			if tt.limits != nil {
+				limits = *tt.limits
+			}
+
+			got, findings := config.Normalize(tt.sites, tt.policy, limits)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantFindings, findings)
+		})
+	}
+}
+
+func TestDuplicatePolicy_UnmarshalText(t *testing.T) {
+	var policy config.DuplicatePolicy
+
+	assert.NoError(t, policy.UnmarshalText([]byte("")))
+	assert.Equal(t, config.DuplicatePolicyFirst, policy)
+
+	assert.NoError(t, policy.UnmarshalText([]byte("last")))
+	assert.Equal(t, config.DuplicatePolicyLast, policy)
+
+	assert.ErrorIs(t, policy.UnmarshalText([]byte("newest")), config.ErrUnknownDuplicatePolicy)
+}
+
+func TestSLOScope_UnmarshalText(t *testing.T) {
+	var scope config.SLOScope
+
+	assert.NoError(t, scope.UnmarshalText([]byte("")))
+	assert.Equal(t, config.SLOScopeSite, scope)
+
+	assert.NoError(t, scope.UnmarshalText([]byte("group")))
+	assert.Equal(t, config.SLOScopeGroup, scope)
+
+	assert.ErrorIs(t, scope.UnmarshalText([]byte("team")), config.ErrUnknownSLOScope)
+}
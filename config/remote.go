@@ -0,0 +1,257 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultMaxConfigBytes is how big a remote site list may be if no limit is set.
+const DefaultMaxConfigBytes = 10 << 20 // 10 MiB
+
+var (
+	// ErrNotModified is returned by [Remote.Fetch] when the site list did not change since the last fetch.
+	ErrNotModified = errors.New("configuration not modified")
+	// ErrUnexpectedStatus is returned when the server answers with anything but a 2xx status code (or a 304, when we asked for it).
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	// ErrConfigTooLarge is returned when a remote site list is bigger than the limit.
+	ErrConfigTooLarge = errors.New("configuration too large")
+)
+
+// cachedConfig is what the cache file keeps: the last site list that was successfully parsed, and what we need to ask the server whether it changed.
+type cachedConfig struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	Format       Format `json:"format"`
+	Body         string `json:"body"`
+	Signature    []byte `json:"signature"` // Only set if it was verified.
+}
+
+// Remote fetches a remote site list. It remembers the ETag and Last-Modified of the last good response, so refreshing a site list that did not change is cheap.
+//
+// If a cache path is set, the last good site list is also written there. When the first fetch fails (eg. the server is down while we start), the cached one is used instead, so the monitor can still start.
+//
+// If a public key is set, every site list must have a valid ed25519 detached signature at the same URL plus .sig (eg. sites.json.sig), either raw or in base64.
+// The signature is checked before parsing, and the cached site list is checked again when it's read.
+type Remote struct {
+	client    *http.Client
+	url       string
+	cachePath string
+	maxBytes  int64
+	publicKey ed25519.PublicKey
+
+	mut        *sync.Mutex
+	last       *cachedConfig // the last good response, or the cached one before the first fetch
+	cacheRead  bool
+	downloaded bool // whether a site list was already returned, so ErrNotModified makes sense
+}
+
+// NewRemote creates a new Remote. An empty cache path disables the cache, a max size of zero or less uses [DefaultMaxConfigBytes], and a nil public key disables signature verification.
+func NewRemote(client *http.Client, url, cachePath string, maxBytes int64, publicKey ed25519.PublicKey) *Remote {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxConfigBytes
+	}
+
+	return &Remote{
+		client:    client,
+		url:       url,
+		cachePath: cachePath,
+		maxBytes:  maxBytes,
+		publicKey: publicKey,
+		mut:       &sync.Mutex{},
+	}
+}
+
+// Fetch downloads and parses the site list. It returns [ErrNotModified] if the server says that it did not change since the last call.
+//
+// If the first call fails and there is a cached site list, the cached one is returned instead and the error is only logged.
+func (r *Remote) Fetch(ctx context.Context) ([]SiteElement, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if !r.cacheRead {
+		r.cacheRead = true
+		r.last = r.readCache()
+	}
+
+	siteConfiguration, err := r.fetch(ctx)
+	if err == nil || r.downloaded || r.last == nil {
+		r.downloaded = r.downloaded || err == nil
+
+		return siteConfiguration, err
+	}
+
+	slog.WarnContext(ctx, "Failed fetching configuration, using the cached one.", slog.String("url", r.url), slog.String("cache", r.cachePath), slog.String("error", err.Error()))
+
+	siteConfiguration, cacheErr := parse([]byte(r.last.Body), r.last.Format, r.cachePath)
+	if cacheErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("parsing cached configuration: %w", cacheErr))
+	}
+
+	r.downloaded = true
+
+	return siteConfiguration, nil
+}
+
+func (r *Remote) fetch(ctx context.Context) ([]SiteElement, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if r.last != nil {
+		if r.last.ETag != "" {
+			req.Header.Set("If-None-Match", r.last.ETag)
+		}
+
+		if r.last.LastModified != "" {
+			req.Header.Set("If-Modified-Since", r.last.LastModified)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && r.last != nil:
+		if r.downloaded {
+			return nil, ErrNotModified
+		}
+
+		// It's the cached one, which we didn't parse yet.
+		return parse([]byte(r.last.Body), r.last.Format, r.url)
+	case resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices:
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedStatus, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, r.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	if int64(len(body)) > r.maxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrConfigTooLarge, r.maxBytes)
+	}
+
+	var signature []byte
+
+	if r.publicKey != nil {
+		signature, err = fetchSignature(ctx, r.client, r.url)
+		if err != nil {
+			return nil, err
+		}
+
+		err = verify(r.publicKey, body, signature)
+		if err != nil {
+			return nil, fmt.Errorf("verifying %v: %w", r.url, err)
+		}
+	}
+
+	format, ok := FormatFromContentType(resp.Header.Get("Content-Type"))
+	if !ok {
+		format = FormatFromName(req.URL.Path)
+	}
+
+	siteConfiguration, err := parse(body, format, r.url)
+	if err != nil {
+		return nil, err
+	}
+
+	r.last = &cachedConfig{
+		URL:          r.url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Format:       format,
+		Body:         string(body),
+		Signature:    signature,
+	}
+
+	r.writeCache(ctx)
+
+	slog.DebugContext(ctx, "Configuration successfully read.", slog.String("format", string(format)))
+
+	return siteConfiguration, nil
+}
+
+// readCache reads the cached site list. It returns nil if there is none, or if it is from another URL.
+// If signatures are verified, the cached one is verified again too, as the cache file could have been changed (or written before there was a key).
+func (r *Remote) readCache() *cachedConfig {
+	if r.cachePath == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(r.cachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed reading the configuration cache.", slog.String("cache", r.cachePath), slog.String("error", err.Error()))
+		}
+
+		return nil
+	}
+
+	cached := &cachedConfig{}
+
+	err = json.Unmarshal(contents, cached)
+	if err != nil || cached.URL != r.url {
+		return nil
+	}
+
+	if r.publicKey != nil {
+		err = verify(r.publicKey, []byte(cached.Body), cached.Signature)
+		if err != nil {
+			slog.Warn("Ignoring the configuration cache.", slog.String("cache", r.cachePath), slog.String("error", err.Error()))
+
+			return nil
+		}
+	}
+
+	return cached
+}
+
+// writeCache writes the last good site list to the cache. It only logs errors, as the site list itself is fine.
+func (r *Remote) writeCache(ctx context.Context) {
+	if r.cachePath == "" {
+		return
+	}
+
+	contents, err := json.Marshal(r.last)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed encoding the configuration cache.", slog.String("error", err.Error()))
+
+		return
+	}
+
+	// Written to a temporary file first, so a crash while writing does not leave a broken cache behind.
+	temporary, err := os.CreateTemp(filepath.Dir(r.cachePath), filepath.Base(r.cachePath)+".*")
+	if err != nil {
+		slog.WarnContext(ctx, "Failed writing the configuration cache.", slog.String("cache", r.cachePath), slog.String("error", err.Error()))
+
+		return
+	}
+	defer os.Remove(temporary.Name()) //nolint:errcheck // It's already renamed if everything went well.
+
+	_, err = temporary.Write(contents)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temporary.Name(), r.cachePath)
+	}
+
+	if err != nil {
+		slog.WarnContext(ctx, "Failed writing the configuration cache.", slog.String("cache", r.cachePath), slog.String("error", err.Error()))
+	}
+}
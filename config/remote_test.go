@@ -0,0 +1,166 @@
+package config_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+const (
+	remoteSites   = `[{"url": "https://duckduckgo.com", "regexp": "duck", "interval_seconds": 5}]`
+	remoteETag    = `"v1"`
+	remoteModTime = "Sun, 18 Oct 2026 10:00:00 GMT"
+)
+
+// newRemoteServer serves a site list with an ETag and a Last-Modified, answering conditional requests like a static file server.
+// The status can be changed to simulate a server that is down.
+func newRemoteServer(t *testing.T, status *atomic.Int64) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == remoteETag || r.Header.Get("If-Modified-Since") == remoteModTime {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", remoteETag)
+		w.Header().Set("Last-Modified", remoteModTime)
+		w.Write([]byte(remoteSites)) //nolint:errcheck // The test fails anyway if the write fails.
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRemote_Fetch(t *testing.T) {
+	want := []config.SiteElement{{URL: "https://duckduckgo.com", Regexp: regexp.MustCompile("duck"), IntervalSeconds: 5}}
+
+	tests := []struct {
+		name     string
+		statuses []int64 // status of the server for every fetch
+		cache    bool
+		maxBytes int64
+		wantErrs []error // nil means the site list is returned
+	}{
+		{
+			name:     "not modified",
+			statuses: []int64{http.StatusOK, http.StatusOK},
+			wantErrs: []error{nil, config.ErrNotModified},
+		},
+		{
+			name:     "server error",
+			statuses: []int64{http.StatusInternalServerError},
+			wantErrs: []error{config.ErrUnexpectedStatus},
+		},
+		{
+			name:     "server error after a good fetch",
+			statuses: []int64{http.StatusOK, http.StatusBadGateway},
+			cache:    true,
+			wantErrs: []error{nil, config.ErrUnexpectedStatus},
+		},
+		{
+			name:     "too large",
+			statuses: []int64{http.StatusOK},
+			maxBytes: 10,
+			wantErrs: []error{config.ErrConfigTooLarge},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &atomic.Int64{}
+			server := newRemoteServer(t, status)
+
+			cachePath := ""
+			if tt.cache {
+				cachePath = filepath.Join(t.TempDir(), "cache.json")
+			}
+
+			remote := config.NewRemote(server.Client(), server.URL, cachePath, tt.maxBytes, nil)
+
+			for i, wantErr := range tt.wantErrs {
+				status.Store(tt.statuses[i])
+
+				got, err := remote.Fetch(context.Background())
+				if wantErr != nil {
+					require.ErrorIs(t, err, wantErr)
+					assert.Nil(t, got)
+
+					continue
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func TestRemote_Fetch_Cache(t *testing.T) {
+	want := []config.SiteElement{{URL: "https://duckduckgo.com", Regexp: regexp.MustCompile("duck"), IntervalSeconds: 5}}
+
+	tests := []struct {
+		name    string
+		status  int64 // status of the server when starting again
+		url     string
+		wantErr bool
+	}{
+		{
+			name:   "server is down",
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "not modified",
+			status: http.StatusOK,
+		},
+		{
+			name:    "cache of another url",
+			status:  http.StatusServiceUnavailable,
+			url:     "/other",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &atomic.Int64{}
+			status.Store(http.StatusOK)
+
+			server := newRemoteServer(t, status)
+			cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+			_, err := config.NewRemote(server.Client(), server.URL, cachePath, 0, nil).Fetch(context.Background())
+			require.NoError(t, err)
+
+			cached, err := os.ReadFile(cachePath)
+			require.NoError(t, err)
+			assert.Contains(t, string(cached), `"etag":"\"v1\""`)
+
+			// Starting again, with a new Remote.
+			status.Store(tt.status)
+
+			got, err := config.NewRemote(server.Client(), server.URL+tt.url, cachePath, 0, nil).Fetch(context.Background())
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+
+			if !tt.wantErr {
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// DefaultMinIntervalSeconds is the shortest interval a site can be checked at, unless the limits say otherwise. Shorter ones are clamped, as nobody needs a check every second and the site probably doesn't want it either.
+	DefaultMinIntervalSeconds = 5
+	// DefaultMaxIntervalSeconds is the longest interval a site can be checked at, unless the limits say otherwise.
+	DefaultMaxIntervalSeconds = 300
+)
+
+// ErrInvalidLimits is returned when the minimum interval is not positive or it's above the maximum.
+var ErrInvalidLimits = errors.New("invalid interval limits")
+
+// Limits are the shortest and the longest intervals a site can be checked at. Cron schedules are not limited, as they are set on purpose.
+type Limits struct {
+	MinIntervalSeconds int
+	MaxIntervalSeconds int
+}
+
+// DefaultLimits are the limits if nothing else is configured.
+var DefaultLimits = Limits{MinIntervalSeconds: DefaultMinIntervalSeconds, MaxIntervalSeconds: DefaultMaxIntervalSeconds}
+
+// Validate checks that the limits make sense.
+func (l Limits) Validate() error {
+	if l.MinIntervalSeconds <= 0 || l.MinIntervalSeconds > l.MaxIntervalSeconds {
+		return fmt.Errorf("%w: the minimum (%ds) must be positive and not above the maximum (%ds)", ErrInvalidLimits, l.MinIntervalSeconds, l.MaxIntervalSeconds)
+	}
+
+	return nil
+}
+
+// Clamp returns the interval within the limits.
+func (l Limits) Clamp(seconds int) int {
+	return min(max(seconds, l.MinIntervalSeconds), l.MaxIntervalSeconds)
+}
+
+// Cron is a cron expression with five fields (minute, hour, day of month, month and day of week), like 0 2 * * SUN, or a descriptor like @daily.
+// It's a type of its own so it's parsed once, like a regexp.
+type Cron struct {
+	expression string
+	schedule   cron.Schedule
+}
+
+// ParseCron parses a cron expression. It returns a wrapped error from the cron package if it's not valid.
+func ParseCron(expression string) (*Cron, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cron expression %q: %w", expression, err)
+	}
+
+	return &Cron{expression: expression, schedule: schedule}, nil
+}
+
+// Next returns the first time of the schedule after t, in the location of t.
+func (c *Cron) Next(t time.Time) time.Time {
+	return c.schedule.Next(t)
+}
+
+func (c *Cron) String() string {
+	return c.expression
+}
+
+// UnmarshalText parses the expression.
+func (c *Cron) UnmarshalText(text []byte) error {
+	parsed, err := ParseCron(string(text))
+	if err != nil {
+		return err
+	}
+
+	*c = *parsed
+
+	return nil
+}
+
+// MarshalText returns the expression, as it was written.
+func (c *Cron) MarshalText() ([]byte, error) {
+	return []byte(c.expression), nil
+}
+
+// Location is a time zone, like Europe/Berlin.
+type Location struct {
+	*time.Location
+}
+
+// UnmarshalText loads the time zone. It returns a wrapped error from [time.LoadLocation] if it doesn't exist.
+func (l *Location) UnmarshalText(text []byte) error {
+	location, err := time.LoadLocation(string(text))
+	if err != nil {
+		return fmt.Errorf("loading time zone: %w", err)
+	}
+
+	l.Location = location
+
+	return nil
+}
+
+// MarshalText returns the name of the time zone.
+func (l Location) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ErrInvalidTimeOfDay is returned when a time of day is not like 09:30.
+var ErrInvalidTimeOfDay = errors.New("invalid time of day, it must be like 09:30")
+
+// TimeOfDay is a time within a day, in minutes since midnight. It's written like 09:30.
+type TimeOfDay int
+
+const minutesPerHour = 60
+
+// UnmarshalText parses the time, like 09:30.
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	parsed, err := time.Parse("15:04", string(text))
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidTimeOfDay, text)
+	}
+
+	*t = TimeOfDay(parsed.Hour()*minutesPerHour + parsed.Minute())
+
+	return nil
+}
+
+// MarshalText returns the time, like 09:30.
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "%02d:%02d", int(t)/minutesPerHour, int(t)%minutesPerHour), nil
+}
+
+// ErrUnknownWeekday is returned when a day is not one of mon, tue, wed, thu, fri, sat or sun.
+var ErrUnknownWeekday = errors.New("unknown day of the week")
+
+// weekdays are the names of the days, as they are written in the site list.
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Weekday is a day of the week. It's written as its first three letters, like mon.
+type Weekday time.Weekday
+
+// UnmarshalText parses the day, like mon.
+func (w *Weekday) UnmarshalText(text []byte) error {
+	index := slices.Index(weekdays, strings.ToLower(string(text)))
+	if index < 0 {
+		return fmt.Errorf("%w: %q", ErrUnknownWeekday, text)
+	}
+
+	*w = Weekday(index)
+
+	return nil
+}
+
+// MarshalText returns the day, like mon.
+func (w Weekday) MarshalText() ([]byte, error) {
+	return []byte(weekdays[w]), nil
+}
+
+// IntervalWindow is an interval that is used during part of the week, eg. every 10 seconds during business hours.
+// If From is after To, the window goes over midnight, eg. from 22:00 to 06:00.
+type IntervalWindow struct {
+	Days            []Weekday `json:"days"` // Defaults to every day.
+	From            TimeOfDay `json:"from"`
+	To              TimeOfDay `json:"to"`
+	IntervalSeconds int       `json:"interval_seconds"`
+}
+
+// Active returns whether the window is active at a time, in the location of the time.
+func (w IntervalWindow) Active(at time.Time) bool {
+	if len(w.Days) > 0 && !slices.Contains(w.Days, Weekday(at.Weekday())) {
+		return false
+	}
+
+	minute := TimeOfDay(at.Hour()*minutesPerHour + at.Minute())
+	if w.From <= w.To {
+		return minute >= w.From && minute < w.To
+	}
+
+	return minute >= w.From || minute < w.To
+}
+
+// location returns the time zone of the schedule of the site, UTC by default.
+func (s SiteElement) location() *time.Location {
+	if s.TimeZone != nil {
+		return s.TimeZone.Location
+	}
+
+	return time.UTC
+}
+
+// IntervalAt returns the interval of the site at a time: the one of the first interval window that is active, or IntervalSeconds.
+func (s SiteElement) IntervalAt(at time.Time) time.Duration {
+	at = at.In(s.location())
+
+	for _, window := range s.Intervals {
+		if window.Active(at) {
+			return time.Duration(window.IntervalSeconds) * time.Second
+		}
+	}
+
+	return time.Duration(s.IntervalSeconds) * time.Second
+}
+
+// NextCheck returns when the site is checked next, after a check at a time: the next time of the schedule, if the site has one, or after the interval at that time.
+func (s SiteElement) NextCheck(after time.Time) time.Time {
+	if s.Schedule != nil {
+		return s.Schedule.Next(after.In(s.location()))
+	}
+
+	return after.Add(s.IntervalAt(after))
+}
@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// decodeSite decodes a site from JSON, without validating it.
+func decodeSite(t *testing.T, contents string) config.SiteElement {
+	t.Helper()
+
+	var site config.SiteElement
+	require.NoError(t, json.Unmarshal([]byte(contents), &site))
+
+	return site
+}
+
+func TestSiteElement_NextCheck(t *testing.T) {
+	businessHours := `"intervals": [{"days": ["mon", "tue", "wed", "thu", "fri"], "from": "09:00", "to": "18:00", "interval_seconds": 10}]`
+	nights := `"intervals": [{"from": "22:00", "to": "06:00", "interval_seconds": 300}]`
+
+	tests := []struct {
+		name  string
+		site  string
+		after string
+		want  string
+	}{
+		{
+			name:  "interval",
+			site:  `{"interval_seconds": 60}`,
+			after: "2026-10-19T10:00:00Z",
+			want:  "2026-10-19T10:01:00Z",
+		},
+		{
+			name:  "during business hours",
+			site:  `{"interval_seconds": 60, ` + businessHours + `}`,
+			after: "2026-10-19T10:00:00Z", // a Monday
+			want:  "2026-10-19T10:00:10Z",
+		},
+		{
+			name:  "after business hours",
+			site:  `{"interval_seconds": 60, ` + businessHours + `}`,
+			after: "2026-10-19T18:00:00Z",
+			want:  "2026-10-19T18:01:00Z",
+		},
+		{
+			name:  "weekend",
+			site:  `{"interval_seconds": 60, ` + businessHours + `}`,
+			after: "2026-10-18T10:00:00Z", // a Sunday
+			want:  "2026-10-18T10:01:00Z",
+		},
+		{
+			name:  "business hours in a time zone",
+			site:  `{"interval_seconds": 60, "time_zone": "Europe/Berlin", ` + businessHours + `}`,
+			after: "2026-10-19T07:30:00Z", // 09:30 in Berlin
+			want:  "2026-10-19T07:30:10Z",
+		},
+		{
+			name:  "over midnight",
+			site:  `{"interval_seconds": 60, ` + nights + `}`,
+			after: "2026-10-19T03:00:00Z",
+			want:  "2026-10-19T03:05:00Z",
+		},
+		{
+			name:  "schedule",
+			site:  `{"schedule": "*/15 * * * *", "interval_seconds": 60}`,
+			after: "2026-10-19T10:07:00Z",
+			want:  "2026-10-19T10:15:00Z",
+		},
+		{
+			name:  "schedule in a time zone",
+			site:  `{"schedule": "0 9 * * *", "time_zone": "Europe/Berlin"}`,
+			after: "2026-10-19T10:00:00Z",
+			want:  "2026-10-20T07:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := decodeSite(t, tt.site)
+
+			assert.Equal(t, mustParseTime(t, tt.want), site.NextCheck(mustParseTime(t, tt.after)).UTC())
+		})
+	}
+}
+
+func TestSiteElement_Timeout_Schedule(t *testing.T) {
+	assert.Equal(t, config.DefaultMaxIntervalSeconds*time.Second, decodeSite(t, `{"schedule": "@hourly"}`).Timeout())
+	assert.Equal(t, 30*time.Second, decodeSite(t, `{"schedule": "@hourly", "timeout_seconds": 30}`).Timeout())
+}
+
+func TestLimits(t *testing.T) {
+	require.NoError(t, config.DefaultLimits.Validate())
+	require.ErrorIs(t, config.Limits{MinIntervalSeconds: 0, MaxIntervalSeconds: 10}.Validate(), config.ErrInvalidLimits)
+	require.ErrorIs(t, config.Limits{MinIntervalSeconds: 20, MaxIntervalSeconds: 10}.Validate(), config.ErrInvalidLimits)
+
+	assert.Equal(t, 5, config.DefaultLimits.Clamp(1))
+	assert.Equal(t, 60, config.DefaultLimits.Clamp(60))
+	assert.Equal(t, 300, config.DefaultLimits.Clamp(3600))
+}
+
+func TestIntervalWindow_UnmarshalJSON(t *testing.T) {
+	var window config.IntervalWindow
+
+	require.NoError(t, json.Unmarshal([]byte(`{"days": ["sat", "SUN"], "from": "22:30", "to": "06:00", "interval_seconds": 300}`), &window))
+	assert.Equal(t, config.IntervalWindow{Days: []config.Weekday{config.Weekday(time.Saturday), config.Weekday(time.Sunday)}, From: 22*60 + 30, To: 6 * 60, IntervalSeconds: 300}, window)
+
+	marshaled, err := json.Marshal(window)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"days": ["sat", "sun"], "from": "22:30", "to": "06:00", "interval_seconds": 300}`, string(marshaled))
+
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"from": "25:00"}`), &window), config.ErrInvalidTimeOfDay)
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"days": ["someday"]}`), &window), config.ErrUnknownWeekday)
+}
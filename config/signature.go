@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// signatureSuffix is added to the URL of a site list to get the URL of its detached signature, eg. sites.json.sig.
+const signatureSuffix = ".sig"
+
+// maxSignatureBytes is more than enough for a base64 signature and some whitespace. Anything bigger is not a signature.
+const maxSignatureBytes = 1 << 10
+
+var (
+	// ErrInvalidPublicKey is returned when a public key is not an ed25519 key.
+	ErrInvalidPublicKey = errors.New("invalid ed25519 public key")
+	// ErrMissingSignature is returned when a site list must be signed, but there is no signature for it.
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrInvalidSignature is returned when the signature of a site list does not match it, or is not a signature at all.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// ParsePublicKey parses an ed25519 public key, either as the base64 of its 32 bytes or as a PEM "PUBLIC KEY" block (what openssl pkey -pubout writes).
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+		}
+
+		publicKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: it's a %T", ErrInvalidPublicKey, parsed)
+		}
+
+		return publicKey, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(key))))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPublicKey, err)
+	}
+
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: it has %d bytes instead of %d", ErrInvalidPublicKey, len(decoded), ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(decoded), nil
+}
+
+// decodeSignature accepts a signature either as its raw 64 bytes or in base64, which is easier to publish.
+func decodeSignature(signature []byte) ([]byte, error) {
+	if len(signature) == ed25519.SignatureSize {
+		return signature, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || len(decoded) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: it's neither %d bytes nor their base64", ErrInvalidSignature, ed25519.SignatureSize)
+	}
+
+	return decoded, nil
+}
+
+// verify checks the detached signature of a site list.
+func verify(publicKey ed25519.PublicKey, body, signature []byte) error {
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+
+	if !ed25519.Verify(publicKey, body, signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signatureURL returns where the detached signature of a site list is, eg. https://example.org/sites.json.sig for https://example.org/sites.json?v=2.
+func signatureURL(siteListURL string) (string, error) {
+	parsed, err := url.Parse(siteListURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing url: %w", err)
+	}
+
+	parsed.Path += signatureSuffix
+	parsed.RawPath = ""
+
+	return parsed.String(), nil
+}
+
+// fetchSignature downloads the detached signature of a site list.
+func fetchSignature(ctx context.Context, client *http.Client, siteListURL string) ([]byte, error) {
+	location, err := signatureURL(siteListURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating signature request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing signature request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %v not found", ErrMissingSignature, location)
+	case resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices:
+		return nil, fmt.Errorf("%w: %v for %v", ErrUnexpectedStatus, resp.Status, location)
+	}
+
+	signature, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureBytes))
+	if err != nil {
+		return nil, fmt.Errorf("reading signature: %w", err)
+	}
+
+	return decodeSignature(signature)
+}
@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{
+			name: "base64",
+			key:  base64.StdEncoding.EncodeToString(publicKey) + "\n",
+		},
+		{
+			name: "pem",
+			key:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		},
+		{
+			name:    "too short",
+			key:     base64.StdEncoding.EncodeToString(publicKey[:16]),
+			wantErr: config.ErrInvalidPublicKey,
+		},
+		{
+			name:    "not base64",
+			key:     "not a key",
+			wantErr: config.ErrInvalidPublicKey,
+		},
+		{
+			name:    "broken pem",
+			key:     string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("nope")})),
+			wantErr: config.ErrInvalidPublicKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.ParsePublicKey(tt.key)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, publicKey, got)
+		})
+	}
+}
+
+func TestRemote_Fetch_Signature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	signature := ed25519.Sign(privateKey, []byte(remoteSites))
+
+	signatures := map[string][]byte{
+		"/base64.json.sig":  []byte(base64.StdEncoding.EncodeToString(signature) + "\n"),
+		"/raw.json.sig":     signature,
+		"/other.json.sig":   ed25519.Sign(otherKey, []byte(remoteSites)),
+		"/garbage.json.sig": []byte("garbage"),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sig") {
+			contents, ok := signatures[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+
+				return
+			}
+
+			w.Write(contents) //nolint:errcheck // The test fails anyway if the write fails.
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(remoteSites)) //nolint:errcheck // The test fails anyway if the write fails.
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{
+			name: "base64 signature",
+			path: "/base64.json?version=2",
+		},
+		{
+			name: "raw signature",
+			path: "/raw.json",
+		},
+		{
+			name:    "signed with another key",
+			path:    "/other.json",
+			wantErr: config.ErrInvalidSignature,
+		},
+		{
+			name:    "not a signature",
+			path:    "/garbage.json",
+			wantErr: config.ErrInvalidSignature,
+		},
+		{
+			name:    "unsigned",
+			path:    "/unsigned.json",
+			wantErr: config.ErrMissingSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.NewRemote(server.Client(), server.URL+tt.path, "", 0, publicKey).Fetch(context.Background())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, got)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, got, 1)
+		})
+	}
+}
+
+func TestRemote_Fetch_TamperedCache(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	down := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case down:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.HasSuffix(r.URL.Path, ".sig"):
+			w.Write(ed25519.Sign(privateKey, []byte(remoteSites))) //nolint:errcheck // The test fails anyway if the write fails.
+		default:
+			w.Write([]byte(remoteSites)) //nolint:errcheck // The test fails anyway if the write fails.
+		}
+	}))
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+
+	_, err = config.NewRemote(server.Client(), server.URL+"/sites.json", cachePath, 0, publicKey).Fetch(context.Background())
+	require.NoError(t, err)
+
+	cached, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cachePath, []byte(strings.Replace(string(cached), "duckduckgo.com", "evil.example", 1)), 0o600))
+
+	down = true
+
+	_, err = config.NewRemote(server.Client(), server.URL+"/sites.json", cachePath, 0, publicKey).Fetch(context.Background())
+	require.ErrorIs(t, err, config.ErrUnexpectedStatus, "the tampered cache should have been ignored")
+}
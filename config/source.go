@@ -0,0 +1,208 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long Watch waits for things to settle after a change. Editors and deploys usually write a file in several steps (truncate, write, rename...), and we only want to reload once.
+const watchDelay = 200 * time.Millisecond
+
+// LocalPath returns the path of a local site list, and whether the location is local at all. A location is local if it's a file:// URL or a bare path.
+func LocalPath(location string) (string, bool) {
+	parsed, err := url.Parse(location)
+	if err != nil { // eg. a path with a % in it, which can't be anything but a path anyway
+		return location, true
+	}
+
+	switch parsed.Scheme {
+	case "":
+		return location, true
+	case "file":
+		return filepath.FromSlash(parsed.Path), true
+	default:
+		return "", false
+	}
+}
+
+// Load loads the site list from a location, which can be an http(s) URL, a file:// URL or a bare path to a file or a directory.
+// Directories are loaded with [ParseDir].
+func Load(ctx context.Context, client *http.Client, location string) ([]SiteElement, error) {
+	path, local := LocalPath(location)
+	if !local {
+		return ParseRemote(ctx, client, location)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %w", path, err)
+	}
+
+	if info.IsDir() {
+		return ParseDir(path)
+	}
+
+	return Parse(path)
+}
+
+// isSiteFile returns whether a file in a directory is a site list, by its extension. Everything else (eg. a README) is skipped.
+func isSiteFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseDir parses every site list in a directory (not recursively) and merges them, in the order of the file names.
+// Hidden files and files with other extensions are skipped.
+//
+// A URL that appears more than once is an error, as it's most likely a copy-paste mistake between files. Every problem of every file is returned at once as [ValidationErrors].
+func ParseDir(dir string) ([]SiteElement, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %v: %w", dir, err)
+	}
+
+	type origin struct {
+		file  string
+		index int
+	}
+
+	siteConfiguration := []SiteElement{}
+	seen := map[string]origin{}
+	errs := ValidationErrors{}
+
+	for _, entry := range entries { // they are already sorted by name
+		if entry.IsDir() || entry.Name()[0] == '.' || !isSiteFile(entry.Name()) {
+			continue
+		}
+
+		filename := filepath.Join(dir, entry.Name())
+
+		fileContents, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %v: %w", filename, err)
+		}
+
+		sites, lines, err := parseWithPositions(fileContents, FormatFromName(filename), filename)
+		if err != nil {
+			var validationErrors ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				return nil, err
+			}
+
+			errs = append(errs, validationErrors...)
+
+			continue
+		}
+
+		for i, site := range sites {
+			if first, ok := seen[site.URL]; ok {
+				errs = append(errs, ValidationError{
+					File:    filename,
+					Line:    lines.line("/" + strconv.Itoa(i) + "/url"),
+					Index:   i,
+					Field:   "url",
+					Message: fmt.Sprintf("%v is already in site %d of %v", site.URL, first.index, first.file),
+				})
+
+				continue
+			}
+
+			seen[site.URL] = origin{file: filename, index: i}
+			siteConfiguration = append(siteConfiguration, site)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return siteConfiguration, nil
+}
+
+// Watch watches a local site list (a file or a directory) and sends to the returned channel when it changes, until the context is done.
+// Changes close to each other are sent only once, and a change is dropped if the previous one was not received yet, as reloading once is enough.
+//
+// A single file is watched through its directory, so it's still watched after an editor replaces it instead of writing it.
+func Watch(ctx context.Context, path string) (<-chan struct{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %v: %w", path, err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("creating watcher: %w", err)
+	}
+
+	dir := path
+	if !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	err = watcher.Add(dir)
+	if err != nil {
+		watcher.Close() //nolint:errcheck // We are returning the other error.
+
+		return nil, fmt.Errorf("watching %v: %w", dir, err)
+	}
+
+	relevant := func(name string) bool {
+		if info.IsDir() {
+			return isSiteFile(name)
+		}
+
+		return filepath.Clean(name) == filepath.Clean(path)
+	}
+
+	changes := make(chan struct{}, 1)
+
+	go func() {
+		defer watcher.Close() //nolint:errcheck // There is nothing to do about it.
+
+		settle := time.NewTimer(watchDelay)
+		settle.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if relevant(event.Name) && event.Op != fsnotify.Chmod {
+					settle.Reset(watchDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.WarnContext(ctx, "Failed watching the configuration.", slog.String("path", path), slog.String("error", err.Error()))
+			case <-settle.C:
+				select {
+				case changes <- struct{}{}:
+				default: // a reload is already pending
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
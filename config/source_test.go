@@ -0,0 +1,165 @@
+package config_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		location  string
+		wantPath  string
+		wantLocal bool
+	}{
+		{
+			name:      "bare path",
+			location:  "sites/a.json",
+			wantPath:  "sites/a.json",
+			wantLocal: true,
+		},
+		{
+			name:      "file url",
+			location:  "file:///etc/go-monitor/sites.yaml",
+			wantPath:  "/etc/go-monitor/sites.yaml",
+			wantLocal: true,
+		},
+		{
+			name:      "remote",
+			location:  "https://example.org/sites.json",
+			wantPath:  "",
+			wantLocal: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, local := config.LocalPath(tt.location)
+			assert.Equal(t, tt.wantPath, path)
+			assert.Equal(t, tt.wantLocal, local)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	absolute, err := filepath.Abs("testdata/correct.yaml")
+	require.NoError(t, err)
+
+	duck := config.SiteElement{URL: "https://duckduckgo.com", Regexp: regexp.MustCompile("duck"), IntervalSeconds: 5}
+
+	tests := []struct {
+		name     string
+		location string
+		want     []config.SiteElement
+		wantErr  bool
+	}{
+		{
+			name:     "bare path",
+			location: "testdata/correct.json",
+			want:     []config.SiteElement{duck},
+		},
+		{
+			name:     "file url",
+			location: "file://" + filepath.ToSlash(absolute),
+			want:     []config.SiteElement{duck},
+		},
+		{
+			name:     "directory",
+			location: "testdata/sites",
+			want:     []config.SiteElement{duck, {URL: "https://example.org", IntervalSeconds: 10}},
+		},
+		{
+			name:     "directory with duplicates",
+			location: "testdata/duplicates",
+			wantErr:  true,
+		},
+		{
+			name:     "does not exist",
+			location: "testdata/does_not_exist.json",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := config.Load(context.Background(), &http.Client{}, tt.location)
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseDir_ValidationErrors(t *testing.T) {
+	_, err := config.ParseDir("testdata/duplicates")
+
+	var got config.ValidationErrors
+
+	require.ErrorAs(t, err, &got)
+	assert.Equal(t, config.ValidationErrors{
+		{File: filepath.Join("testdata", "duplicates", "b.toml"), Line: 6, Index: 1, Field: "url", Message: "https://duckduckgo.com is already in site 0 of " + filepath.Join("testdata", "duplicates", "a.json")},
+		{File: filepath.Join("testdata", "duplicates", "c.yaml"), Line: 2, Index: 0, Field: "interval_seconds", Message: "got string, want integer"},
+	}, got)
+}
+
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		watch func(dir string) string // returns the path to watch
+		write string                  // file written in the directory
+		want  bool
+	}{
+		{
+			name:  "file",
+			watch: func(dir string) string { return filepath.Join(dir, "sites.json") },
+			write: "sites.json",
+			want:  true,
+		},
+		{
+			name:  "other file in the same directory",
+			watch: func(dir string) string { return filepath.Join(dir, "sites.json") },
+			write: "other.json",
+			want:  false,
+		},
+		{
+			name:  "directory",
+			watch: func(dir string) string { return dir },
+			write: "more.yaml",
+			want:  true,
+		},
+		{
+			name:  "not a site list in the directory",
+			watch: func(dir string) string { return dir },
+			write: "notes.txt",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "sites.json"), []byte("[]"), 0o600))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			changes, err := config.Watch(ctx, tt.watch(dir))
+			require.NoError(t, err)
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, tt.write), []byte("[]"), 0o600))
+
+			select {
+			case <-changes:
+				assert.True(t, tt.want, "got a change that should have been ignored")
+			case <-time.After(time.Second):
+				assert.False(t, tt.want, "did not get a change")
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Schema is the JSON Schema of the site list. It's the same for every format, as they are all converted to JSON values before validating.
+//
+//go:embed schema.json
+var Schema []byte
+
+// schemaURL is the $id of the schema.
+const schemaURL = "https://github.com/pbabbicola/go-monitor/config/schema.json"
+
+// ValidationError is a problem found in a site list. Line and Index are only set when they are known, so Line is 0 and Index is -1 otherwise.
+type ValidationError struct {
+	File    string
+	Line    int
+	Index   int    // Index of the site in the list.
+	Field   string // Field within the site, eg. retry.attempts or extract[1].regexp.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+
+	parts := []string{}
+	if location != "" {
+		parts = append(parts, location)
+	}
+
+	if e.Index >= 0 {
+		parts = append(parts, "site "+strconv.Itoa(e.Index))
+	}
+
+	if e.Field != "" {
+		parts = append(parts, e.Field)
+	}
+
+	return strings.Join(append(parts, e.Message), ": ")
+}
+
+// ValidationErrors are all the problems found in a site list, so they can be fixed at once instead of one by one.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, validationError := range e {
+		messages = append(messages, validationError.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns every error, so [errors.As] finds them.
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for i := range e {
+		errs = append(errs, &e[i])
+	}
+
+	return errs
+}
+
+// compiledSchema is compiled once, the first time it's needed. It's embedded, so it failing to compile is a bug.
+var compiledSchema = sync.OnceValue(func() *jsonschema.Schema {
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(Schema))
+	if err != nil {
+		panic(fmt.Sprintf("unmarshaling the embedded schema: %v", err))
+	}
+
+	compiler := jsonschema.NewCompiler()
+
+	err = compiler.AddResource(schemaURL, document)
+	if err != nil {
+		panic(fmt.Sprintf("adding the embedded schema: %v", err))
+	}
+
+	return compiler.MustCompile(schemaURL)
+})
+
+// validate checks the decoded site list against the schema, and that every regexp compiles. It returns every problem found, sorted by line.
+func validate(value any, lines positions) ValidationErrors {
+	errs := ValidationErrors{}
+
+	err := compiledSchema().Validate(value)
+	if err != nil {
+		var schemaError *jsonschema.ValidationError
+		if !errors.As(err, &schemaError) { // only happens if the decoder returned a type that is not JSON, which would be a bug
+			return ValidationErrors{{Index: -1, Message: err.Error()}}
+		}
+
+		printer := message.NewPrinter(language.English)
+
+		for _, leaf := range leaves(schemaError) {
+			errs = append(errs, newValidationError(leaf.InstanceLocation, lines, leaf.ErrorKind.LocalizedString(printer)))
+		}
+	}
+
+	errs = append(errs, validateRegexps(value, lines)...)
+	errs = append(errs, validateSchedules(value, lines)...)
+
+	slices.SortStableFunc(errs, func(a, b ValidationError) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Index, b.Index), strings.Compare(a.Field, b.Field))
+	})
+
+	return errs
+}
+
+// leaves returns the errors that caused a schema error. The ones in between only say that something below them failed.
+func leaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+
+	found := []*jsonschema.ValidationError{}
+	for _, cause := range err.Causes {
+		found = append(found, leaves(cause)...)
+	}
+
+	return found
+}
+
+// newValidationError creates an error for the value at a location, eg. [0 extract 1 regexp].
+func newValidationError(location []string, lines positions, msg string) ValidationError {
+	validationError := ValidationError{
+		Line:    lines.line(pointer(location)),
+		Index:   -1,
+		Message: msg,
+	}
+
+	if len(location) == 0 {
+		return validationError
+	}
+
+	index, err := strconv.Atoi(location[0])
+	if err == nil {
+		validationError.Index = index
+	}
+
+	field := strings.Builder{}
+
+	for _, token := range location[1:] {
+		if _, err := strconv.Atoi(token); err == nil {
+			field.WriteString("[" + token + "]")
+
+			continue
+		}
+
+		if field.Len() > 0 {
+			field.WriteString(".")
+		}
+
+		field.WriteString(token)
+	}
+
+	validationError.Field = field.String()
+
+	return validationError
+}
+
+// pointer returns the JSON pointer of a location.
+func pointer(location []string) string {
+	escaped := strings.Builder{}
+	for _, token := range location {
+		escaped.WriteString("/" + pointerEscaper.Replace(token))
+	}
+
+	return escaped.String()
+}
+
+// validateRegexps compiles every regexp of the site list. Values with the wrong type are skipped, as the schema already reports them.
+func validateRegexps(value any, lines positions) ValidationErrors {
+	errs := ValidationErrors{}
+
+	check := func(location []string, field any) {
+		expression, ok := field.(string)
+		if !ok {
+			return
+		}
+
+		_, err := regexp.Compile(expression)
+		if err != nil {
+			errs = append(errs, newValidationError(location, lines, err.Error()))
+		}
+	}
+
+	sites, _ := value.([]any)
+	for i, site := range sites {
+		fields, _ := site.(map[string]any)
+		index := strconv.Itoa(i)
+
+		check([]string{index, "regexp"}, fields["regexp"])
+
+		ignoreRegexps, _ := fields["change_ignore_regexps"].([]any)
+		for j, expression := range ignoreRegexps {
+			check([]string{index, "change_ignore_regexps", strconv.Itoa(j)}, expression)
+		}
+
+		extractions, _ := fields["extract"].([]any)
+		for j, extraction := range extractions {
+			extractionFields, _ := extraction.(map[string]any)
+			check([]string{index, "extract", strconv.Itoa(j), "regexp"}, extractionFields["regexp"])
+		}
+	}
+
+	return errs
+}
+
+// validateSchedules parses the cron expressions and time zones of the sites and their maintenance windows, and the times of the maintenance windows. It also checks that one-off windows don't end before they start.
+// Like in validateRegexps, values with the wrong type are skipped.
+func validateSchedules(value any, lines positions) ValidationErrors {
+	errs := ValidationErrors{}
+
+	check := func(location []string, field any, parse func(string) error) {
+		text, ok := field.(string)
+		if !ok {
+			return
+		}
+
+		err := parse(text)
+		if err != nil {
+			errs = append(errs, newValidationError(location, lines, err.Error()))
+		}
+	}
+
+	parseCron := func(text string) error {
+		_, err := ParseCron(text)
+
+		return err
+	}
+
+	parseTimeZone := func(text string) error {
+		return (&Location{}).UnmarshalText([]byte(text))
+	}
+
+	sites, _ := value.([]any)
+	for i, site := range sites {
+		fields, _ := site.(map[string]any)
+
+		check([]string{strconv.Itoa(i), "schedule"}, fields["schedule"], parseCron)
+		check([]string{strconv.Itoa(i), "time_zone"}, fields["time_zone"], parseTimeZone)
+
+		windows, _ := fields["maintenance"].([]any)
+		for j, window := range windows {
+			windowFields, _ := window.(map[string]any)
+			location := []string{strconv.Itoa(i), "maintenance", strconv.Itoa(j)}
+
+			var start, end time.Time
+
+			check(append(location, "start"), windowFields["start"], func(text string) (err error) {
+				start, err = time.Parse(time.RFC3339, text)
+
+				return err //nolint:wrapcheck // It's a validation error message.
+			})
+			check(append(location, "end"), windowFields["end"], func(text string) (err error) {
+				end, err = time.Parse(time.RFC3339, text)
+
+				return err //nolint:wrapcheck // It's a validation error message.
+			})
+			check(append(location, "cron"), windowFields["cron"], parseCron)
+			check(append(location, "time_zone"), windowFields["time_zone"], parseTimeZone)
+
+			if !start.IsZero() && !end.IsZero() && !end.After(start) {
+				errs = append(errs, newValidationError(append(location, "end"), lines, "the window ends before it starts"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// parse decodes, validates and converts a site list. The source is the file name or URL, for error messages.
+func parse(contents []byte, format Format, source string) ([]SiteElement, error) {
+	siteConfiguration, _, err := parseWithPositions(contents, format, source)
+
+	return siteConfiguration, err
+}
+
+// parseWithPositions is like parse, but it also returns where every value is.
+func parseWithPositions(contents []byte, format Format, source string) ([]SiteElement, positions, error) {
+	value, lines, err := decode(format, contents)
+	if err != nil {
+		var validationError *ValidationError
+		if errors.As(err, &validationError) {
+			validationError.File = source
+
+			return nil, nil, ValidationErrors{*validationError}
+		}
+
+		return nil, nil, err
+	}
+
+	secrets, errs := interpolate(value, lines)
+
+	errs = append(errs, validate(value, lines)...)
+	if len(errs) > 0 {
+		// The messages may quote values, eg. a regexp that does not compile, so they may have secrets too.
+		allSecrets := slices.Concat(slices.Collect(maps.Values(secrets))...)
+
+		for i := range errs {
+			errs[i].File = source
+			errs[i].Message = redact(errs[i].Message, allSecrets)
+		}
+
+		return nil, nil, errs
+	}
+
+	// Once it's valid, the easiest way to get the sites is to go through JSON, so the regexps and check types are unmarshaled the same way for every format.
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("converting %v: %w", source, err)
+	}
+
+	siteConfiguration := []SiteElement{}
+
+	err = json.Unmarshal(converted, &siteConfiguration)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unmarshaling %v: %w", source, err)
+	}
+
+	for i := range siteConfiguration {
+		siteConfiguration[i].secrets = secrets[i]
+	}
+
+	return siteConfiguration, lines, nil
+}
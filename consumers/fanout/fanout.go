@@ -0,0 +1,27 @@
+package fanout
+
+import (
+	"context"
+
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// Consume consumes the message queue and sends every message to all the outputs, so more than one consumer can see every message.
+//
+// A message is sent to the outputs in order, so a slow consumer slows down the rest.
+func Consume(ctx context.Context, messageQueue chan monitor.Message, outputs ...chan monitor.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messageQueue:
+			for _, output := range outputs {
+				select {
+				case <-ctx.Done():
+					return
+				case output <- msg:
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package fanout_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+
+	"github.com/pbabbicola/go-monitor/consumers/fanout"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+func TestConsume(t *testing.T) {
+	expectedMessages := []monitor.Message{
+		{StatusCode: 1},
+		{StatusCode: 2},
+		{StatusCode: 3},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messageQueue := make(chan monitor.Message)
+	outputs := []chan monitor.Message{make(chan monitor.Message), make(chan monitor.Message)}
+	received := make([][]monitor.Message, len(outputs))
+
+	var wg sync.WaitGroup
+
+	// these are fake consumers
+	for i, output := range outputs {
+		wg.Go(func() {
+			for range expectedMessages {
+				received[i] = append(received[i], <-output)
+			}
+		})
+	}
+
+	go fanout.Consume(ctx, messageQueue, outputs...)
+
+	// this is a fake producer
+	for _, message := range expectedMessages {
+		messageQueue <- message
+	}
+
+	wg.Wait()
+	cancel()
+
+	for _, messages := range received {
+		assert.Equal(t, expectedMessages, messages)
+	}
+}
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
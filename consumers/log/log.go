@@ -13,7 +13,7 @@ func Consume(ctx context.Context, messageQueue chan monitor.Message) {
 		case <-ctx.Done():
 			return
 		case msg := <-messageQueue:
-			slog.DebugContext(ctx, "Request done", slog.String("url", msg.URL), slog.Duration("duration", msg.Duration), slog.Int("status_code", msg.StatusCode), slog.Bool("regexp_matches", msg.RegexpMatches))
+			slog.DebugContext(ctx, "Request done", slog.String("url", msg.URL), slog.String("check_type", string(msg.CheckType)), slog.String("group", msg.Group), slog.String("owner", msg.Owner), slog.Duration("duration", msg.Duration), slog.Int("status_code", msg.StatusCode), slog.Bool("regexp_matches", msg.RegexpMatches), slog.String("error_class", string(msg.ErrorClass)), slog.Bool("content_changed", msg.ContentChanged), slog.String("suppressed_by", msg.SuppressedBy), slog.Bool("in_maintenance", msg.InMaintenance), slog.String("schedule_mode", string(msg.ScheduleMode)), slog.Bool("anomalous", msg.Anomalous), slog.Bool("flapping", msg.Flapping))
 		}
 	}
 }
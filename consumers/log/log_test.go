@@ -26,6 +26,7 @@ func TestConsume(t *testing.T) {
 		Duration      time.Duration `json:"duration"`
 		StatusCode    int           `json:"status_code"`
 		RegexpMatches bool          `json:"regexp_matches"`
+		ErrorClass    string        `json:"error_class"`
 	}
 
 	expectedLog := temporaryLog{
@@ -33,6 +34,7 @@ func TestConsume(t *testing.T) {
 		Duration:      time.Second,
 		StatusCode:    http.StatusTeapot,
 		RegexpMatches: true,
+		ErrorClass:    "http_status",
 	}
 
 	// Make a new logger with a buffer, so we can check the logs against each other.
@@ -59,6 +61,7 @@ func TestConsume(t *testing.T) {
 			Duration:      expectedLog.Duration,
 			StatusCode:    expectedLog.StatusCode,
 			RegexpMatches: expectedLog.RegexpMatches,
+			ErrorClass:    monitor.ErrorClass(expectedLog.ErrorClass),
 		}
 
 		cancel()
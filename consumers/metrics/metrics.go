@@ -0,0 +1,263 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// Metrics keeps the latest result of every site and some counters, and serves them in the Prometheus text format.
+// I didn't want to pull in the whole Prometheus client for a handful of gauges.
+type Metrics struct {
+	mut        *sync.Mutex
+	sites      map[string]bool // of the site list, as [config.SiteElement.String] shows them, or nil if it was never set, to keep every site.
+	latest     map[string]monitor.Message
+	checks     map[checkKey]int
+	failures   map[checkKey]int
+	collectors []Collector
+}
+
+// Collector has more metrics to serve along with the ones of the results, eg. the ones of the SLOs.
+type Collector interface {
+	Families() []*Family
+}
+
+// checkKey identifies a counter.
+type checkKey struct {
+	url        string
+	errorClass monitor.ErrorClass
+}
+
+// New creates a new Metrics consumer. It also satisfies the implicit Consumer interface.
+func New() *Metrics {
+	return &Metrics{
+		mut:      &sync.Mutex{},
+		latest:   map[string]monitor.Message{},
+		checks:   map[checkKey]int{},
+		failures: map[checkKey]int{},
+	}
+}
+
+// Register adds a collector, whose metrics are served after the ones of the results.
+func (m *Metrics) Register(collector Collector) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.collectors = append(m.collectors, collector)
+}
+
+// SetSites sets the site list, eg. after it's reloaded. The sites that are not in it anymore are not served, and their results are ignored from then on, so the ones that were still on their way are not served either.
+func (m *Metrics) SetSites(sites []config.SiteElement) {
+	keys := make(map[string]bool, len(sites))
+	for _, site := range sites {
+		keys[site.String()] = true
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.sites = keys
+
+	maps.DeleteFunc(m.latest, func(url string, _ monitor.Message) bool { return !keys[url] })
+	maps.DeleteFunc(m.checks, func(key checkKey, _ int) bool { return !keys[key.url] })
+	maps.DeleteFunc(m.failures, func(key checkKey, _ int) bool { return !keys[key.url] })
+}
+
+// Add records a message. It's safe to use concurrently.
+func (m *Metrics) Add(message monitor.Message) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.sites != nil && !m.sites[message.URL] { // removed from the site list
+		return
+	}
+
+	m.latest[message.URL] = message
+	m.checks[checkKey{url: message.URL}]++
+
+	if message.ErrorClass != monitor.ErrorClassNone {
+		m.failures[checkKey{url: message.URL, errorClass: message.ErrorClass}]++
+	}
+}
+
+// Consume consumes the message queue and records every message.
+func (m *Metrics) Consume(ctx context.Context, messageQueue chan monitor.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messageQueue:
+			m.Add(msg)
+		}
+	}
+}
+
+// labelEscaper escapes label values as the text format expects.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Labels formats label pairs, eg. {url="https://example.org"}.
+func Labels(pairs ...string) string {
+	formatted := make([]string, 0, len(pairs)/2) //nolint:mnd // Pairs of name and value.
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		formatted = append(formatted, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+
+	return "{" + strings.Join(formatted, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
+// Family is a metric with all its samples, ready to be written. Other packages use it to serve their own metrics, see [Collector].
+type Family struct {
+	name       string
+	help       string
+	metricType string
+	samples    []string
+}
+
+// NewFamily creates a metric family without samples. The type is one of the text format, eg. gauge or counter.
+func NewFamily(name, help, metricType string) *Family {
+	return &Family{name: name, help: help, metricType: metricType}
+}
+
+// Add adds a sample, with the label pairs formatted by [Labels].
+func (f *Family) Add(labelPairs string, value float64) {
+	f.samples = append(f.samples, f.name+labelPairs+" "+formatFloat(value))
+}
+
+func (f *Family) writeTo(w io.Writer) error {
+	if len(f.samples) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s\n", f.name, f.help, f.name, f.metricType, strings.Join(f.samples, "\n"))
+	if err != nil {
+		return fmt.Errorf("writing %v: %w", f.name, err)
+	}
+
+	return nil
+}
+
+// families builds all the metric families from the current state.
+func (m *Metrics) families() []*Family {
+	up := &Family{name: "gomonitor_up", help: "Whether the last check of the site succeeded.", metricType: "gauge"}
+	duration := &Family{name: "gomonitor_check_duration_seconds", help: "Duration of the last check of the site.", metricType: "gauge"}
+	statusCode := &Family{name: "gomonitor_status_code", help: "Status code of the last check of the site.", metricType: "gauge"}
+	lastCheck := &Family{name: "gomonitor_last_check_timestamp_seconds", help: "When the last check of the site started.", metricType: "gauge"}
+	values := &Family{name: "gomonitor_extracted_value", help: "Numbers extracted from the body in the last check of the site.", metricType: "gauge"}
+	checks := &Family{name: "gomonitor_checks_total", help: "Checks done for the site.", metricType: "counter"}
+	failures := &Family{name: "gomonitor_check_failures_total", help: "Failed checks of the site, by error class.", metricType: "counter"}
+	info := &Family{name: "gomonitor_site_info", help: "Group, owner and labels of the site, to join with the other metrics.", metricType: "gauge"}
+	groupSites := &Family{name: "gomonitor_group_sites", help: "Sites in the group.", metricType: "gauge"}
+	groupUp := &Family{name: "gomonitor_group_sites_up", help: "Sites in the group whose last check succeeded.", metricType: "gauge"}
+	inMaintenance := &Family{name: "gomonitor_in_maintenance", help: "Whether the site was in a maintenance window in the last check.", metricType: "gauge"}
+	flapping := &Family{name: "gomonitor_flapping", help: "Whether the site keeps going up and down.", metricType: "gauge"}
+	baseline := &Family{name: "gomonitor_latency_baseline_seconds", help: "Usual duration of a check of the site at this hour of the week.", metricType: "gauge"}
+	anomalous := &Family{name: "gomonitor_latency_anomalous", help: "Whether the last check of the site was much slower than usual.", metricType: "gauge"}
+
+	sitesPerGroup := map[string]int{} // sites in maintenance are left out, so they don't affect the uptime
+	upPerGroup := map[string]int{}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	for _, url := range slices.Sorted(maps.Keys(m.latest)) {
+		message := m.latest[url]
+		siteLabels := Labels("url", url, "check_type", string(message.CheckType))
+
+		up.Add(siteLabels, boolToFloat(message.ErrorClass == monitor.ErrorClassNone))
+		duration.Add(siteLabels, message.Duration.Seconds())
+		statusCode.Add(siteLabels, float64(message.StatusCode))
+		lastCheck.Add(siteLabels, float64(message.Timestamp.Unix()))
+
+		for _, name := range slices.Sorted(maps.Keys(message.Values)) {
+			values.Add(Labels("url", url, "name", name), message.Values[name])
+		}
+
+		checks.Add(Labels("url", url), float64(m.checks[checkKey{url: url}]))
+		info.Add(infoLabels(message), 1)
+		inMaintenance.Add(Labels("url", url), boolToFloat(message.InMaintenance))
+		flapping.Add(Labels("url", url), boolToFloat(message.Flapping))
+
+		if message.Baseline > 0 { // only sites that detect anomalies and have learned enough
+			baseline.Add(Labels("url", url), message.Baseline.Seconds())
+			anomalous.Add(Labels("url", url), boolToFloat(message.Anomalous))
+		}
+
+		if message.Group != "" && !message.InMaintenance {
+			sitesPerGroup[message.Group]++
+
+			if message.ErrorClass == monitor.ErrorClassNone {
+				upPerGroup[message.Group]++
+			}
+		}
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(sitesPerGroup)) {
+		groupSites.Add(Labels("group", group), float64(sitesPerGroup[group]))
+		groupUp.Add(Labels("group", group), float64(upPerGroup[group]))
+	}
+
+	failureKeys := slices.SortedFunc(maps.Keys(m.failures), func(a, b checkKey) int {
+		return strings.Compare(a.url+" "+string(a.errorClass), b.url+" "+string(b.errorClass))
+	})
+
+	for _, key := range failureKeys {
+		failures.Add(Labels("url", key.url, "error_class", string(key.errorClass)), float64(m.failures[key]))
+	}
+
+	return []*Family{up, duration, statusCode, lastCheck, values, checks, failures, info, groupSites, groupUp, inMaintenance, flapping, baseline, anomalous}
+}
+
+// infoLabels returns the labels of the info metric of a site. The labels of the site are prefixed with label_, so they can't clash with ours.
+func infoLabels(message monitor.Message) string {
+	pairs := []string{"url", message.URL, "check_type", string(message.CheckType), "group", message.Group, "owner", message.Owner}
+
+	for _, name := range slices.Sorted(maps.Keys(message.Labels)) {
+		pairs = append(pairs, "label_"+name, message.Labels[name])
+	}
+
+	return Labels(pairs...)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	families := m.families()
+
+	m.mut.Lock()
+	collectors := slices.Clone(m.collectors)
+	m.mut.Unlock()
+
+	for _, collector := range collectors {
+		families = append(families, collector.Families()...)
+	}
+
+	for _, metricFamily := range families {
+		err := metricFamily.writeTo(w)
+		if err != nil { // the client is most likely gone, there is nothing else we can do.
+			return
+		}
+	}
+}
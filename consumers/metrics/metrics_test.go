@@ -0,0 +1,229 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/consumers/metrics"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	timestamp, err := time.Parse(time.RFC3339, "2021-10-14T16:08:01+01:00")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		messages []monitor.Message
+		want     string
+		partial  bool // only checks that want is part of the output
+	}{
+		{
+			name:     "no messages",
+			messages: nil,
+			want:     "",
+		},
+		{
+			name: "latest result and counters",
+			messages: []monitor.Message{
+				{
+					URL:        "https://example.org",
+					CheckType:  config.CheckTypeHTTP,
+					Duration:   time.Second,
+					Timestamp:  timestamp,
+					StatusCode: http.StatusInternalServerError,
+					ErrorClass: monitor.ErrorClassHTTPStatus,
+				},
+				{
+					URL:        "https://example.org",
+					CheckType:  config.CheckTypeHTTP,
+					Duration:   250 * time.Millisecond,
+					Timestamp:  timestamp.Add(time.Minute),
+					StatusCode: http.StatusOK,
+					Values:     map[string]float64{"queue_depth": 1234, "workers": 8},
+					Labels:     map[string]string{"tier": "1", "env": "prod"},
+					Group:      "website",
+					Owner:      "web-team",
+				},
+				{
+					URL:        `localhost:50051`,
+					CheckType:  config.CheckTypeGRPC,
+					Duration:   time.Millisecond,
+					Timestamp:  timestamp,
+					ErrorClass: monitor.ErrorClassNotServing,
+					Group:      "website",
+				},
+			},
+			want: `# HELP gomonitor_up Whether the last check of the site succeeded.
+# TYPE gomonitor_up gauge
+gomonitor_up{url="https://example.org",check_type="http"} 1
+gomonitor_up{url="localhost:50051",check_type="grpc"} 0
+# HELP gomonitor_check_duration_seconds Duration of the last check of the site.
+# TYPE gomonitor_check_duration_seconds gauge
+gomonitor_check_duration_seconds{url="https://example.org",check_type="http"} 0.25
+gomonitor_check_duration_seconds{url="localhost:50051",check_type="grpc"} 0.001
+# HELP gomonitor_status_code Status code of the last check of the site.
+# TYPE gomonitor_status_code gauge
+gomonitor_status_code{url="https://example.org",check_type="http"} 200
+gomonitor_status_code{url="localhost:50051",check_type="grpc"} 0
+# HELP gomonitor_last_check_timestamp_seconds When the last check of the site started.
+# TYPE gomonitor_last_check_timestamp_seconds gauge
+gomonitor_last_check_timestamp_seconds{url="https://example.org",check_type="http"} 1634224141
+gomonitor_last_check_timestamp_seconds{url="localhost:50051",check_type="grpc"} 1634224081
+# HELP gomonitor_extracted_value Numbers extracted from the body in the last check of the site.
+# TYPE gomonitor_extracted_value gauge
+gomonitor_extracted_value{url="https://example.org",name="queue_depth"} 1234
+gomonitor_extracted_value{url="https://example.org",name="workers"} 8
+# HELP gomonitor_checks_total Checks done for the site.
+# TYPE gomonitor_checks_total counter
+gomonitor_checks_total{url="https://example.org"} 2
+gomonitor_checks_total{url="localhost:50051"} 1
+# HELP gomonitor_check_failures_total Failed checks of the site, by error class.
+# TYPE gomonitor_check_failures_total counter
+gomonitor_check_failures_total{url="https://example.org",error_class="http_status"} 1
+gomonitor_check_failures_total{url="localhost:50051",error_class="not_serving"} 1
+# HELP gomonitor_site_info Group, owner and labels of the site, to join with the other metrics.
+# TYPE gomonitor_site_info gauge
+gomonitor_site_info{url="https://example.org",check_type="http",group="website",owner="web-team",label_env="prod",label_tier="1"} 1
+gomonitor_site_info{url="localhost:50051",check_type="grpc",group="website",owner=""} 1
+# HELP gomonitor_group_sites Sites in the group.
+# TYPE gomonitor_group_sites gauge
+gomonitor_group_sites{group="website"} 2
+# HELP gomonitor_group_sites_up Sites in the group whose last check succeeded.
+# TYPE gomonitor_group_sites_up gauge
+gomonitor_group_sites_up{group="website"} 1
+# HELP gomonitor_in_maintenance Whether the site was in a maintenance window in the last check.
+# TYPE gomonitor_in_maintenance gauge
+gomonitor_in_maintenance{url="https://example.org"} 0
+gomonitor_in_maintenance{url="localhost:50051"} 0
+# HELP gomonitor_flapping Whether the site keeps going up and down.
+# TYPE gomonitor_flapping gauge
+gomonitor_flapping{url="https://example.org"} 0
+gomonitor_flapping{url="localhost:50051"} 0
+`,
+		},
+		{
+			name: "label values are escaped",
+			messages: []monitor.Message{
+				{URL: "https://example.org/\"quoted\"\\", CheckType: config.CheckTypeHTTP},
+			},
+			want: `# HELP gomonitor_up Whether the last check of the site succeeded.
+# TYPE gomonitor_up gauge
+gomonitor_up{url="https://example.org/\"quoted\"\\",check_type="http"} 1
+`,
+			partial: true,
+		},
+		{
+			name: "latency anomalies",
+			messages: []monitor.Message{
+				{URL: "https://example.org", Duration: 2 * time.Second, Anomalous: true, Baseline: 250 * time.Millisecond},
+				{URL: "https://example.org/blog"},
+			},
+			want: `# HELP gomonitor_latency_baseline_seconds Usual duration of a check of the site at this hour of the week.
+# TYPE gomonitor_latency_baseline_seconds gauge
+gomonitor_latency_baseline_seconds{url="https://example.org"} 0.25
+# HELP gomonitor_latency_anomalous Whether the last check of the site was much slower than usual.
+# TYPE gomonitor_latency_anomalous gauge
+gomonitor_latency_anomalous{url="https://example.org"} 1
+`,
+			partial: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.New()
+
+			for _, message := range tt.messages {
+				m.Add(message)
+			}
+
+			recorder := httptest.NewRecorder()
+			m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+			body, err := io.ReadAll(recorder.Body)
+			require.NoError(t, err)
+
+			if tt.partial {
+				assert.Contains(t, string(body), tt.want)
+
+				return
+			}
+
+			assert.Equal(t, tt.want, string(body))
+		})
+	}
+}
+
+func TestMetrics_Consume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messageQueue := make(chan monitor.Message)
+
+	m := metrics.New()
+
+	var wg sync.WaitGroup
+
+	wg.Go(func() {
+		m.Consume(ctx, messageQueue)
+	})
+
+	messageQueue <- monitor.Message{URL: "https://example.org", CheckType: config.CheckTypeHTTP}
+
+	cancel()
+	wg.Wait()
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Contains(t, recorder.Body.String(), `gomonitor_checks_total{url="https://example.org"} 1`)
+}
+
+func TestMetrics_SetSites(t *testing.T) {
+	m := metrics.New()
+	m.SetSites([]config.SiteElement{{URL: "https://example.org"}, {URL: "https://shop.example.org"}})
+
+	m.Add(monitor.Message{URL: "https://example.org", CheckType: config.CheckTypeHTTP})
+	m.Add(monitor.Message{URL: "https://shop.example.org", CheckType: config.CheckTypeHTTP, ErrorClass: monitor.ErrorClassTimeout})
+
+	// the shop is removed from the site list, and its last check was still on its way
+	m.SetSites([]config.SiteElement{{URL: "https://example.org"}})
+	m.Add(monitor.Message{URL: "https://shop.example.org", CheckType: config.CheckTypeHTTP})
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Contains(t, recorder.Body.String(), `gomonitor_checks_total{url="https://example.org"} 1`)
+	assert.NotContains(t, recorder.Body.String(), "shop.example.org", "removed sites are not served")
+}
+
+type collector []*metrics.Family
+
+func (c collector) Families() []*metrics.Family {
+	return c
+}
+
+func TestMetrics_Register(t *testing.T) {
+	queueDepth := metrics.NewFamily("gomonitor_queue_depth", "Messages waiting.", "gauge")
+	queueDepth.Add(metrics.Labels("queue", `say "hi"`), 3)
+
+	m := metrics.New()
+	m.Register(collector{queueDepth, metrics.NewFamily("gomonitor_empty", "Nothing.", "gauge")})
+	m.Add(monitor.Message{URL: "https://example.org", CheckType: config.CheckTypeHTTP})
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Contains(t, recorder.Body.String(), `gomonitor_checks_total{url="https://example.org"} 1`)
+	assert.True(t, strings.HasSuffix(recorder.Body.String(), "# HELP gomonitor_queue_depth Messages waiting.\n# TYPE gomonitor_queue_depth gauge\ngomonitor_queue_depth{queue=\"say \\\"hi\\\"\"} 3\n"), "collected metrics are served last")
+	assert.NotContains(t, recorder.Body.String(), "gomonitor_empty", "families without samples are not served")
+}
@@ -3,11 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
 
-	_ "github.com/lib/pq" // postgres driver
+	"github.com/lib/pq" // postgres driver
 
 	"github.com/pbabbicola/go-monitor/monitor"
 )
@@ -22,7 +23,6 @@ import (
 // }
 // func NewClient(databaseURL string, options ...Option) (*sql.DB, error) {
 
-
 type Postgres struct {
 	pool *sql.DB
 }
@@ -58,6 +58,11 @@ func NewConnection(ctx context.Context, databaseURL string) (*sql.DB, error) {
 	return db, nil
 }
 
+// DB returns the database pool, for whoever needs to read the results, eg. the SLOs.
+func (p *Postgres) DB() *sql.DB {
+	return p.pool
+}
+
 // Close closes the database pool and logs an error to slog if there is any problem.
 func (p *Postgres) Close(ctx context.Context) {
 	err := p.pool.Close()
@@ -85,7 +90,50 @@ func (p *Postgres) Consume(ctx context.Context, batchQueue chan []monitor.Messag
 	}
 }
 
-const insertQuery = "insert into logs (ts, url, duration_milliseconds, status_code, regexp_matches, error) values($1, $2, $3, $4, $5, $6)"
+const insertQuery = "insert into logs (ts, url, duration_milliseconds, status_code, regexp_matches, error, check_type, grpc_serving_status, handshake_milliseconds, round_trip_milliseconds, attempts, attempt_errors, error_class, body_bytes, body_truncated, body_hash, content_changed, content_diff, extracted_values, assertion_failures, redirect_chain, labels, site_group, owner, suppressed_by, in_maintenance, schedule_mode, anomalous, baseline_milliseconds, flapping) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30)"
+
+// hop is how a hop of a redirect chain is stored.
+type hop struct {
+	URL                  string `json:"url"`
+	StatusCode           int    `json:"status_code"`
+	DurationMilliseconds int64  `json:"duration_milliseconds"`
+}
+
+// baselineMilliseconds returns the baseline in milliseconds, or nil if there is none, so it's not confused with a baseline of 0ms.
+func baselineMilliseconds(baseline time.Duration) any {
+	if baseline == 0 {
+		return nil
+	}
+
+	return baseline.Milliseconds()
+}
+
+// redirectChain returns the redirect chain as JSON, or nil if there were no redirects.
+func redirectChain(hops []monitor.Hop) []byte {
+	if len(hops) == 0 {
+		return nil
+	}
+
+	chain := make([]hop, 0, len(hops))
+	for _, h := range hops {
+		chain = append(chain, hop{URL: h.URL, StatusCode: h.StatusCode, DurationMilliseconds: h.Duration.Milliseconds()})
+	}
+
+	marshaled, _ := json.Marshal(chain) //nolint:errcheck,errchkjson // It's only strings and numbers.
+
+	return marshaled
+}
+
+// labels returns the labels as JSON, or nil if there are none.
+func labels(values map[string]string) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+
+	marshaled, _ := json.Marshal(values) //nolint:errcheck,errchkjson // It's only strings.
+
+	return marshaled
+}
 
 // writeToPostgres writes a batch of inserts in a transaction.
 func writeToPostgres(ctx context.Context, pool *sql.DB, batch []monitor.Message) error {
@@ -108,6 +156,19 @@ func writeToPostgres(ctx context.Context, pool *sql.DB, batch []monitor.Message)
 			messageError = msg.Err.Error() // There is probably a more elegant way, but also I feel like go should be handling nil errors better.
 		}
 
+		attemptErrors := make([]string, 0, len(msg.AttemptErrors))
+		for _, attemptError := range msg.AttemptErrors {
+			attemptErrors = append(attemptErrors, attemptError.Error())
+		}
+
+		var extractedValues []byte // stays NULL if there are no values
+		if len(msg.Values) > 0 {
+			extractedValues, err = json.Marshal(msg.Values)
+			if err != nil { // can't really happen with a map of floats, so the row is still written without them.
+				slog.ErrorContext(ctx, "Failed marshaling extracted values.", slog.String("url", msg.URL), slog.String("error", fmt.Sprintf("%s", err)))
+			}
+		}
+
 		_, err := stmt.ExecContext(
 			ctx,
 			msg.Timestamp,
@@ -116,6 +177,30 @@ func writeToPostgres(ctx context.Context, pool *sql.DB, batch []monitor.Message)
 			msg.StatusCode,
 			msg.RegexpMatches,
 			messageError,
+			string(msg.CheckType),
+			msg.GRPCServingStatus,
+			msg.HandshakeDuration.Milliseconds(),
+			msg.RoundTripDuration.Milliseconds(),
+			msg.Attempts,
+			pq.Array(attemptErrors),
+			string(msg.ErrorClass),
+			msg.BodyBytes,
+			msg.BodyTruncated,
+			msg.BodyHash,
+			msg.ContentChanged,
+			msg.ContentDiff,
+			extractedValues,
+			pq.Array(msg.AssertionFailures),
+			redirectChain(msg.Redirects),
+			labels(msg.Labels),
+			msg.Group,
+			msg.Owner,
+			msg.SuppressedBy,
+			msg.InMaintenance,
+			msg.ScheduleMode,
+			msg.Anomalous,
+			baselineMilliseconds(msg.Baseline),
+			msg.Flapping,
 		)
 		if err != nil { // making the assumption here that we want to keep writing despite the error
 			slog.ErrorContext(
@@ -126,6 +211,8 @@ func writeToPostgres(ctx context.Context, pool *sql.DB, batch []monitor.Message)
 				slog.Duration("duration", msg.Duration),
 				slog.Int("status_code", msg.StatusCode),
 				slog.Bool("regexp_matches", msg.RegexpMatches),
+				slog.String("check_type", string(msg.CheckType)),
+				slog.String("error_class", string(msg.ErrorClass)),
 				slog.String("error", fmt.Sprintf("%s", err)),
 			)
 		}
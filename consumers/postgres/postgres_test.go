@@ -4,13 +4,16 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/pbabbicola/go-monitor/config"
 	"github.com/pbabbicola/go-monitor/monitor"
 )
 
@@ -39,12 +42,15 @@ func Test_writeToPostgres(t *testing.T) {
 					StatusCode:    http.StatusOK,
 					RegexpMatches: true,
 					Err:           assert.AnError,
+					CheckType:     config.CheckTypeHTTP,
+					Attempts:      1,
+					ErrorClass:    monitor.ErrorClassUnknown,
 				},
 			},
 			wantErr: false,
 			dbExpectations: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectPrepare("insert into logs \\(ts, url, duration_milliseconds, status_code, regexp_matches, error\\) values\\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)").ExpectExec().WithArgs(timestamp, "some_url", int(time.Second/time.Millisecond), http.StatusOK, true, assert.AnError.Error()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectPrepare(regexp.QuoteMeta(insertQuery)).ExpectExec().WithArgs(timestamp, "some_url", int(time.Second/time.Millisecond), http.StatusOK, true, assert.AnError.Error(), "http", "", int64(0), int64(0), 1, pq.Array([]string{}), "unknown", int64(0), false, "", false, "", []byte(nil), pq.Array([]string(nil)), []byte(nil), []byte(nil), "", "", "", false, monitor.ScheduleMode(""), false, nil, false).WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectCommit()
 			},
 		},
@@ -62,7 +68,7 @@ func Test_writeToPostgres(t *testing.T) {
 			wantErr: true,
 			dbExpectations: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectPrepare("insert into logs \\(ts, url, duration_milliseconds, status_code, regexp_matches, error\\) values\\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)").WillReturnError(assert.AnError)
+				mock.ExpectPrepare(regexp.QuoteMeta(insertQuery)).WillReturnError(assert.AnError)
 			},
 		},
 		{
@@ -75,28 +81,57 @@ func Test_writeToPostgres(t *testing.T) {
 					StatusCode:    http.StatusOK,
 					RegexpMatches: true,
 					Err:           assert.AnError,
+					CheckType:     config.CheckTypeHTTP,
+					Attempts:      1,
+					ErrorClass:    monitor.ErrorClassUnknown,
 				},
 				{
-					URL:           "some_url_2",
-					Duration:      2 * time.Second,
-					Timestamp:     timestamp.Add(time.Hour),
-					StatusCode:    http.StatusNotAcceptable,
-					RegexpMatches: false,
-					Err:           nil,
+					URL:               "some_url_2",
+					Duration:          2 * time.Second,
+					Timestamp:         timestamp.Add(time.Hour),
+					StatusCode:        http.StatusNotAcceptable,
+					RegexpMatches:     false,
+					Err:               nil,
+					CheckType:         config.CheckTypeWebSocket,
+					HandshakeDuration: 2 * time.Second,
+					RoundTripDuration: time.Second,
+					Attempts:          2,
+					AttemptErrors:     []error{assert.AnError},
+					ErrorClass:        monitor.ErrorClassHTTPStatus,
+					BodyBytes:         10 << 20,
+					BodyTruncated:     true,
+					BodyHash:          "abc",
+					ContentChanged:    true,
+					ContentDiff:       "- old\n+ new",
+					Values:            map[string]float64{"queue_depth": 1234},
+					AssertionFailures: []string{"queue_depth: 1234 is above the maximum 1000"},
+					Redirects: []monitor.Hop{
+						{URL: "http://example.org", StatusCode: http.StatusMovedPermanently, Duration: 100 * time.Millisecond},
+						{URL: "https://example.org", StatusCode: http.StatusNotAcceptable, Duration: 1900 * time.Millisecond},
+					},
+					Labels:        map[string]string{"env": "prod"},
+					Group:         "website",
+					Owner:         "web-team",
+					SuppressedBy:  "https://cdn.example.org",
+					InMaintenance: true,
+					ScheduleMode:  monitor.ScheduleModeFailing,
+					Anomalous:     true,
+					Baseline:      250 * time.Millisecond,
+					Flapping:      true,
 				},
 			},
 			wantErr: false,
 			dbExpectations: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
 
-				prepared := mock.ExpectPrepare("insert into logs \\(ts, url, duration_milliseconds, status_code, regexp_matches, error\\) values\\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)")
+				prepared := mock.ExpectPrepare(regexp.QuoteMeta(insertQuery))
 
 				prepared.ExpectExec().
-					WithArgs(timestamp, "some_url", int(time.Second/time.Millisecond), http.StatusOK, true, assert.AnError.Error()).
+					WithArgs(timestamp, "some_url", int(time.Second/time.Millisecond), http.StatusOK, true, assert.AnError.Error(), "http", "", int64(0), int64(0), 1, pq.Array([]string{}), "unknown", int64(0), false, "", false, "", []byte(nil), pq.Array([]string(nil)), []byte(nil), []byte(nil), "", "", "", false, monitor.ScheduleMode(""), false, nil, false).
 					WillReturnError(err)
 
 				prepared.ExpectExec().
-					WithArgs(timestamp.Add(time.Hour), "some_url_2", int(2*time.Second/time.Millisecond), http.StatusNotAcceptable, false, "").
+					WithArgs(timestamp.Add(time.Hour), "some_url_2", int(2*time.Second/time.Millisecond), http.StatusNotAcceptable, false, "", "websocket", "", int64(2*time.Second/time.Millisecond), int64(time.Second/time.Millisecond), 2, pq.Array([]string{assert.AnError.Error()}), "http_status", int64(10<<20), true, "abc", true, "- old\n+ new", []byte(`{"queue_depth":1234}`), pq.Array([]string{"queue_depth: 1234 is above the maximum 1000"}), []byte(`[{"url":"http://example.org","status_code":301,"duration_milliseconds":100},{"url":"https://example.org","status_code":406,"duration_milliseconds":1900}]`), []byte(`{"env":"prod"}`), "website", "web-team", "https://cdn.example.org", true, monitor.ScheduleModeFailing, true, int64(250), true).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				mock.ExpectCommit()
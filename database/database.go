@@ -0,0 +1,31 @@
+// Package database has what the packages that read the results from Postgres share.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Query runs a query and calls scan for every row.
+func Query(ctx context.Context, db *sql.DB, statement string, args []any, scan func(rows *sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return fmt.Errorf("running query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("reading rows: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+// Package httpjson writes the JSON responses of the API, so every route answers, and fails, the same way.
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Write writes a value as the JSON response.
+func Write(ctx context.Context, w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil { // the client is most likely gone, there is nothing else we can do.
+		slog.DebugContext(ctx, "Failed writing response.", slog.String("error", err.Error()))
+	}
+}
+
+// WriteError writes an error as the JSON response, eg. {"error": "no incident with id: 12"}.
+func WriteError(ctx context.Context, w http.ResponseWriter, status int, err error) {
+	Write(ctx, w, status, map[string]string{"error": err.Error()})
+}
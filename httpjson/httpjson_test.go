@@ -0,0 +1,50 @@
+package httpjson_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pbabbicola/go-monitor/httpjson"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "value",
+			write: func(w http.ResponseWriter) {
+				httpjson.Write(context.Background(), w, http.StatusCreated, map[string]int{"id": 1})
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"id":1}` + "\n",
+		},
+		{
+			name: "error",
+			write: func(w http.ResponseWriter) {
+				httpjson.WriteError(context.Background(), w, http.StatusNotFound, errors.New("no such thing"))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"no such thing"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			tt.write(recorder)
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pbabbicola/go-monitor/httpjson"
+)
+
+const (
+	// DefaultLimit is how many incidents are listed if the request doesn't say.
+	DefaultLimit = 100
+	// MaxLimit is how many incidents can be listed at most.
+	MaxLimit = 1000
+)
+
+var (
+	// ErrUnknownState is returned when incidents are listed by a state that is not open nor closed.
+	ErrUnknownState = errors.New("unknown incident state")
+	// ErrInvalidLimit is returned when the limit of a list is not a number between 1 and [MaxLimit].
+	ErrInvalidLimit = errors.New("the limit must be a number between 1 and 1000")
+	// ErrInvalidLabel is returned when incidents are listed by a label that is not name:value.
+	ErrInvalidLabel = errors.New("the label must be name:value")
+	// ErrInvalidID is returned when the ID of the path is not a number.
+	ErrInvalidID = errors.New("invalid incident id")
+	// ErrNoAuthor is returned when someone acknowledges or annotates an incident without saying who they are.
+	ErrNoAuthor = errors.New("the author is needed, so on-call knows who to talk to")
+	// ErrInvalidBody is returned when the body of a request is not the JSON it should be.
+	ErrInvalidBody = errors.New("invalid body")
+	// ErrNoNote is returned when an incident is annotated without a note.
+	ErrNoNote = errors.New("the note is empty")
+)
+
+// State is whether an incident is still open.
+type State string
+
+const (
+	StateAny    State = ""
+	StateOpen   State = "open"
+	StateClosed State = "closed"
+)
+
+// UnmarshalText validates the state, so a typo is an error and not an empty list.
+func (s *State) UnmarshalText(text []byte) error {
+	switch state := State(text); state {
+	case StateAny, StateOpen, StateClosed:
+		*s = state
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownState, state)
+	}
+
+	return nil
+}
+
+// Filter says which incidents are listed. They are filtered by URL, as [config.SiteElement.String] shows it, by state, group and owner, unless they are empty,
+// and by labels: the site must have all of them.
+type Filter struct {
+	URL    string
+	State  State
+	Group  string
+	Owner  string
+	Labels map[string]string
+	Limit  int
+}
+
+// Author is the body of the requests that acknowledge or annotate an incident.
+type Author struct {
+	Author string `json:"author"`
+	Note   string `json:"note"`
+}
+
+// writeError writes an error as the JSON response, eg. {"error": "no incident with id: 12"}, with the status that fits it.
+func writeError(ctx context.Context, w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlreadyAcknowledged):
+		status = http.StatusConflict
+	case errors.Is(err, ErrUnknownState), errors.Is(err, ErrInvalidLimit), errors.Is(err, ErrInvalidLabel), errors.Is(err, ErrInvalidID), errors.Is(err, ErrInvalidBody), errors.Is(err, ErrNoAuthor), errors.Is(err, ErrNoNote):
+		status = http.StatusBadRequest
+	default:
+		slog.ErrorContext(ctx, "Failed serving incidents.", slog.String("error", err.Error()))
+	}
+
+	httpjson.WriteError(ctx, w, status, err)
+}
+
+// parseFilter reads the filter of the query, eg. ?url=https://example.org&state=open&limit=10, or ?owner=web-team&label=env:prod&label=tier:1.
+func parseFilter(r *http.Request) (Filter, error) {
+	query := r.URL.Query()
+	filter := Filter{URL: query.Get("url"), Group: query.Get("group"), Owner: query.Get("owner"), Limit: DefaultLimit}
+
+	for _, label := range query["label"] {
+		name, value, ok := strings.Cut(label, ":")
+		if !ok || name == "" {
+			return Filter{}, fmt.Errorf("%w: %q", ErrInvalidLabel, label)
+		}
+
+		if filter.Labels == nil {
+			filter.Labels = map[string]string{}
+		}
+
+		filter.Labels[name] = value
+	}
+
+	err := filter.State.UnmarshalText([]byte(query.Get("state")))
+	if err != nil {
+		return Filter{}, err
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > MaxLimit {
+			return Filter{}, fmt.Errorf("%w: %q", ErrInvalidLimit, limit)
+		}
+	}
+
+	return filter, nil
+}
+
+// parseID reads the ID of the path.
+func parseID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidID, r.PathValue("id"))
+	}
+
+	return id, nil
+}
+
+// parseAuthor reads the body of the request, and checks that it says who is asking. The routes that use it are behind the API token, which is shared, so the author is who the caller says they are.
+func parseAuthor(r *http.Request) (Author, error) {
+	var body Author
+
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		return Author{}, fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+
+	if body.Author == "" {
+		return Author{}, ErrNoAuthor
+	}
+
+	return body, nil
+}
+
+// ServeList lists the newest incidents, eg. for GET /api/incidents?state=open.
+func (s *Store) ServeList(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	incidents, err := s.List(r.Context(), filter)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, incidents)
+}
+
+// ServeGet responds with the incident with the ID of the path and its timeline, eg. for GET /api/incidents/{id}.
+func (s *Store) ServeGet(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	incident, err := s.Get(r.Context(), id)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, incident)
+}
+
+// ServeAcknowledge acknowledges the incident with the ID of the path, by the author of the body, eg. for POST /api/incidents/{id}/acknowledge.
+func (s *Store) ServeAcknowledge(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	body, err := parseAuthor(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	incident, err := s.Acknowledge(r.Context(), id, body.Author, body.Note)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Incident acknowledged.", slog.Int64("id", id), slog.String("url", incident.URL), slog.String("author", body.Author))
+
+	httpjson.Write(r.Context(), w, http.StatusOK, incident)
+}
+
+// ServeAnnotate adds the note of the body to the incident with the ID of the path, eg. for POST /api/incidents/{id}/notes.
+func (s *Store) ServeAnnotate(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	body, err := parseAuthor(r)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	if body.Note == "" {
+		writeError(r.Context(), w, ErrNoNote)
+
+		return
+	}
+
+	incident, err := s.Annotate(r.Context(), id, body.Author, body.Note)
+	if err != nil {
+		writeError(r.Context(), w, err)
+
+		return
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusCreated, incident)
+}
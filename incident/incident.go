@@ -0,0 +1,407 @@
+// Package incident keeps the incidents of the sites in Postgres: when a site started failing (or got much slower than usual) and when it recovered, its errors, and a timeline of what happened in between, so on-call can see who is handling what.
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+var (
+	// ErrNotFound is returned when there is no incident with an ID.
+	ErrNotFound = errors.New("no incident with id")
+	// ErrAlreadyAcknowledged is returned when someone acknowledges an incident that someone already did.
+	ErrAlreadyAcknowledged = errors.New("the incident is already acknowledged")
+)
+
+// EventKind says what happened in the timeline of an incident.
+type EventKind string
+
+const (
+	EventDetected     EventKind = "detected"     // The site started failing.
+	EventNotified     EventKind = "notified"     // Someone was told that the site is down, or slower than usual.
+	EventAcknowledged EventKind = "acknowledged" // Someone is handling it.
+	EventNote         EventKind = "note"         // Someone wrote something about it.
+	EventResolved     EventKind = "resolved"     // The site recovered.
+)
+
+// Incident is a time when a site was failing or much slower than usual, from its first failed or anomalous check to the first one after it that is neither.
+// Incidents of sites that are flapping stay open until they stop flapping, so they aren't opened and resolved over and over.
+type Incident struct {
+	ID             int64             `json:"id"`
+	URL            string            `json:"url"`
+	Group          string            `json:"group,omitempty"`
+	Owner          string            `json:"owner,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	OpenedAt       time.Time         `json:"opened_at"`
+	ClosedAt       time.Time         `json:"closed_at,omitzero"`
+	FirstError     string            `json:"first_error"`
+	LastError      string            `json:"last_error"`
+	ErrorClass     string            `json:"error_class"` // Of the last failed check. It's empty if the site was only slower than usual.
+	LastFailureAt  time.Time         `json:"last_failure_at"`
+	AcknowledgedBy string            `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt time.Time         `json:"acknowledged_at,omitzero"`
+	Events         []Event           `json:"events,omitempty"` // Only when a single incident is asked for.
+}
+
+// Event is something that happened in an incident.
+type Event struct {
+	At     time.Time `json:"at"`
+	Kind   EventKind `json:"kind"`
+	Author string    `json:"author,omitempty"`
+	Note   string    `json:"note,omitempty"`
+}
+
+const (
+	// openQuery opens an incident, with its first events ($9) at the time of the failed check.
+	openQuery = `with opened as (
+	insert into incidents (url, site_group, owner, opened_at, first_error, last_error, error_class, last_failure_at, labels)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $10)
+	returning id
+)
+insert into incident_events (incident_id, ts, kind)
+select id, $4, unnest($9::varchar[]) from opened
+returning incident_id`
+
+	// failQuery records another failed check of an open incident.
+	failQuery = `update incidents set last_error = $2, error_class = $3, last_failure_at = $4 where id = $1`
+
+	// eventQuery adds an event to an incident, if it exists.
+	eventQuery = `insert into incident_events (incident_id, ts, kind, author, note)
+select id, $2, $3, nullif($4, ''), nullif($5, '') from incidents where id = $1`
+
+	// resolveQuery closes an incident.
+	resolveQuery = `with closed as (
+	update incidents set closed_at = $2 where id = $1 and closed_at is null
+	returning id
+)
+insert into incident_events (incident_id, ts, kind)
+select id, $2, 'resolved' from closed`
+
+	// acknowledgeQuery acknowledges an incident, unless someone already did.
+	acknowledgeQuery = `with acknowledged as (
+	update incidents set acknowledged_by = $2, acknowledged_at = $3 where id = $1 and acknowledged_at is null
+	returning id
+)
+insert into incident_events (incident_id, ts, kind, author, note)
+select id, $3, 'acknowledged', $2, nullif($4, '') from acknowledged`
+
+	// openIncidentsQuery finds the incidents that are still open, eg. from before a restart.
+	openIncidentsQuery = `select id, url from incidents where closed_at is null`
+
+	incidentColumns = `id, url, coalesce(site_group, ''), coalesce(owner, ''), opened_at, closed_at, coalesce(first_error, ''), coalesce(last_error, ''),
+	coalesce(error_class, ''), last_failure_at, coalesce(acknowledged_by, ''), acknowledged_at, labels`
+
+	// listQuery lists the newest incidents ($3 of them) of a URL ($1), in a state ($2), of a group ($4) and an owner ($5), unless they are empty,
+	// and with all the labels of $6, unless it's null.
+	listQuery = `select ` + incidentColumns + `
+from incidents
+where ($1::text = '' or url = $1) and ($2::text = '' or ($2 = 'open') = (closed_at is null))
+	and ($4::text = '' or site_group = $4) and ($5::text = '' or owner = $5) and ($6::jsonb is null or labels @> $6)
+order by opened_at desc, id desc
+limit $3`
+
+	getQuery = `select ` + incidentColumns + ` from incidents where id = $1`
+
+	eventsQuery = `select ts, kind, coalesce(author, ''), coalesce(note, '') from incident_events where incident_id = $1 order by ts, id`
+)
+
+// open is what the store remembers of an open incident.
+type open struct {
+	id       int64
+	notified bool
+}
+
+// Store records the incidents from the evaluated results, and serves them through the API.
+type Store struct {
+	db *sql.DB
+
+	mut  *sync.Mutex
+	open map[string]*open // by site, as [config.SiteElement.String] shows it, which is the URL of its messages
+}
+
+// New creates a new Store. It doesn't know about the incidents that are still open until [Store.Load] is called.
+func New(db *sql.DB) *Store {
+	return &Store{
+		db:   db,
+		mut:  &sync.Mutex{},
+		open: map[string]*open{},
+	}
+}
+
+// Load finds the incidents that are still open, so a site that recovers after a restart resolves the incident it had.
+func (s *Store) Load(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, openIncidentsQuery)
+	if err != nil {
+		return fmt.Errorf("querying open incidents: %w", err)
+	}
+	defer rows.Close()
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for rows.Next() {
+		var (
+			id  int64
+			url string
+		)
+
+		err := rows.Scan(&id, &url)
+		if err != nil {
+			return fmt.Errorf("scanning open incident: %w", err)
+		}
+
+		s.open[url] = &open{id: id, notified: true} // we can't know, and it's better not to say it twice
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("reading open incidents: %w", err)
+	}
+
+	return nil
+}
+
+// labels returns the labels as JSON, or nil if there are none.
+func labels(values map[string]string) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+
+	marshaled, _ := json.Marshal(values) //nolint:errcheck,errchkjson // It's only strings.
+
+	return marshaled
+}
+
+// errorText returns the error of a result, how much slower than usual it was if it's anomalous, or its class if there is no error, eg. for the results that were stored before there were errors.
+func errorText(message monitor.Message) string {
+	if message.Err != nil {
+		return message.Err.Error()
+	}
+
+	if message.Anomalous {
+		return fmt.Sprintf("slower than usual: took %v, usually %v", message.Duration, message.Baseline)
+	}
+
+	return string(message.ErrorClass)
+}
+
+// Record updates the incidents with an evaluated result. A failure or an anomalous result opens an incident, unless the site has one already or the failure is suppressed by an upstream site, or it's in a maintenance window.
+// A success at the usual speed resolves it, unless the site is flapping.
+func (s *Store) Record(ctx context.Context, message monitor.Message) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	incident, isOpen := s.open[message.URL]
+	unhealthy := message.ErrorClass != monitor.ErrorClassNone || message.Anomalous
+
+	switch {
+	case unhealthy && !isOpen:
+		if message.InMaintenance || message.SuppressedBy != "" {
+			return nil
+		}
+
+		kinds := []string{string(EventDetected)}
+		if message.Notified {
+			kinds = append(kinds, string(EventNotified))
+		}
+
+		var id int64
+
+		err := s.db.QueryRowContext(ctx, openQuery, message.URL, message.Group, message.Owner, message.Timestamp,
+			errorText(message), errorText(message), string(message.ErrorClass), message.Timestamp, pq.Array(kinds), labels(message.Labels)).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("opening incident: %w", err)
+		}
+
+		s.open[message.URL] = &open{id: id, notified: message.Notified}
+	case unhealthy:
+		_, err := s.db.ExecContext(ctx, failQuery, incident.id, errorText(message), string(message.ErrorClass), message.Timestamp)
+		if err != nil {
+			return fmt.Errorf("updating incident %d: %w", incident.id, err)
+		}
+
+		if message.Notified && !incident.notified {
+			_, err := s.db.ExecContext(ctx, eventQuery, incident.id, message.Timestamp, string(EventNotified), "", "")
+			if err != nil {
+				return fmt.Errorf("adding event to incident %d: %w", incident.id, err)
+			}
+
+			incident.notified = true
+		}
+	case isOpen && !message.Flapping:
+		_, err := s.db.ExecContext(ctx, resolveQuery, incident.id, message.Timestamp)
+		if err != nil {
+			return fmt.Errorf("resolving incident %d: %w", incident.id, err)
+		}
+
+		delete(s.open, message.URL)
+	}
+
+	return nil
+}
+
+// Consume consumes the message queue and records every message. Errors are logged, and the message is not retried.
+func (s *Store) Consume(ctx context.Context, messageQueue chan monitor.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-messageQueue:
+			err := s.Record(ctx, msg)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed recording incident.", slog.String("url", msg.URL), slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// scanner is a row or rows, whichever is being scanned.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanIncident scans the columns of [incidentColumns].
+func scanIncident(row scanner) (Incident, error) {
+	var (
+		incident                                Incident
+		closedAt, lastFailureAt, acknowledgedAt sql.NullTime
+		labels                                  []byte
+	)
+
+	err := row.Scan(&incident.ID, &incident.URL, &incident.Group, &incident.Owner, &incident.OpenedAt, &closedAt, &incident.FirstError, &incident.LastError,
+		&incident.ErrorClass, &lastFailureAt, &incident.AcknowledgedBy, &acknowledgedAt, &labels)
+	if err != nil {
+		return Incident{}, err //nolint:wrapcheck // It's wrapped by the callers, which know what they were scanning.
+	}
+
+	if len(labels) > 0 {
+		err := json.Unmarshal(labels, &incident.Labels)
+		if err != nil {
+			return Incident{}, fmt.Errorf("decoding labels: %w", err)
+		}
+	}
+
+	incident.ClosedAt = closedAt.Time
+	incident.LastFailureAt = lastFailureAt.Time
+	incident.AcknowledgedAt = acknowledgedAt.Time
+
+	return incident, nil
+}
+
+// List lists the newest incidents that match the filter.
+func (s *Store) List(ctx context.Context, filter Filter) ([]Incident, error) {
+	rows, err := s.db.QueryContext(ctx, listQuery, filter.URL, string(filter.State), filter.Limit, filter.Group, filter.Owner, labels(filter.Labels))
+	if err != nil {
+		return nil, fmt.Errorf("querying incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+
+	for rows.Next() {
+		incident, err := scanIncident(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning incident: %w", err)
+		}
+
+		incidents = append(incidents, incident)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("reading incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// Get returns an incident with its timeline.
+func (s *Store) Get(ctx context.Context, id int64) (Incident, error) {
+	incident, err := scanIncident(s.db.QueryRowContext(ctx, getQuery, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Incident{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	if err != nil {
+		return Incident{}, fmt.Errorf("querying incident %d: %w", id, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, eventsQuery, id)
+	if err != nil {
+		return Incident{}, fmt.Errorf("querying events of incident %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	incident.Events = []Event{}
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(&event.At, &event.Kind, &event.Author, &event.Note)
+		if err != nil {
+			return Incident{}, fmt.Errorf("scanning event of incident %d: %w", id, err)
+		}
+
+		incident.Events = append(incident.Events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return Incident{}, fmt.Errorf("reading events of incident %d: %w", id, err)
+	}
+
+	return incident, nil
+}
+
+// Acknowledge says that someone is handling an incident, with an optional note. It can only be acknowledged once, and then it's clear who to talk to.
+func (s *Store) Acknowledge(ctx context.Context, id int64, author, note string) (Incident, error) {
+	result, err := s.db.ExecContext(ctx, acknowledgeQuery, id, author, time.Now(), note)
+	if err != nil {
+		return Incident{}, fmt.Errorf("acknowledging incident %d: %w", id, err)
+	}
+
+	acknowledged, err := result.RowsAffected()
+	if err != nil {
+		return Incident{}, fmt.Errorf("acknowledging incident %d: %w", id, err)
+	}
+
+	incident, err := s.Get(ctx, id)
+	if err != nil {
+		return Incident{}, err
+	}
+
+	if acknowledged == 0 {
+		return incident, fmt.Errorf("%w by %v", ErrAlreadyAcknowledged, incident.AcknowledgedBy)
+	}
+
+	return incident, nil
+}
+
+// Annotate adds a note to the timeline of an incident, eg. what was tried already.
+func (s *Store) Annotate(ctx context.Context, id int64, author, note string) (Incident, error) {
+	result, err := s.db.ExecContext(ctx, eventQuery, id, time.Now(), string(EventNote), author, note)
+	if err != nil {
+		return Incident{}, fmt.Errorf("annotating incident %d: %w", id, err)
+	}
+
+	annotated, err := result.RowsAffected()
+	if err != nil {
+		return Incident{}, fmt.Errorf("annotating incident %d: %w", id, err)
+	}
+
+	if annotated == 0 {
+		return Incident{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+
+	return s.Get(ctx, id)
+}
@@ -0,0 +1,383 @@
+package incident_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/incident"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+const shop = "https://shop.example.org"
+
+var (
+	openQuery        = regexp.QuoteMeta("insert into incidents")
+	failQuery        = regexp.QuoteMeta("update incidents set last_error")
+	eventQuery       = regexp.QuoteMeta("insert into incident_events (incident_id, ts, kind, author, note)\nselect id, $2, $3")
+	resolveQuery     = regexp.QuoteMeta("update incidents set closed_at")
+	acknowledgeQuery = regexp.QuoteMeta("update incidents set acknowledged_by")
+	listQuery        = regexp.QuoteMeta("order by opened_at desc")
+	getQuery         = regexp.QuoteMeta("from incidents where id = $1")
+	eventsQuery      = regexp.QuoteMeta("from incident_events where incident_id = $1")
+
+	incidentColumns = []string{"id", "url", "site_group", "owner", "opened_at", "closed_at", "first_error", "last_error", "error_class", "last_failure_at", "acknowledged_by", "acknowledged_at", "labels"}
+	eventColumns    = []string{"ts", "kind", "author", "note"}
+
+	now = time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+)
+
+func down(at time.Time) monitor.Message {
+	return monitor.Message{URL: shop, Group: "shop", Owner: "web-team", Labels: map[string]string{"env": "prod"}, Timestamp: at, ErrorClass: monitor.ErrorClassTimeout}
+}
+
+func up(at time.Time) monitor.Message {
+	return monitor.Message{URL: shop, Timestamp: at}
+}
+
+func TestStore_Record(t *testing.T) {
+	notified := down(now)
+	notified.Notified = true
+
+	notifiedLater := down(now.Add(time.Minute))
+	notifiedLater.Notified = true
+
+	suppressed := down(now)
+	suppressed.SuppressedBy = "https://cdn.example.org"
+
+	inMaintenance := down(now)
+	inMaintenance.InMaintenance = true
+
+	flapping := up(now.Add(time.Minute))
+	flapping.Flapping = true
+
+	slow := up(now)
+	slow.Group, slow.Owner = "shop", "web-team"
+	slow.Anomalous = true
+	slow.Notified = true
+	slow.Duration = 2 * time.Second
+	slow.Baseline = 200 * time.Millisecond
+
+	tests := []struct {
+		name           string
+		messages       []monitor.Message
+		dbExpectations func(mock sqlmock.Sqlmock)
+		wantErr        bool
+	}{
+		{
+			name:     "detected, notified and resolved",
+			messages: []monitor.Message{notified, down(now.Add(time.Minute)), up(now.Add(2 * time.Minute)), up(now.Add(3 * time.Minute))},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(openQuery).
+					WithArgs(shop, "shop", "web-team", now, "timeout", "timeout", "timeout", now, pq.Array([]string{"detected", "notified"}), []byte(`{"env":"prod"}`)).
+					WillReturnRows(sqlmock.NewRows([]string{"incident_id"}).AddRow(1).AddRow(1))
+				mock.ExpectExec(failQuery).WithArgs(1, "timeout", "timeout", now.Add(time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(resolveQuery).WithArgs(1, now.Add(2*time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "slower than usual, then down, then back to its usual speed",
+			messages: []monitor.Message{slow, down(now.Add(time.Minute)), up(now.Add(2 * time.Minute))},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(openQuery).
+					WithArgs(shop, "shop", "web-team", now, "slower than usual: took 2s, usually 200ms", "slower than usual: took 2s, usually 200ms", "", now,
+						pq.Array([]string{"detected", "notified"}), []byte(nil)).
+					WillReturnRows(sqlmock.NewRows([]string{"incident_id"}).AddRow(1).AddRow(1))
+				mock.ExpectExec(failQuery).WithArgs(1, "timeout", "timeout", now.Add(time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(resolveQuery).WithArgs(1, now.Add(2*time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "notified later",
+			messages: []monitor.Message{down(now), notifiedLater},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(openQuery).WithArgs(shop, "shop", "web-team", now, "timeout", "timeout", "timeout", now, pq.Array([]string{"detected"}), []byte(`{"env":"prod"}`)).
+					WillReturnRows(sqlmock.NewRows([]string{"incident_id"}).AddRow(1))
+				mock.ExpectExec(failQuery).WithArgs(1, "timeout", "timeout", now.Add(time.Minute)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(eventQuery).WithArgs(1, now.Add(time.Minute), "notified", "", "").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "not resolved while flapping",
+			messages: []monitor.Message{notified, flapping},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(openQuery).WillReturnRows(sqlmock.NewRows([]string{"incident_id"}).AddRow(1))
+			},
+		},
+		{
+			name:           "suppressed, in maintenance or up",
+			messages:       []monitor.Message{suppressed, inMaintenance, up(now)},
+			dbExpectations: func(sqlmock.Sqlmock) {},
+		},
+		{
+			name:     "failed query",
+			messages: []monitor.Message{notified},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(openQuery).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			store := incident.New(db)
+
+			var errs []error
+
+			for _, message := range tt.messages {
+				err := store.Record(context.Background(), message)
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			assert.Truef(t, len(errs) > 0 == tt.wantErr, "wanted err to be %v, but got errors %v", tt.wantErr, errs)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStore_Load(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("where closed_at is null")).WillReturnRows(sqlmock.NewRows([]string{"id", "url"}).AddRow(7, shop))
+	mock.ExpectExec(resolveQuery).WithArgs(7, now).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := incident.New(db)
+	require.NoError(t, store.Load(context.Background()))
+
+	// the incident from before the restart is resolved, and not opened again
+	require.NoError(t, store.Record(context.Background(), up(now)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStore_API(t *testing.T) {
+	opened := sqlmock.NewRows(incidentColumns).AddRow(1, shop, "shop", "web-team", now, nil, "timeout", "connection refused", "connection_refused", now.Add(time.Minute), "", nil, nil)
+	acknowledged := func() *sqlmock.Rows {
+		return sqlmock.NewRows(incidentColumns).AddRow(1, shop, "shop", "web-team", now, nil, "timeout", "timeout", "timeout", now, "alice", now.Add(time.Minute), []byte(`{"env":"prod"}`))
+	}
+	timeline := func(events ...[]driver.Value) *sqlmock.Rows {
+		rows := sqlmock.NewRows(eventColumns).AddRow(now, "detected", "", "").AddRow(now, "notified", "", "")
+		for _, event := range events {
+			rows.AddRow(event...)
+		}
+
+		return rows
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		dbExpectations func(mock sqlmock.Sqlmock)
+		wantStatus     int
+		wantBody       string
+	}{
+		{
+			name:   "list",
+			method: http.MethodGet,
+			path:   "/api/incidents?state=open&url=" + shop,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).WithArgs(shop, "open", incident.DefaultLimit, "", "", []byte(nil)).WillReturnRows(opened)
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `[{"id":1,"url":"https://shop.example.org","group":"shop","owner":"web-team","opened_at":"2026-10-19T10:00:00Z","first_error":"timeout",` +
+				`"last_error":"connection refused","error_class":"connection_refused","last_failure_at":"2026-10-19T10:01:00Z"}]` + "\n",
+		},
+		{
+			name:   "list by group, owner and labels",
+			method: http.MethodGet,
+			path:   "/api/incidents?group=shop&owner=web-team&label=env:prod&label=tier:1",
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).WithArgs("", "", incident.DefaultLimit, "shop", "web-team", []byte(`{"env":"prod","tier":"1"}`)).
+					WillReturnRows(sqlmock.NewRows(incidentColumns).AddRow(1, shop, "shop", "web-team", now, nil, "timeout", "timeout", "timeout", now, "", nil, []byte(`{"env":"prod","tier":"1"}`)))
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `[{"id":1,"url":"https://shop.example.org","group":"shop","owner":"web-team","labels":{"env":"prod","tier":"1"},"opened_at":"2026-10-19T10:00:00Z",` +
+				`"first_error":"timeout","last_error":"timeout","error_class":"timeout","last_failure_at":"2026-10-19T10:00:00Z"}]` + "\n",
+		},
+		{
+			name:           "invalid label",
+			method:         http.MethodGet,
+			path:           "/api/incidents?label=prod",
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"the label must be name:value: \"prod\""}` + "\n",
+		},
+		{
+			name:           "unknown state",
+			method:         http.MethodGet,
+			path:           "/api/incidents?state=pending",
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"unknown incident state: \"pending\""}` + "\n",
+		},
+		{
+			name:           "invalid limit",
+			method:         http.MethodGet,
+			path:           "/api/incidents?limit=0",
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"the limit must be a number between 1 and 1000: \"0\""}` + "\n",
+		},
+		{
+			name:   "get",
+			method: http.MethodGet,
+			path:   "/api/incidents/1",
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getQuery).WithArgs(1).WillReturnRows(acknowledged())
+				mock.ExpectQuery(eventsQuery).WithArgs(1).WillReturnRows(timeline([]driver.Value{now.Add(time.Minute), "acknowledged", "alice", "looking into it"}))
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"id":1,"url":"https://shop.example.org","group":"shop","owner":"web-team","labels":{"env":"prod"},"opened_at":"2026-10-19T10:00:00Z","first_error":"timeout",` +
+				`"last_error":"timeout","error_class":"timeout","last_failure_at":"2026-10-19T10:00:00Z","acknowledged_by":"alice","acknowledged_at":"2026-10-19T10:01:00Z",` +
+				`"events":[{"at":"2026-10-19T10:00:00Z","kind":"detected"},{"at":"2026-10-19T10:00:00Z","kind":"notified"},` +
+				`{"at":"2026-10-19T10:01:00Z","kind":"acknowledged","author":"alice","note":"looking into it"}]}` + "\n",
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/api/incidents/2",
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(getQuery).WithArgs(2).WillReturnRows(sqlmock.NewRows(incidentColumns))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"no incident with id: 2"}` + "\n",
+		},
+		{
+			name:           "invalid id",
+			method:         http.MethodGet,
+			path:           "/api/incidents/latest",
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"invalid incident id: \"latest\""}` + "\n",
+		},
+		{
+			name:   "acknowledge",
+			method: http.MethodPost,
+			path:   "/api/incidents/1/acknowledge",
+			body:   `{"author": "alice", "note": "looking into it"}`,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(acknowledgeQuery).WithArgs(1, "alice", sqlmock.AnyArg(), "looking into it").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(getQuery).WithArgs(1).WillReturnRows(acknowledged())
+				mock.ExpectQuery(eventsQuery).WithArgs(1).WillReturnRows(timeline())
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "already acknowledged",
+			method: http.MethodPost,
+			path:   "/api/incidents/1/acknowledge",
+			body:   `{"author": "bob"}`,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(acknowledgeQuery).WithArgs(1, "bob", sqlmock.AnyArg(), "").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(getQuery).WithArgs(1).WillReturnRows(acknowledged())
+				mock.ExpectQuery(eventsQuery).WithArgs(1).WillReturnRows(timeline())
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"error":"the incident is already acknowledged by alice"}` + "\n",
+		},
+		{
+			name:           "no author",
+			method:         http.MethodPost,
+			path:           "/api/incidents/1/acknowledge",
+			body:           `{"note": "looking into it"}`,
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"the author is needed, so on-call knows who to talk to"}` + "\n",
+		},
+		{
+			name:   "annotate",
+			method: http.MethodPost,
+			path:   "/api/incidents/1/notes",
+			body:   `{"author": "alice", "note": "rolled back the deploy"}`,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(eventQuery).WithArgs(1, sqlmock.AnyArg(), "note", "alice", "rolled back the deploy").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(getQuery).WithArgs(1).WillReturnRows(acknowledged())
+				mock.ExpectQuery(eventsQuery).WithArgs(1).WillReturnRows(timeline())
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:   "annotate unknown incident",
+			method: http.MethodPost,
+			path:   "/api/incidents/2/notes",
+			body:   `{"author": "alice", "note": "rolled back the deploy"}`,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(eventQuery).WithArgs(2, sqlmock.AnyArg(), "note", "alice", "rolled back the deploy").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"no incident with id: 2"}` + "\n",
+		},
+		{
+			name:           "empty note",
+			method:         http.MethodPost,
+			path:           "/api/incidents/1/notes",
+			body:           `{"author": "alice"}`,
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			wantStatus:     http.StatusBadRequest,
+			wantBody:       `{"error":"the note is empty"}` + "\n",
+		},
+		{
+			name:   "failed query",
+			method: http.MethodGet,
+			path:   "/api/incidents",
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(listQuery).WithArgs("", "", incident.DefaultLimit, "", "", []byte(nil)).WillReturnError(assert.AnError)
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"error":"querying incidents: ` + assert.AnError.Error() + `"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			store := incident.New(db)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/incidents", store.ServeList)
+			mux.HandleFunc("GET /api/incidents/{id}", store.ServeGet)
+			mux.HandleFunc("POST /api/incidents/{id}/acknowledge", store.ServeAcknowledge)
+			mux.HandleFunc("POST /api/incidents/{id}/notes", store.ServeAnnotate)
+
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, recorder.Body.String())
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// Exit codes of lint.
+const (
+	lintOK       = 0
+	lintFindings = 1 // The site list has problems.
+	lintFailed   = 2 // The site list could not be read at all, or the arguments are wrong.
+)
+
+// lint loads a site list like the monitor would, and prints every problem with it: validation errors, and what [config.Normalize] finds.
+// It returns the exit code, so it can be used in CI: anything but lintOK means that there is something to fix.
+//
+// Usage: go-monitor lint [-duplicates first|last|error] [-min-interval seconds] [-max-interval seconds] [location]. The location defaults to FILE_URL,
+// and the flags to DUPLICATE_POLICY, MIN_INTERVAL_SECONDS and MAX_INTERVAL_SECONDS, so it's linted with the same settings as the monitor that uses it.
+func lint(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	normalization, err := config.ParseNormalizationEnv()
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error()) //nolint:errcheck // There is nowhere else to write it.
+
+		return lintFailed
+	}
+
+	policy := normalization.DuplicatePolicy
+	flags.Var(&policy, "duplicates", "which duplicate is kept: first, last or error")
+
+	limits := normalization.Limits()
+	flags.IntVar(&limits.MinIntervalSeconds, "min-interval", limits.MinIntervalSeconds, "shortest interval, in seconds")
+	flags.IntVar(&limits.MaxIntervalSeconds, "max-interval", limits.MaxIntervalSeconds, "longest interval, in seconds")
+
+	err = flags.Parse(args)
+	if err != nil {
+		return lintFailed
+	}
+
+	err = limits.Validate()
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error()) //nolint:errcheck // There is nowhere else to write it.
+
+		return lintFailed
+	}
+
+	location := flags.Arg(0)
+	if location == "" {
+		location = os.Getenv("FILE_URL")
+	}
+
+	if location == "" {
+		fmt.Fprintln(stderr, "Nothing to lint: pass a location or set FILE_URL.") //nolint:errcheck // There is nowhere else to write it.
+
+		return lintFailed
+	}
+
+	sites, err := config.Load(ctx, cleanhttp.DefaultClient(), location)
+	if err != nil {
+		var validationErrors config.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			fmt.Fprintf(stderr, "Failed reading %v: %v\n", location, err) //nolint:errcheck // There is nowhere else to write it.
+
+			return lintFailed
+		}
+
+		for _, validationError := range validationErrors {
+			fmt.Fprintf(stdout, "error: %v\n", validationError.Error()) //nolint:errcheck // There is nowhere else to write it.
+		}
+
+		fmt.Fprintf(stdout, "%d errors.\n", len(validationErrors)) //nolint:errcheck // There is nowhere else to write it.
+
+		return lintFindings
+	}
+
+	normalizedSites, findings := config.Normalize(sites, policy, limits)
+
+	for _, finding := range findings {
+		fmt.Fprintln(stdout, finding.String()) //nolint:errcheck // There is nowhere else to write it.
+	}
+
+	errs := len(findings.Errors())
+
+	fmt.Fprintf(stdout, "%d sites (%d after removing duplicates), %d errors, %d warnings.\n", len(sites), len(normalizedSites), errs, len(findings)-errs) //nolint:errcheck // There is nowhere else to write it.
+
+	if len(findings) > 0 {
+		return lintFindings
+	}
+
+	return lintOK
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, contents string) string {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(filename, []byte(contents), 0o600))
+
+		return filename
+	}
+
+	clean := write("clean.yaml", "- url: https://example.org\n  interval_seconds: 60\n")
+	duplicates := write("duplicates.yaml", "- url: https://example.org\n  interval_seconds: 60\n- url: https://EXAMPLE.org:443\n  interval_seconds: 60\n")
+	invalid := write("invalid.yaml", "- url: https://example.org\n  interval_seconds: sixty\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		wantCode   int
+		wantOutput string
+	}{
+		{
+			name:       "clean",
+			args:       []string{clean},
+			wantCode:   lintOK,
+			wantOutput: "1 sites (1 after removing duplicates), 0 errors, 0 warnings.\n",
+		},
+		{
+			name:     "duplicates",
+			args:     []string{duplicates},
+			wantCode: lintFindings,
+			wantOutput: "warning: site 1 (https://EXAMPLE.org:443): duplicate of site 0, so it's skipped\n" +
+				"2 sites (1 after removing duplicates), 0 errors, 1 warnings.\n",
+		},
+		{
+			name:     "duplicates are errors",
+			args:     []string{"-duplicates", "error", duplicates},
+			wantCode: lintFindings,
+			wantOutput: "error: site 1 (https://EXAMPLE.org:443): duplicate of site 0\n" +
+				"2 sites (1 after removing duplicates), 1 errors, 0 warnings.\n",
+		},
+		{
+			name:     "configured limits",
+			args:     []string{"-max-interval", "30", clean},
+			wantCode: lintFindings,
+			wantOutput: "warning: site 0 (https://example.org): interval of 60s is above the maximum, it's checked every 30s\n" +
+				"1 sites (1 after removing duplicates), 0 errors, 1 warnings.\n",
+		},
+		{
+			name:     "limits of the environment",
+			args:     []string{clean},
+			env:      map[string]string{"MAX_INTERVAL_SECONDS": "30"},
+			wantCode: lintFindings,
+			wantOutput: "warning: site 0 (https://example.org): interval of 60s is above the maximum, it's checked every 30s\n" +
+				"1 sites (1 after removing duplicates), 0 errors, 1 warnings.\n",
+		},
+		{
+			name:     "policy of the environment",
+			args:     []string{duplicates},
+			env:      map[string]string{"DUPLICATE_POLICY": "error"},
+			wantCode: lintFindings,
+			wantOutput: "error: site 1 (https://EXAMPLE.org:443): duplicate of site 0\n" +
+				"2 sites (1 after removing duplicates), 1 errors, 0 warnings.\n",
+		},
+		{
+			name:       "flags win over the environment",
+			args:       []string{"-max-interval", "300", "-duplicates", "first", clean},
+			env:        map[string]string{"MAX_INTERVAL_SECONDS": "30", "DUPLICATE_POLICY": "error"},
+			wantCode:   lintOK,
+			wantOutput: "1 sites (1 after removing duplicates), 0 errors, 0 warnings.\n",
+		},
+		{
+			name:     "invalid limits of the environment",
+			args:     []string{clean},
+			env:      map[string]string{"MIN_INTERVAL_SECONDS": "600"},
+			wantCode: lintFailed,
+		},
+		{
+			name:     "invalid limits",
+			args:     []string{"-min-interval", "0", clean},
+			wantCode: lintFailed,
+		},
+		{
+			name:     "invalid",
+			args:     []string{invalid},
+			wantCode: lintFindings,
+			wantOutput: "error: " + invalid + ":2: site 0: interval_seconds: got string, want integer\n" +
+				"1 errors.\n",
+		},
+		{
+			name:     "does not exist",
+			args:     []string{filepath.Join(dir, "nope.json")},
+			wantCode: lintFailed,
+		},
+		{
+			name:     "unknown policy",
+			args:     []string{"-duplicates", "newest", clean},
+			wantCode: lintFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FILE_URL", "")
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			stdout := &bytes.Buffer{}
+
+			assert.Equal(t, tt.wantCode, lint(context.Background(), tt.args, stdout, &bytes.Buffer{}))
+			assert.Equal(t, tt.wantOutput, stdout.String())
+		})
+	}
+}
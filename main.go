@@ -2,21 +2,178 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata" // the image has no time zones, and maintenance windows may need them
 
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
 
+	"github.com/pbabbicola/go-monitor/alert"
+	"github.com/pbabbicola/go-monitor/anomaly"
 	"github.com/pbabbicola/go-monitor/config"
 	"github.com/pbabbicola/go-monitor/consumers/batcher"
+	"github.com/pbabbicola/go-monitor/consumers/fanout"
+	"github.com/pbabbicola/go-monitor/consumers/metrics"
 	"github.com/pbabbicola/go-monitor/consumers/postgres"
+	"github.com/pbabbicola/go-monitor/incident"
+	"github.com/pbabbicola/go-monitor/maintenance"
 	"github.com/pbabbicola/go-monitor/monitor"
+	"github.com/pbabbicola/go-monitor/slo"
+	"github.com/pbabbicola/go-monitor/statuspage"
 )
 
+const shutdownTimeout = 5 * time.Second
+
+// serve serves the handler on the address until the context is done.
+func serve(ctx context.Context, address string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx) //nolint:errcheck,contextcheck // We are exiting anyway.
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listening on %v: %w", address, err)
+	}
+
+	return nil
+}
+
+// every sends to the returned channel every interval, until the context is done. Ticks are dropped if the previous one was not received yet.
+func every(ctx context.Context, interval time.Duration) <-chan struct{} {
+	ticks := make(chan struct{}, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case ticks <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return ticks
+}
+
+// siteSetter is anything else that needs the site list, eg. [alert.Evaluator].
+type siteSetter interface {
+	SetSites(sites []config.SiteElement)
+}
+
+// monitorSites runs a monitor for every site until the context is done. When the configuration is reloaded, all the monitors are restarted with the new site list, and the maintenance windows and the setters get it too.
+// The monitorer is the same across reloads, so the sites that detect changes are compared with their last body.
+// Sites in a maintenance window that skips the checks are not checked.
+func monitorSites(ctx context.Context, monitorer *monitor.DefaultMonitorer, load loader, sites []config.SiteElement, limits config.Limits, reloads <-chan struct{}, windows *maintenance.Windows, setters ...siteSetter) {
+	for {
+		windows.SetSites(sites)
+
+		for _, setter := range setters {
+			setter.SetSites(sites)
+		}
+
+		sitesCtx, cancel := context.WithCancel(ctx)
+
+		var wg sync.WaitGroup
+		for _, website := range sites {
+			wg.Go(func() {
+				monitor.Ticks(sitesCtx, website, limits, windows.Skip(monitorer.Monitor))
+			})
+		}
+
+		reloaded, ok := reload(ctx, load, reloads)
+
+		cancel()
+		wg.Wait()
+
+		if !ok {
+			return
+		}
+
+		sites = reloaded
+	}
+}
+
+// loader loads the site list, eg. [config.Load] or [config.Remote.Fetch].
+type loader func(ctx context.Context) ([]config.SiteElement, error)
+
+// normalized wraps a loader, so every site list it loads is normalized. Warnings are logged, and errors fail the load like an invalid site list does.
+func normalized(load loader, policy config.DuplicatePolicy, limits config.Limits) loader {
+	return func(ctx context.Context) ([]config.SiteElement, error) {
+		sites, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		sites, findings := config.Normalize(sites, policy, limits)
+
+		for _, finding := range findings {
+			if finding.Severity == config.SeverityWarning {
+				slog.WarnContext(ctx, "Configuration warning.", slog.Int("site", finding.Index), slog.String("url", finding.Site), slog.String("warning", finding.Message))
+			}
+		}
+
+		if errs := findings.Errors(); len(errs) > 0 {
+			return nil, errs
+		}
+
+		return sites, nil
+	}
+}
+
+// reload waits for the configuration to change and loads it. If the new configuration is not valid, the error is logged and it keeps waiting, so the previous one keeps running.
+// It returns false when the context is done.
+func reload(ctx context.Context, load loader, reloads <-chan struct{}) ([]config.SiteElement, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-reloads:
+			sites, err := load(ctx)
+			if errors.Is(err, config.ErrNotModified) {
+				slog.DebugContext(ctx, "Configuration not modified.")
+
+				continue
+			}
+
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed reloading configuration, keeping the previous one.", slog.String("error", err.Error()))
+
+				continue
+			}
+
+			slog.InfoContext(ctx, "Configuration reloaded.", slog.Int("sites", len(sites)))
+
+			return sites, true
+		}
+	}
+}
+
 func run(envConfig *config.EnvConfig) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -34,11 +191,45 @@ func run(envConfig *config.EnvConfig) error {
 
 	client := cleanhttp.DefaultClient() // This sets sensible defaults for the client.
 
-	cfg, err := config.ParseRemote(ctx, client, envConfig.FileURL)
+	load := func(ctx context.Context) ([]config.SiteElement, error) {
+		return config.Load(ctx, client, envConfig.FileURL)
+	}
+
+	path, local := config.LocalPath(envConfig.FileURL)
+	if !local {
+		var publicKey ed25519.PublicKey
+
+		if envConfig.ConfigPublicKey != "" {
+			var err error
+
+			publicKey, err = config.ParsePublicKey(envConfig.ConfigPublicKey)
+			if err != nil {
+				return fmt.Errorf("parsing configuration public key: %w", err)
+			}
+		}
+
+		load = config.NewRemote(client, envConfig.FileURL, envConfig.ConfigCacheFile, envConfig.ConfigMaxBytes, publicKey).Fetch
+	}
+
+	load = normalized(load, envConfig.DuplicatePolicy, envConfig.Limits())
+
+	cfg, err := load(ctx)
 	if err != nil {
 		return fmt.Errorf("parsing configuration: %w", err)
 	}
 
+	var reloads <-chan struct{} // if nothing is set to reload the configuration, it blocks forever
+
+	switch {
+	case local:
+		reloads, err = config.Watch(ctx, path)
+		if err != nil {
+			return fmt.Errorf("watching configuration: %w", err)
+		}
+	case envConfig.ConfigRefreshSeconds > 0:
+		reloads = every(ctx, time.Duration(envConfig.ConfigRefreshSeconds)*time.Second)
+	}
+
 	pool, err := postgres.NewConsumer(ctx, envConfig.DatabaseURL)
 	if err != nil {
 		return fmt.Errorf("creating postgres consumer: %w", err)
@@ -53,18 +244,124 @@ func run(envConfig *config.EnvConfig) error {
 	}
 
 	messageQueue := make(chan monitor.Message)
+	detectedQueue := make(chan monitor.Message)
+	evaluatedQueue := make(chan monitor.Message)
+	batcherQueue := make(chan monitor.Message)
+	metricsQueue := make(chan monitor.Message)
+	incidentQueue := make(chan monitor.Message, len(cfg)) // incidents are written one by one, so a slow database shouldn't hold back the checks
+
+	metricsConsumer := metrics.New()
+	windows := maintenance.New()
+	evaluator := alert.New(windows)
+	calculator := slo.New(pool.DB(), alert.NotifySLO)
+	detector := anomaly.New(pool.DB())
+	incidents := incident.New(pool.DB())
+
+	err = incidents.Load(ctx)
+	if err != nil { // the sites that are down now open new incidents, it's not worth not monitoring anything
+		slog.ErrorContext(ctx, "Failed loading open incidents.", slog.String("error", err.Error()))
+	}
+
+	monitorer := monitor.NewDefaultMonitorer(client, messageQueue)
+
+	err = monitorer.LoadFingerprints(ctx, pool.DB(), cfg)
+	if err != nil { // the first check of those sites only sets the baseline then
+		slog.ErrorContext(ctx, "Failed loading the last body hashes.", slog.String("error", err.Error()))
+	}
+
+	metricsConsumer.Register(calculator)
 
 	var wg sync.WaitGroup
-	for _, website := range cfg { // values don't need to be copied over for correct concurrency since go 1.21
+
+	setters := []siteSetter{evaluator, calculator, detector, metricsConsumer}
+
+	calculator.SetSites(cfg) // monitorSites sets them too, but the first update may run before
+	detector.SetSites(cfg)
+
+	if envConfig.StatusPageAddress != "" {
+		page := statuspage.New(pool.DB(), envConfig.StatusPageTitle, time.Duration(envConfig.StatusPageRefreshSeconds)*time.Second)
+		page.SetSites(cfg)
+
+		setters = append(setters, page)
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /{$}", page)
+
+		wg.Go(func() {
+			page.Run(ctx)
+		})
+
+		wg.Go(func() {
+			err := serve(ctx, envConfig.StatusPageAddress, mux)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed serving the status page.", slog.String("error", err.Error()))
+				cancel()
+			}
+		})
+	}
+
+	wg.Go(func() {
+		monitorSites(ctx, monitorer, load, cfg, envConfig.Limits(), reloads, windows, setters...)
+	})
+
+	if envConfig.SLORefreshSeconds > 0 {
 		wg.Go(func() {
-			monitor.Ticks(ctx, website, monitor.NewDefaultMonitorer(client, messageQueue).Monitor)
+			calculator.Run(ctx, time.Duration(envConfig.SLORefreshSeconds)*time.Second)
 		})
 	}
 
+	if envConfig.AnomalyRefreshSeconds > 0 {
+		wg.Go(func() {
+			detector.Run(ctx, time.Duration(envConfig.AnomalyRefreshSeconds)*time.Second)
+		})
+	}
+
+	wg.Go(func() {
+		detector.Consume(ctx, messageQueue, detectedQueue)
+	})
+
+	wg.Go(func() {
+		evaluator.Consume(ctx, detectedQueue, evaluatedQueue)
+	})
+
+	wg.Go(func() {
+		fanout.Consume(ctx, evaluatedQueue, batcherQueue, metricsQueue, incidentQueue)
+	})
+
 	wg.Go(func() {
-		batch.Consume(ctx, messageQueue)
+		batch.Consume(ctx, batcherQueue)
 	})
 
+	wg.Go(func() {
+		metricsConsumer.Consume(ctx, metricsQueue)
+	})
+
+	wg.Go(func() {
+		incidents.Consume(ctx, incidentQueue)
+	})
+
+	if envConfig.HTTPAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metricsConsumer)
+		mux.HandleFunc("GET /api/maintenance", windows.ServeList)
+		mux.HandleFunc("GET /api/maintenance/sites", windows.ServeSites)
+		mux.HandleFunc("POST /api/maintenance", authorized(envConfig.APIToken, windows.ServeAdd))
+		mux.HandleFunc("DELETE /api/maintenance/{id}", authorized(envConfig.APIToken, windows.ServeRemove))
+		mux.Handle("GET /api/slos", calculator)
+		mux.HandleFunc("GET /api/incidents", incidents.ServeList)
+		mux.HandleFunc("GET /api/incidents/{id}", incidents.ServeGet)
+		mux.HandleFunc("POST /api/incidents/{id}/acknowledge", authorized(envConfig.APIToken, incidents.ServeAcknowledge))
+		mux.HandleFunc("POST /api/incidents/{id}/notes", authorized(envConfig.APIToken, incidents.ServeAnnotate))
+
+		wg.Go(func() {
+			err := serve(ctx, envConfig.HTTPAddress, mux)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed serving HTTP.", slog.String("error", err.Error()))
+				cancel()
+			}
+		})
+	}
+
 	wg.Go(func() {
 		pool.Consume(ctx, batchQueue)
 	})
@@ -75,6 +372,15 @@ func run(envConfig *config.EnvConfig) error {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(lint(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+		case "report":
+			os.Exit(generateReport(context.Background(), os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	envConfig, err := config.ParseEnv()
 	if err != nil {
 		panic(err)
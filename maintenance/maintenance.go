@@ -0,0 +1,242 @@
+// Package maintenance keeps the maintenance windows of the sites: the ones in the site list, and the ones added at runtime through the API.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/httpjson"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+var (
+	// ErrNoSelector is returned when a window of the API doesn't say which sites it applies to.
+	ErrNoSelector = errors.New("the window needs a url, a group or labels")
+	// ErrNotFound is returned when there is no window with an ID.
+	ErrNotFound = errors.New("no maintenance window with id")
+)
+
+// Window is a maintenance window added through the API. It applies to every site that matches all of URL, Group and Labels that are set.
+// The URL is the one in the results, so secrets in it are [config.Redacted].
+type Window struct {
+	ID     string            `json:"id"`
+	URL    string            `json:"url"`
+	Group  string            `json:"group"`
+	Labels map[string]string `json:"labels"`
+	config.MaintenanceWindow
+}
+
+// Validate checks that the window applies to some site, and that the window itself is valid.
+func (w Window) Validate() error {
+	if w.URL == "" && w.Group == "" && len(w.Labels) == 0 {
+		return ErrNoSelector
+	}
+
+	return w.MaintenanceWindow.Validate() //nolint:wrapcheck // It's already clear enough.
+}
+
+// matches returns whether the window applies to a site.
+func (w Window) matches(url, group string, labels map[string]string) bool {
+	if w.URL != "" && w.URL != url {
+		return false
+	}
+
+	if w.Group != "" && w.Group != group {
+		return false
+	}
+
+	for name, value := range w.Labels {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Windows keeps all the maintenance windows. The ones added through the API are only kept in memory, so they are lost on restart.
+type Windows struct {
+	mut     *sync.Mutex
+	sites   map[string][]config.MaintenanceWindow // by site, as [config.SiteElement.String] shows it
+	windows []Window
+	lastID  int
+}
+
+// New creates a new Windows, without any window.
+func New() *Windows {
+	return &Windows{
+		mut:   &sync.Mutex{},
+		sites: map[string][]config.MaintenanceWindow{},
+	}
+}
+
+// SetSites sets the windows of the site list, eg. after it's reloaded. The windows of the API are kept.
+func (w *Windows) SetSites(sites []config.SiteElement) {
+	windows := make(map[string][]config.MaintenanceWindow, len(sites))
+
+	for _, site := range sites {
+		if len(site.Maintenance) > 0 {
+			windows[site.String()] = site.Maintenance
+		}
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	w.sites = windows
+}
+
+// Add adds a window, and returns it with its ID.
+func (w *Windows) Add(window Window) (Window, error) {
+	err := window.Validate()
+	if err != nil {
+		return Window{}, err
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if window.Mode == "" {
+		window.Mode = config.MaintenanceModeFlag
+	}
+
+	w.lastID++
+	window.ID = strconv.Itoa(w.lastID)
+	w.windows = append(w.windows, window)
+
+	return window, nil
+}
+
+// Remove removes a window added with [Windows.Add]. It returns false if there is no window with that ID.
+func (w *Windows) Remove(id string) bool {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	index := slices.IndexFunc(w.windows, func(window Window) bool { return window.ID == id })
+	if index < 0 {
+		return false
+	}
+
+	w.windows = slices.Delete(w.windows, index, index+1)
+
+	return true
+}
+
+// List returns the windows added with [Windows.Add].
+func (w *Windows) List() []Window {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	return slices.Clone(w.windows)
+}
+
+// Active returns the maintenance window of a site that is active at a time, if there is any. If more than one is active, the first one that skips the checks wins.
+// The site is given by the URL, group and labels of its results, so it works for both sites and messages.
+func (w *Windows) Active(url, group string, labels map[string]string, at time.Time) (config.MaintenanceWindow, bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	var (
+		active config.MaintenanceWindow
+		found  bool
+	)
+
+	check := func(window config.MaintenanceWindow) bool {
+		if !window.Active(at) {
+			return false
+		}
+
+		if !found || window.Mode == config.MaintenanceModeSkip {
+			active = window
+			found = true
+		}
+
+		return active.Mode == config.MaintenanceModeSkip
+	}
+
+	for _, window := range w.sites[url] {
+		if check(window) {
+			return active, true
+		}
+	}
+
+	for _, window := range w.windows {
+		if window.matches(url, group, labels) && check(window.MaintenanceWindow) {
+			return active, true
+		}
+	}
+
+	return active, found
+}
+
+// Skip wraps a monitorer, so sites in a maintenance window that skips the checks are not checked.
+func (w *Windows) Skip(monitorer monitor.Monitorer) monitor.Monitorer {
+	return func(ctx context.Context, website config.SiteElement) error {
+		window, ok := w.Active(website.String(), website.Group, website.Labels, time.Now())
+		if ok && window.Mode == config.MaintenanceModeSkip {
+			slog.DebugContext(ctx, "Not checked, the site is in maintenance.", slog.String("url", website.String()), slog.String("reason", window.Reason))
+
+			return nil
+		}
+
+		return monitorer(ctx, website)
+	}
+}
+
+// ServeList lists the windows added through the API, eg. for GET /api/maintenance.
+func (w *Windows) ServeList(rw http.ResponseWriter, r *http.Request) {
+	httpjson.Write(r.Context(), rw, http.StatusOK, w.List())
+}
+
+// ServeSites lists the windows of every site in the site list, eg. for GET /api/maintenance/sites.
+func (w *Windows) ServeSites(rw http.ResponseWriter, r *http.Request) {
+	w.mut.Lock()
+	sites := maps.Clone(w.sites)
+	w.mut.Unlock()
+
+	httpjson.Write(r.Context(), rw, http.StatusOK, sites)
+}
+
+// ServeAdd adds the window in the body of the request, eg. for POST /api/maintenance. It responds with the window and its ID.
+func (w *Windows) ServeAdd(rw http.ResponseWriter, r *http.Request) {
+	var window Window
+
+	err := json.NewDecoder(r.Body).Decode(&window)
+	if err != nil {
+		httpjson.WriteError(r.Context(), rw, http.StatusBadRequest, fmt.Errorf("decoding window: %w", err))
+
+		return
+	}
+
+	window, err = w.Add(window)
+	if err != nil {
+		httpjson.WriteError(r.Context(), rw, http.StatusBadRequest, err)
+
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Maintenance window added.", slog.String("id", window.ID), slog.String("url", window.URL), slog.String("group", window.Group), slog.String("reason", window.Reason))
+
+	httpjson.Write(r.Context(), rw, http.StatusCreated, window)
+}
+
+// ServeRemove removes the window with the ID of the path, eg. for DELETE /api/maintenance/{id}.
+func (w *Windows) ServeRemove(rw http.ResponseWriter, r *http.Request) {
+	if !w.Remove(r.PathValue("id")) {
+		httpjson.WriteError(r.Context(), rw, http.StatusNotFound, fmt.Errorf("%w: %q", ErrNotFound, r.PathValue("id")))
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
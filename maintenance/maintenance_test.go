@@ -0,0 +1,158 @@
+package maintenance_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/maintenance"
+)
+
+// always is a window that is active for a long time.
+var always = config.MaintenanceWindow{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}
+
+func skipping(window config.MaintenanceWindow) config.MaintenanceWindow {
+	window.Mode = config.MaintenanceModeSkip
+
+	return window
+}
+
+func TestWindows_Active(t *testing.T) {
+	windows := maintenance.New()
+	windows.SetSites([]config.SiteElement{
+		{URL: "https://site.example", Maintenance: []config.MaintenanceWindow{always}},
+	})
+
+	_, err := windows.Add(maintenance.Window{Group: "shop", MaintenanceWindow: always})
+	require.NoError(t, err)
+	_, err = windows.Add(maintenance.Window{Labels: map[string]string{"env": "staging"}, MaintenanceWindow: skipping(always)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		url      string
+		group    string
+		labels   map[string]string
+		wantOK   bool
+		wantMode config.MaintenanceMode
+	}{
+		{name: "site window", url: "https://site.example", wantOK: true},
+		{name: "group window", url: "https://shop.example", group: "shop", wantOK: true, wantMode: config.MaintenanceModeFlag},
+		{name: "label window", url: "https://staging.example", labels: map[string]string{"env": "staging", "tier": "1"}, wantOK: true, wantMode: config.MaintenanceModeSkip},
+		{name: "skip wins", url: "https://shop.example", group: "shop", labels: map[string]string{"env": "staging"}, wantOK: true, wantMode: config.MaintenanceModeSkip},
+		{name: "no window", url: "https://other.example", group: "blog", labels: map[string]string{"env": "prod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, ok := windows.Active(tt.url, tt.group, tt.labels, time.Now())
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantMode, window.Mode)
+		})
+	}
+}
+
+func TestWindows_Skip(t *testing.T) {
+	windows := maintenance.New()
+	windows.SetSites([]config.SiteElement{
+		{URL: "https://skipped.example", Maintenance: []config.MaintenanceWindow{skipping(always)}},
+		{URL: "https://flagged.example", Maintenance: []config.MaintenanceWindow{always}},
+	})
+
+	checked := []string{}
+	monitorer := windows.Skip(func(_ context.Context, website config.SiteElement) error {
+		checked = append(checked, website.URL)
+
+		return nil
+	})
+
+	for _, url := range []string{"https://skipped.example", "https://flagged.example", "https://other.example"} {
+		require.NoError(t, monitorer(context.Background(), config.SiteElement{URL: url}))
+	}
+
+	assert.Equal(t, []string{"https://flagged.example", "https://other.example"}, checked)
+}
+
+func TestWindows_API(t *testing.T) {
+	windows := maintenance.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/maintenance", windows.ServeList)
+	mux.HandleFunc("POST /api/maintenance", windows.ServeAdd)
+	mux.HandleFunc("DELETE /api/maintenance/{id}", windows.ServeRemove)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		return recorder
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "add",
+			method:     http.MethodPost,
+			path:       "/api/maintenance",
+			body:       `{"group": "shop", "cron": "0 2 * * SUN", "duration_seconds": 3600, "time_zone": "Europe/Berlin", "reason": "database upgrade"}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"id":"1","url":"","group":"shop","labels":null,"cron":"0 2 * * SUN","duration_seconds":3600,"time_zone":"Europe/Berlin","mode":"flag","reason":"database upgrade"}` + "\n",
+		},
+		{
+			name:       "list",
+			method:     http.MethodGet,
+			path:       "/api/maintenance",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":"1","url":"","group":"shop","labels":null,"cron":"0 2 * * SUN","duration_seconds":3600,"time_zone":"Europe/Berlin","mode":"flag","reason":"database upgrade"}]` + "\n",
+		},
+		{
+			name:       "no selector",
+			method:     http.MethodPost,
+			path:       "/api/maintenance",
+			body:       `{"start": "2026-10-18T10:00:00Z", "end": "2026-10-18T12:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"the window needs a url, a group or labels"}` + "\n",
+		},
+		{
+			name:       "invalid cron",
+			method:     http.MethodPost,
+			path:       "/api/maintenance",
+			body:       `{"group": "shop", "cron": "sometimes", "duration_seconds": 60}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"decoding window: parsing cron expression \"sometimes\": expected exactly 5 fields, found 1: [sometimes]"}` + "\n",
+		},
+		{
+			name:       "remove",
+			method:     http.MethodDelete,
+			path:       "/api/maintenance/1",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "remove again",
+			method:     http.MethodDelete,
+			path:       "/api/maintenance/1",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"no maintenance window with id: \"1\""}` + "\n",
+		},
+	}
+	for _, tt := range tests { // in order, as they build on each other
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := request(tt.method, tt.path, tt.body)
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			assert.Equal(t, tt.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column check_type varchar not null default 'http',
+    add column grpc_serving_status varchar;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column check_type,
+    drop column grpc_serving_status;
+-- +goose StatementEnd
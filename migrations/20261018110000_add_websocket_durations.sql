@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column handshake_milliseconds bigint,
+    add column round_trip_milliseconds bigint;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column handshake_milliseconds,
+    drop column round_trip_milliseconds;
+-- +goose StatementEnd
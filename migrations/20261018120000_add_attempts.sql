@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column attempts integer not null default 1,
+    add column attempt_errors varchar[];
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column attempts,
+    drop column attempt_errors;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column error_class varchar;
+
+create index logs_error_class_ts on logs (error_class, ts) where error_class <> '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index logs_error_class_ts;
+
+alter table logs
+    drop column error_class;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column body_bytes bigint,
+    add column body_truncated boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column body_bytes,
+    drop column body_truncated;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column body_hash varchar,
+    add column content_changed boolean not null default false,
+    add column content_diff varchar;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column body_hash,
+    drop column content_changed,
+    drop column content_diff;
+-- +goose StatementEnd
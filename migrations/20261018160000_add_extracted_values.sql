@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column extracted_values jsonb,
+    add column assertion_failures varchar[];
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column extracted_values,
+    drop column assertion_failures;
+-- +goose StatementEnd
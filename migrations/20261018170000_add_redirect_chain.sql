@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column redirect_chain jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column redirect_chain;
+-- +goose StatementEnd
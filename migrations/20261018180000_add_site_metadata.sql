@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column labels jsonb,
+    add column site_group varchar not null default '',
+    add column owner varchar not null default '';
+
+create index logs_site_group_ts_idx on logs (site_group, ts);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index logs_site_group_ts_idx;
+
+alter table logs
+    drop column labels,
+    drop column site_group,
+    drop column owner;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column suppressed_by varchar not null default '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column suppressed_by;
+-- +goose StatementEnd
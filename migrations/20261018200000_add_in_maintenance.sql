@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column in_maintenance boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column in_maintenance;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column schedule_mode varchar;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column schedule_mode;
+-- +goose StatementEnd
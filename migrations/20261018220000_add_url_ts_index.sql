@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+create index logs_url_ts_idx on logs (url, ts);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index logs_url_ts_idx;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table logs
+    add column anomalous boolean not null default false,
+    add column baseline_milliseconds bigint;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+alter table logs
+    drop column anomalous,
+    drop column baseline_milliseconds;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+create table incidents (
+    id bigserial primary key,
+    url varchar not null,
+    site_group varchar,
+    owner varchar,
+    opened_at timestamp with time zone not null,
+    closed_at timestamp with time zone,
+    first_error varchar,
+    last_error varchar,
+    error_class varchar,
+    last_failure_at timestamp with time zone,
+    acknowledged_by varchar,
+    acknowledged_at timestamp with time zone
+);
+
+create index incidents_url_opened_at_idx on incidents (url, opened_at);
+
+create table incident_events (
+    id bigserial primary key,
+    incident_id bigint not null references incidents (id) on delete cascade,
+    ts timestamp with time zone not null,
+    kind varchar not null,
+    author varchar,
+    note varchar
+);
+
+create index incident_events_incident_id_idx on incident_events (incident_id, ts);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop table incident_events;
+drop table incidents;
+-- +goose StatementEnd
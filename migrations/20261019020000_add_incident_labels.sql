@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+alter table incidents
+    add column labels jsonb;
+
+create index incidents_site_group_opened_at_idx on incidents (site_group, opened_at);
+create index incidents_owner_opened_at_idx on incidents (owner, opened_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+drop index incidents_owner_opened_at_idx;
+drop index incidents_site_group_opened_at_idx;
+
+alter table incidents
+    drop column labels;
+-- +goose StatementEnd
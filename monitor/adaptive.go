@@ -0,0 +1,141 @@
+package monitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// ScheduleMode says why a check was done when it was.
+type ScheduleMode string
+
+const (
+	ScheduleModeInterval   ScheduleMode = "interval"   // The interval of the site, or of its interval windows.
+	ScheduleModeCron       ScheduleMode = "cron"       // The schedule of the site.
+	ScheduleModeFailing    ScheduleMode = "failing"    // A shorter interval, because the site is failing.
+	ScheduleModeRecovering ScheduleMode = "recovering" // A shorter interval that is backing off, because the site recovered.
+)
+
+// defaultAdaptiveFactor is how much the interval changes after every check, if the site doesn't say.
+const defaultAdaptiveFactor = 2
+
+// tick is what [Ticks] tells the monitorer about a check, and what the monitorer tells back. It goes in the context, so any [Monitorer] works with Ticks, even if it doesn't know about it.
+type tick struct {
+	mode     ScheduleMode
+	deadline time.Time // when the site is checked next if this check fails, so its retries don't make Ticks skip it.
+	reported bool      // whether the monitorer said if the site is up. Monitorers that don't know about ticks never do, and then the schedule doesn't adapt.
+	up       bool
+}
+
+type tickKey struct{}
+
+func withTick(ctx context.Context, t *tick) context.Context {
+	return context.WithValue(ctx, tickKey{}, t)
+}
+
+// tickDeadline returns when the next check is due if this one fails, as Ticks scheduled it. It returns false if the check was not scheduled by Ticks.
+func tickDeadline(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(tickKey{}).(*tick)
+	if !ok || t.deadline.IsZero() {
+		return time.Time{}, false
+	}
+
+	return t.deadline, true
+}
+
+// reportTick tells Ticks whether the site was up in a check, and returns the schedule mode of the check. It returns an empty mode if the check was not scheduled by Ticks.
+func reportTick(ctx context.Context, message Message) ScheduleMode {
+	t, ok := ctx.Value(tickKey{}).(*tick)
+	if !ok {
+		return ""
+	}
+
+	t.reported = true
+	t.up = message.ErrorClass == ErrorClassNone
+
+	return t.mode
+}
+
+// scheduler decides when a site is checked next. If the site is adaptive, the interval is divided by the factor after every failed check, down to the floor,
+// and multiplied by it after every successful one, until it's back to the normal schedule.
+type scheduler struct {
+	website config.SiteElement
+	floor   time.Duration
+	factor  float64
+	current time.Duration // the adapted interval, or zero if the site is on its normal schedule.
+	failing bool
+}
+
+func newScheduler(website config.SiteElement, limits config.Limits) *scheduler {
+	floorSeconds := max(website.Adaptive.FloorSeconds, limits.MinIntervalSeconds)
+
+	factor := website.Adaptive.Factor
+	if factor <= 1 {
+		factor = defaultAdaptiveFactor
+	}
+
+	return &scheduler{
+		website: website,
+		floor:   time.Duration(floorSeconds) * time.Second,
+		factor:  factor,
+	}
+}
+
+// normalMode returns the mode of the normal schedule of the site.
+func (s *scheduler) normalMode() ScheduleMode {
+	if s.website.Schedule != nil {
+		return ScheduleModeCron
+	}
+
+	return ScheduleModeInterval
+}
+
+// adapt changes the interval after a check at a time, if the site is adaptive and the monitorer said whether it was up.
+func (s *scheduler) adapt(at time.Time, previous *tick) {
+	if !s.website.Adaptive.Enabled || !previous.reported {
+		return
+	}
+
+	switch {
+	case !previous.up:
+		if s.current == 0 {
+			s.current = s.website.NextCheck(at).Sub(at)
+		}
+
+		s.current = max(time.Duration(float64(s.current)/s.factor), s.floor)
+		s.failing = true
+	case s.current > 0:
+		s.current = time.Duration(float64(s.current) * s.factor)
+		s.failing = false
+	}
+}
+
+// nextIfFailed returns when the site is checked next if the check at a time fails, without changing the schedule. Retries only happen when a check fails, so it's their deadline.
+func (s *scheduler) nextIfFailed(at time.Time) time.Time {
+	failed := *s
+	failed.adapt(at, &tick{reported: true})
+
+	next, _ := failed.next(at)
+
+	return next
+}
+
+// next returns when the site is checked after a time, and the mode of that check. The adapted interval is only used if it's sooner than the normal schedule.
+func (s *scheduler) next(after time.Time) (time.Time, ScheduleMode) {
+	regular := s.website.NextCheck(after)
+
+	if s.current == 0 || !after.Add(s.current).Before(regular) {
+		if !s.failing { // it backed off all the way, so it's back to normal
+			s.current = 0
+		}
+
+		return regular, s.normalMode()
+	}
+
+	if s.failing {
+		return after.Add(s.current), ScheduleModeFailing
+	}
+
+	return after.Add(s.current), ScheduleModeRecovering
+}
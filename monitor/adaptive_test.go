@@ -0,0 +1,129 @@
+package monitor_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// scriptedTransport answers with the next status code of the script, and with 200 once it runs out. It doesn't use the network, so it works within a synctest bubble.
+type scriptedTransport struct {
+	statusCodes []int
+}
+
+func (s *scriptedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	statusCode := http.StatusOK
+	if len(s.statusCodes) > 0 {
+		statusCode, s.statusCodes = s.statusCodes[0], s.statusCodes[1:]
+	}
+
+	return &http.Response{StatusCode: statusCode, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+}
+
+func TestTicks_Adaptive(t *testing.T) {
+	type check struct {
+		at   time.Duration // since the start
+		mode monitor.ScheduleMode
+	}
+
+	tests := []struct {
+		name        string
+		website     config.SiteElement
+		statusCodes []int
+		timeout     time.Duration
+		want        []check
+	}{
+		{
+			name:        "not adaptive",
+			website:     config.SiteElement{URL: "https://example.org", IntervalSeconds: 60},
+			statusCodes: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			timeout:     150 * time.Second,
+			want:        []check{{60 * time.Second, monitor.ScheduleModeInterval}, {120 * time.Second, monitor.ScheduleModeInterval}},
+		},
+		{
+			name: "fails and recovers",
+			website: config.SiteElement{
+				URL:             "https://example.org",
+				IntervalSeconds: 60,
+				Adaptive:        config.AdaptivePolicy{Enabled: true, FloorSeconds: 10},
+			},
+			statusCodes: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			timeout:     240 * time.Second,
+			want: []check{
+				{60 * time.Second, monitor.ScheduleModeInterval},
+				{90 * time.Second, monitor.ScheduleModeFailing},
+				{105 * time.Second, monitor.ScheduleModeFailing},
+				{115 * time.Second, monitor.ScheduleModeFailing}, // the floor
+				{135 * time.Second, monitor.ScheduleModeRecovering},
+				{175 * time.Second, monitor.ScheduleModeRecovering},
+				{235 * time.Second, monitor.ScheduleModeInterval},
+			},
+		},
+		{
+			name: "retries of a failing site don't skip its checks",
+			website: config.SiteElement{
+				URL:             "https://example.org",
+				IntervalSeconds: 60,
+				TimeoutSeconds:  5,
+				Adaptive:        config.AdaptivePolicy{Enabled: true, FloorSeconds: 10},
+				Retry:           config.RetryPolicy{Attempts: 10, BackoffMilliseconds: 4000, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			},
+			statusCodes: slices.Repeat([]int{http.StatusServiceUnavailable}, 10),
+			timeout:     120 * time.Second,
+			want: []check{
+				{60 * time.Second, monitor.ScheduleModeInterval}, // retried at 64s and 72s, as 16s more and the timeout are past 90s
+				{90 * time.Second, monitor.ScheduleModeFailing},
+				{105 * time.Second, monitor.ScheduleModeFailing},
+				{115 * time.Second, monitor.ScheduleModeFailing},
+			},
+		},
+		{
+			name: "cron",
+			website: config.SiteElement{
+				URL:      "https://example.org",
+				Schedule: mustParseCron(t, "*/5 * * * *"),
+				Adaptive: config.AdaptivePolicy{Enabled: true, Factor: 3},
+			},
+			statusCodes: []int{http.StatusInternalServerError},
+			timeout:     11 * time.Minute,
+			want: []check{
+				{5 * time.Minute, monitor.ScheduleModeCron},
+				{6*time.Minute + 40*time.Second, monitor.ScheduleModeFailing},
+				{10 * time.Minute, monitor.ScheduleModeCron},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				start := time.Now()
+
+				ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+				defer cancel()
+
+				messageQueue := make(chan monitor.Message, len(tt.want)+1)
+				client := &http.Client{Transport: &scriptedTransport{statusCodes: tt.statusCodes}}
+
+				monitor.Ticks(ctx, tt.website, config.DefaultLimits, monitor.NewDefaultMonitorer(client, messageQueue).Monitor)
+				close(messageQueue)
+
+				checks := []check{}
+				for message := range messageQueue {
+					checks = append(checks, check{at: message.Timestamp.Sub(start), mode: message.ScheduleMode})
+				}
+
+				assert.Equal(t, tt.want, checks)
+			})
+		})
+	}
+}
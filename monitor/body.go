@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// countingReader counts the bytes read from the underlying reader and keeps the first error that is not [io.EOF].
+//
+// [regexp.Regexp.MatchReader] treats every error as the end of the input, so this is how we find out that reading actually failed.
+type countingReader struct {
+	reader io.Reader
+	read   int64
+	err    error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+
+	if err != nil && !errors.Is(err, io.EOF) && c.err == nil {
+		c.err = err
+	}
+
+	return n, err //nolint:wrapcheck // It has to be returned as is, or the callers won't see io.EOF.
+}
+
+// bodyResult is what we found out from reading a response body.
+type bodyResult struct {
+	matches   bool
+	read      int64
+	truncated bool
+	content   []byte // Only kept if it was asked for.
+}
+
+// readBody reads at most limit bytes of the body. If there is a regexp, it's matched while reading, and the reading stops as soon as it matches.
+// Otherwise the body is read and discarded up to the limit, so we still find out if it can be read.
+//
+// The body is not kept in memory, unless keep is true. Then it's read completely up to the limit, even if the regexp matches earlier.
+func readBody(body io.Reader, re *regexp.Regexp, limit int64, keep bool) (bodyResult, error) {
+	counter := &countingReader{reader: body}
+	limited := &io.LimitedReader{R: counter, N: limit}
+
+	result := bodyResult{}
+
+	switch {
+	case keep:
+		content := &bytes.Buffer{}
+		content.ReadFrom(limited) //nolint:errcheck // The error is kept by the counter.
+
+		result.content = content.Bytes()
+		result.matches = re != nil && re.Match(result.content)
+	case re != nil:
+		result.matches = re.MatchReader(bufio.NewReader(limited))
+	default:
+		io.Copy(io.Discard, limited) //nolint:errcheck // The error is kept by the counter.
+	}
+
+	if limited.N <= 0 && counter.err == nil { // we hit the limit, see if there was anything else to read.
+		n, _ := io.ReadFull(counter, make([]byte, 1)) //nolint:errcheck // The error is kept by the counter.
+		result.truncated = n > 0
+		counter.read -= int64(n) // it was not really read, it was just a peek.
+	}
+
+	result.read = counter.read
+
+	if counter.err != nil {
+		return result, fmt.Errorf("reading body: %w", counter.err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,185 @@
+package monitor_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// largeBodyHandler serves size bytes of filler, with the needle at the given offset, without keeping the whole body in memory.
+type largeBodyHandler struct {
+	size   int64
+	needle string
+	offset int64
+}
+
+func (l *largeBodyHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	chunk := bytes.Repeat([]byte("a"), 32<<10)
+
+	for written := int64(0); written < l.size; {
+		if l.needle != "" && written == l.offset {
+			n, err := w.Write([]byte(l.needle))
+			if err != nil {
+				return
+			}
+
+			written += int64(n)
+
+			continue
+		}
+
+		toWrite := min(int64(len(chunk)), l.size-written)
+		if l.needle != "" && written < l.offset {
+			toWrite = min(toWrite, l.offset-written)
+		}
+
+		n, err := w.Write(chunk[:toWrite])
+		if err != nil {
+			return
+		}
+
+		written += int64(n)
+	}
+}
+
+func TestDefaultMonitorer_Monitor_Body(t *testing.T) {
+	tests := []struct {
+		name              string
+		handler           http.Handler
+		website           config.SiteElement
+		wantRegexpMatches bool
+		wantBodyBytes     int64 // if zero, it's only checked that the body was not read completely.
+		wantTruncated     bool
+		wantErrorClass    monitor.ErrorClass
+	}{
+		{
+			name:          "whole body is read without a regexp",
+			handler:       &largeBodyHandler{size: 1000},
+			website:       config.SiteElement{},
+			wantBodyBytes: 1000,
+		},
+		{
+			name:              "regexp matches within the limit",
+			handler:           &largeBodyHandler{size: 1000, needle: "needle", offset: 500},
+			website:           config.SiteElement{Regexp: regexp.MustCompile("needle"), MaxBodyBytes: 1000},
+			wantRegexpMatches: true,
+			wantBodyBytes:     1000, // it's read through a buffer, so it reads more than the match
+		},
+		{
+			name:              "reading stops when the regexp matches",
+			handler:           &largeBodyHandler{size: 1 << 20, needle: "needle", offset: 10},
+			website:           config.SiteElement{Regexp: regexp.MustCompile("needle")},
+			wantRegexpMatches: true,
+		},
+		{
+			name:           "regexp after the limit",
+			handler:        &largeBodyHandler{size: 1 << 20, needle: "needle", offset: 1<<20 - 10},
+			website:        config.SiteElement{Regexp: regexp.MustCompile("needle"), MaxBodyBytes: 1000},
+			wantBodyBytes:  1000,
+			wantTruncated:  true,
+			wantErrorClass: monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name:          "body exactly as long as the limit",
+			handler:       &largeBodyHandler{size: 1000},
+			website:       config.SiteElement{MaxBodyBytes: 1000},
+			wantBodyBytes: 1000,
+		},
+		{
+			name: "body is shorter than announced",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Length", strconv.Itoa(1000))
+				w.Write([]byte("short")) //nolint:errcheck // Test server.
+			}),
+			website:        config.SiteElement{},
+			wantBodyBytes:  5,
+			wantErrorClass: monitor.ErrorClassBodyRead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeServer := httptest.NewServer(tt.handler)
+			defer fakeServer.Close()
+
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			tt.website.URL = fakeServer.URL
+
+			require.NoError(t, m.Monitor(context.Background(), tt.website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.wantRegexpMatches, msg.RegexpMatches)
+			if tt.wantBodyBytes == 0 {
+				assert.Less(t, msg.BodyBytes, int64(1<<20))
+			} else {
+				assert.Equal(t, tt.wantBodyBytes, msg.BodyBytes)
+			}
+			assert.Equal(t, tt.wantTruncated, msg.BodyTruncated)
+			assert.Equal(t, tt.wantErrorClass, msg.ErrorClass)
+		})
+	}
+}
+
+// BenchmarkDefaultMonitorer_Monitor_LargeBody shows that the memory used by a check does not grow with the size of the body. Run it with -benchmem.
+func BenchmarkDefaultMonitorer_Monitor_LargeBody(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		handler *largeBodyHandler
+		website config.SiteElement
+	}{
+		{
+			name:    "1MiB without regexp",
+			handler: &largeBodyHandler{size: 1 << 20},
+		},
+		{
+			name:    "64MiB without regexp",
+			handler: &largeBodyHandler{size: 64 << 20},
+		},
+		{
+			name:    "64MiB with early match",
+			handler: &largeBodyHandler{size: 64 << 20, needle: "needle", offset: 10},
+			website: config.SiteElement{Regexp: regexp.MustCompile("needle")},
+		},
+		{
+			name:    "64MiB without match",
+			handler: &largeBodyHandler{size: 64 << 20},
+			website: config.SiteElement{Regexp: regexp.MustCompile("needle")},
+		},
+	}
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			fakeServer := httptest.NewServer(bb.handler)
+			defer fakeServer.Close()
+
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			bb.website.URL = fakeServer.URL
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				err := m.Monitor(context.Background(), bb.website)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				<-messageQueue
+			}
+		})
+	}
+}
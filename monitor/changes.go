@@ -0,0 +1,172 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+const (
+	maxDiffLines = 20   // How many changed lines are kept in a diff.
+	maxDiffBytes = 2000 // How long a diff can be, so a big change does not become a big row.
+
+	// lastHashQuery finds the hash of the last body of a URL ($1) that was stored.
+	lastHashQuery = "select body_hash from logs where url = $1 and body_hash <> '' order by ts desc limit 1"
+)
+
+// fingerprint is what we remember of the last body of a site to detect changes.
+type fingerprint struct {
+	hash  string
+	lines []string // nil if only the hash is known, eg. it was loaded from the logs after a restart.
+}
+
+// changeDetector keeps the last fingerprint of every site that detects changes, by site as [config.SiteElement.String] shows it, which is the URL that is stored. It's safe to use concurrently.
+type changeDetector struct {
+	mut      *sync.Mutex
+	previous map[string]fingerprint
+}
+
+func newChangeDetector() *changeDetector {
+	return &changeDetector{
+		mut:      &sync.Mutex{},
+		previous: map[string]fingerprint{},
+	}
+}
+
+// normalize removes everything that matches the ignore regexps (eg. timestamps or nonces), and then trims every line and drops the empty ones, so changes in indentation are not changes in content.
+func normalize(body []byte, ignore []*regexp.Regexp) []string {
+	for _, re := range ignore {
+		body = re.ReplaceAll(body, nil)
+	}
+
+	lines := []string{}
+
+	for line := range bytes.Lines(body) {
+		trimmed := strings.TrimSpace(string(line))
+		if trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+
+	return lines
+}
+
+// newFingerprint hashes the normalized body.
+func newFingerprint(body []byte, ignore []*regexp.Regexp) fingerprint {
+	lines := normalize(body, ignore)
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+
+	return fingerprint{
+		hash:  hex.EncodeToString(hash[:]),
+		lines: lines,
+	}
+}
+
+// diff returns a short textual diff between two lists of lines, in valid UTF-8. It only skips the lines that are the same at the beginning and at the end, which is enough to show what changed in a page without a proper diff algorithm.
+func diff(before, after []string) string {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && before[prefix] == after[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix && before[len(before)-1-suffix] == after[len(after)-1-suffix] {
+		suffix++
+	}
+
+	changed := make([]string, 0, maxDiffLines)
+
+	for _, line := range before[prefix : len(before)-suffix] {
+		changed = append(changed, "- "+line)
+	}
+
+	for _, line := range after[prefix : len(after)-suffix] {
+		changed = append(changed, "+ "+line)
+	}
+
+	if len(changed) > maxDiffLines {
+		changed = append(changed[:maxDiffLines], fmt.Sprintf("... %d more lines", len(changed)-maxDiffLines))
+	}
+
+	// bodies are not always valid UTF-8, but the diff is stored as text, and Postgres rejects the whole batch if it's not
+	result := strings.ToValidUTF8(fmt.Sprintf("@@ line %d @@\n%s", prefix+1, strings.Join(changed, "\n")), string(utf8.RuneError))
+	if len(result) > maxDiffBytes {
+		cut := maxDiffBytes
+		for !utf8.RuneStart(result[cut]) { // so a character is not cut in half
+			cut--
+		}
+
+		result = result[:cut] + "\n..."
+	}
+
+	return result
+}
+
+// load remembers the hash of the last stored body of every site that detects changes and doesn't have one yet, so a change during a restart is not missed.
+func (c *changeDetector) load(ctx context.Context, db *sql.DB, sites []config.SiteElement) error {
+	for _, website := range sites {
+		if !website.DetectChanges {
+			continue
+		}
+
+		c.mut.Lock()
+		_, ok := c.previous[website.String()]
+		c.mut.Unlock()
+
+		if ok {
+			continue
+		}
+
+		var hash string
+
+		err := db.QueryRowContext(ctx, lastHashQuery, website.String()).Scan(&hash)
+		if errors.Is(err, sql.ErrNoRows) { // never checked, the first check sets the baseline
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("querying last body hash of %v: %w", website, err)
+		}
+
+		c.mut.Lock()
+		if _, ok := c.previous[website.String()]; !ok { // a check may have been faster
+			c.previous[website.String()] = fingerprint{hash: hash}
+		}
+		c.mut.Unlock()
+	}
+
+	return nil
+}
+
+// detect compares the body with the previous one of the same site, and fills the message with the hash and, if it changed, the diff. The alert evaluator notifies the change.
+// The first body of a site is only remembered, as there is nothing to compare it with.
+func (c *changeDetector) detect(website config.SiteElement, body []byte, message *Message) {
+	current := newFingerprint(body, website.ChangeIgnoreRegexps)
+
+	message.BodyHash = current.hash
+
+	c.mut.Lock()
+	previous, ok := c.previous[website.String()]
+	c.previous[website.String()] = current
+	c.mut.Unlock()
+
+	if !ok || previous.hash == current.hash {
+		return
+	}
+
+	message.ContentChanged = true
+
+	if previous.lines != nil { // without the previous body there is nothing to diff with
+		message.ContentDiff = diff(previous.lines, current.lines)
+	}
+}
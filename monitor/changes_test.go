@@ -0,0 +1,194 @@
+package monitor_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// changingHandler serves whatever body it's told to serve.
+type changingHandler struct {
+	mut        sync.Mutex
+	body       string
+	statusCode int
+}
+
+func (c *changingHandler) serve(statusCode int, body string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.statusCode = statusCode
+	c.body = body
+}
+
+func (c *changingHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	w.WriteHeader(c.statusCode)
+	w.Write([]byte(c.body)) //nolint:errcheck // Test server.
+}
+
+// This is not a table test because every step depends on the previous one.
+func TestDefaultMonitorer_Monitor_DetectChanges(t *testing.T) {
+	handler := &changingHandler{}
+
+	fakeServer := httptest.NewServer(handler)
+	defer fakeServer.Close()
+
+	messageQueue := make(chan monitor.Message, 1)
+
+	m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+	website := config.SiteElement{
+		URL:                 fakeServer.URL,
+		Regexp:              regexp.MustCompile("Welcome"),
+		DetectChanges:       true,
+		ChangeIgnoreRegexps: []*regexp.Regexp{regexp.MustCompile(`generated at \d+`)},
+	}
+
+	check := func(statusCode int, body string) monitor.Message {
+		t.Helper()
+
+		handler.serve(statusCode, body)
+
+		require.NoError(t, m.Monitor(context.Background(), website))
+
+		return <-messageQueue
+	}
+
+	first := check(http.StatusOK, "<h1>Welcome</h1>\n<p>generated at 1</p>\n<p>Our shop</p>\n")
+	assert.True(t, first.RegexpMatches)
+	assert.NotEmpty(t, first.BodyHash)
+	assert.False(t, first.ContentChanged, "the first body is only remembered")
+
+	ignored := check(http.StatusOK, "  <h1>Welcome</h1>\n\n<p>generated at 2</p>\n<p>Our shop</p>\n")
+	assert.Equal(t, first.BodyHash, ignored.BodyHash, "ignored regexps and whitespace are not changes")
+	assert.False(t, ignored.ContentChanged)
+
+	errorPage := check(http.StatusInternalServerError, "Internal Server Error")
+	assert.Empty(t, errorPage.BodyHash, "error pages are not compared")
+	assert.False(t, errorPage.ContentChanged)
+
+	defaced := check(http.StatusOK, "<h1>Welcome</h1>\n<p>generated at 3</p>\n<p>Hacked!</p>\n")
+	assert.True(t, defaced.RegexpMatches)
+	assert.NotEqual(t, first.BodyHash, defaced.BodyHash)
+	assert.True(t, defaced.ContentChanged)
+	assert.Equal(t, "@@ line 3 @@\n- <p>Our shop</p>\n+ <p>Hacked!</p>", defaced.ContentDiff)
+
+	same := check(http.StatusOK, "<h1>Welcome</h1>\n<p>generated at 4</p>\n<p>Hacked!</p>\n")
+	assert.False(t, same.ContentChanged, "it's compared with the previous body, not the first one")
+}
+
+func TestDefaultMonitorer_Monitor_DetectChanges_LongDiff(t *testing.T) {
+	handler := &changingHandler{}
+
+	fakeServer := httptest.NewServer(handler)
+	defer fakeServer.Close()
+
+	messageQueue := make(chan monitor.Message, 1)
+
+	m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+	website := config.SiteElement{URL: fakeServer.URL, DetectChanges: true}
+
+	handler.serve(http.StatusOK, "<h1>Welcome</h1>\n")
+	require.NoError(t, m.Monitor(context.Background(), website))
+	<-messageQueue
+
+	// every line is 3000 bytes of three-byte characters and an invalid byte, so the diff is cut somewhere in a character
+	handler.serve(http.StatusOK, strings.Repeat(strings.Repeat("日本", 500)+"\xff\n", 5))
+	require.NoError(t, m.Monitor(context.Background(), website))
+
+	changed := <-messageQueue
+	assert.True(t, changed.ContentChanged)
+	assert.True(t, utf8.ValidString(changed.ContentDiff), "the diff is stored as text")
+	assert.LessOrEqual(t, len(changed.ContentDiff), 2000+len("\n..."))
+	assert.True(t, strings.HasSuffix(changed.ContentDiff, "\n..."))
+}
+
+func TestDefaultMonitorer_LoadFingerprints(t *testing.T) {
+	lastHashQuery := regexp.QuoteMeta("select body_hash from logs where url = $1")
+
+	welcome := sha256.Sum256([]byte("<h1>Welcome</h1>"))
+
+	tests := []struct {
+		name           string
+		dbExpectations func(mock sqlmock.Sqlmock, url string)
+		wantErr        bool
+		wantChanged    bool
+	}{
+		{
+			name: "changed during the restart",
+			dbExpectations: func(mock sqlmock.Sqlmock, url string) {
+				mock.ExpectQuery(lastHashQuery).WithArgs(url).WillReturnRows(sqlmock.NewRows([]string{"body_hash"}).AddRow("before the restart"))
+			},
+			wantChanged: true,
+		},
+		{
+			name: "same as before the restart",
+			dbExpectations: func(mock sqlmock.Sqlmock, url string) {
+				mock.ExpectQuery(lastHashQuery).WithArgs(url).WillReturnRows(sqlmock.NewRows([]string{"body_hash"}).AddRow(hex.EncodeToString(welcome[:])))
+			},
+		},
+		{
+			name: "never stored",
+			dbExpectations: func(mock sqlmock.Sqlmock, url string) {
+				mock.ExpectQuery(lastHashQuery).WithArgs(url).WillReturnError(sql.ErrNoRows)
+			},
+		},
+		{
+			name: "failed query",
+			dbExpectations: func(mock sqlmock.Sqlmock, _ string) {
+				mock.ExpectQuery(lastHashQuery).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &changingHandler{}
+			handler.serve(http.StatusOK, "<h1>Welcome</h1>\n")
+
+			fakeServer := httptest.NewServer(handler)
+			defer fakeServer.Close()
+
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock, fakeServer.URL)
+
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+			website := config.SiteElement{URL: fakeServer.URL, DetectChanges: true}
+			sites := []config.SiteElement{{URL: "https://example.org"}, website} // only the sites that detect changes are loaded
+
+			err = m.LoadFingerprints(context.Background(), db, sites)
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			require.NoError(t, m.Monitor(context.Background(), website))
+
+			got := <-messageQueue
+			assert.Equal(t, tt.wantChanged, got.ContentChanged)
+			assert.Empty(t, got.ContentDiff, "the previous body is not stored, so there is nothing to diff with")
+		})
+	}
+}
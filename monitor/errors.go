@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// ErrorClass is a stable classification of why a check failed. The values are stored as they are, so they should not be changed.
+type ErrorClass string
+
+const (
+	ErrorClassNone              ErrorClass = ""
+	ErrorClassDNS               ErrorClass = "dns_error"
+	ErrorClassConnectionRefused ErrorClass = "connection_refused"
+	ErrorClassConnectionReset   ErrorClass = "connection_reset"
+	ErrorClassTimeout           ErrorClass = "timeout"
+	ErrorClassTLS               ErrorClass = "tls_error"
+	ErrorClassCanceled          ErrorClass = "canceled"
+	ErrorClassInvalidRequest    ErrorClass = "invalid_request"  // The request could not even be created, eg. a malformed URL.
+	ErrorClassBodyRead          ErrorClass = "body_read_error"  // The response arrived, but reading the body failed.
+	ErrorClassHTTPStatus        ErrorClass = "http_status"      // The response has a 4xx or 5xx status code.
+	ErrorClassRedirect          ErrorClass = "redirect_error"   // A redirect could not be followed, eg. there were too many.
+	ErrorClassRedirectLoop      ErrorClass = "redirect_loop"    // A redirect points to a URL that was already visited.
+	ErrorClassAssertionFailed   ErrorClass = "assertion_failed" // The response arrived, but it didn't match what we expected (eg. the regexp).
+	ErrorClassUnavailable       ErrorClass = "unavailable"      // A gRPC server could not be reached.
+	ErrorClassNotServing        ErrorClass = "not_serving"      // A gRPC server answered, but the service is not serving.
+	ErrorClassGRPCStatus        ErrorClass = "grpc_status"      // A gRPC call failed with any other status code.
+	ErrorClassUnknown           ErrorClass = "unknown"
+)
+
+// Classify returns the class of a check error. A nil error has no class.
+func Classify(err error) ErrorClass {
+	var (
+		dnsError         *net.DNSError
+		netError         net.Error
+		certificateError *tls.CertificateVerificationError
+		recordError      tls.RecordHeaderError
+		alertError       tls.AlertError
+		authorityError   x509.UnknownAuthorityError
+		hostnameError    x509.HostnameError
+		invalidCertError x509.CertificateInvalidError
+	)
+
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.As(err, &dnsError):
+		return ErrorClassDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClassConnectionReset
+	case errors.As(err, &certificateError), errors.As(err, &recordError), errors.As(err, &alertError),
+		errors.As(err, &authorityError), errors.As(err, &hostnameError), errors.As(err, &invalidCertError):
+		return ErrorClassTLS
+	case errors.As(err, &netError) && netError.Timeout():
+		return ErrorClassTimeout
+	}
+
+	// gRPC errors don't wrap the underlying error, so we can only go by their code.
+	if grpcStatus, ok := status.FromError(err); ok {
+		switch grpcStatus.Code() {
+		case codes.DeadlineExceeded:
+			return ErrorClassTimeout
+		case codes.Canceled:
+			return ErrorClassCanceled
+		case codes.Unavailable:
+			return classifyUnavailable(grpcStatus.Message())
+		default:
+			return ErrorClassGRPCStatus
+		}
+	}
+
+	return ErrorClassUnknown
+}
+
+// classifyUnavailable tries to find out why a gRPC server was unavailable. The cause of the connection error is only kept in the status message, so that's all we can go by.
+func classifyUnavailable(message string) ErrorClass {
+	switch {
+	case strings.Contains(message, "tls:"), strings.Contains(message, "x509:"):
+		return ErrorClassTLS
+	case strings.Contains(message, "connection refused"):
+		return ErrorClassConnectionRefused
+	case strings.Contains(message, "no such host"):
+		return ErrorClassDNS
+	default:
+		return ErrorClassUnavailable
+	}
+}
+
+// classifyResult returns the class of a check result. Errors are classified by [Classify], but a check without an error can still be a failure, eg. a 500 status code or a regexp that does not match.
+func classifyResult(website config.SiteElement, message Message) ErrorClass {
+	switch {
+	case message.Err != nil:
+		return Classify(message.Err)
+	case message.GRPCServingStatus != "" && message.GRPCServingStatus != "SERVING":
+		return ErrorClassNotServing
+	case message.StatusCode >= http.StatusBadRequest:
+		return ErrorClassHTTPStatus
+	case website.Regexp != nil && !message.RegexpMatches, len(message.AssertionFailures) > 0:
+		return ErrorClassAssertionFailed
+	default:
+		return ErrorClassNone
+	}
+}
@@ -0,0 +1,164 @@
+package monitor_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pbabbicola/go-monitor/config"
+
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want monitor.ErrorClass
+	}{
+		{
+			name: "no error",
+			err:  nil,
+			want: monitor.ErrorClassNone,
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("making request: %w", context.Canceled),
+			want: monitor.ErrorClassCanceled,
+		},
+		{
+			name: "deadline exceeded",
+			err:  fmt.Errorf("making request: %w", context.DeadlineExceeded),
+			want: monitor.ErrorClassTimeout,
+		},
+		{
+			name: "dns",
+			err:  fmt.Errorf("making request: %w", &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}),
+			want: monitor.ErrorClassDNS,
+		},
+		{
+			name: "connection refused",
+			err:  fmt.Errorf("making request: %w", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}),
+			want: monitor.ErrorClassConnectionRefused,
+		},
+		{
+			name: "connection reset",
+			err:  fmt.Errorf("making request: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}),
+			want: monitor.ErrorClassConnectionReset,
+		},
+		{
+			name: "unexpected EOF",
+			err:  fmt.Errorf("making request: %w", io.EOF),
+			want: monitor.ErrorClassConnectionReset,
+		},
+		{
+			name: "tls",
+			err:  fmt.Errorf("making request: %w", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}),
+			want: monitor.ErrorClassTLS,
+		},
+		{
+			name: "grpc unavailable",
+			err:  fmt.Errorf("checking grpc health: %w", status.Error(codes.Unavailable, "connection error")),
+			want: monitor.ErrorClassUnavailable,
+		},
+		{
+			name: "grpc connection refused",
+			err:  status.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing: dial tcp 127.0.0.1:1: connect: connection refused\""),
+			want: monitor.ErrorClassConnectionRefused,
+		},
+		{
+			name: "grpc deadline exceeded",
+			err:  status.Error(codes.DeadlineExceeded, "too slow"),
+			want: monitor.ErrorClassTimeout,
+		},
+		{
+			name: "grpc not found",
+			err:  status.Error(codes.NotFound, "unknown service"),
+			want: monitor.ErrorClassGRPCStatus,
+		},
+		{
+			name: "unknown",
+			err:  assert.AnError,
+			want: monitor.ErrorClassUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, monitor.Classify(tt.err))
+		})
+	}
+}
+
+func TestDefaultMonitorer_Monitor_ErrorClass(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("all good")) //nolint:errcheck // Test server.
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	fakeServer := httptest.NewServer(mux)
+	defer fakeServer.Close()
+
+	closedServer := httptest.NewServer(mux)
+	closedServer.Close() // nothing listens on this address anymore
+
+	tests := []struct {
+		name    string
+		website config.SiteElement
+		want    monitor.ErrorClass
+	}{
+		{
+			name:    "success",
+			website: config.SiteElement{URL: fakeServer.URL + "/ok", Regexp: regexp.MustCompile("good")},
+			want:    monitor.ErrorClassNone,
+		},
+		{
+			name:    "http status",
+			website: config.SiteElement{URL: fakeServer.URL + "/broken"},
+			want:    monitor.ErrorClassHTTPStatus,
+		},
+		{
+			name:    "regexp does not match",
+			website: config.SiteElement{URL: fakeServer.URL + "/ok", Regexp: regexp.MustCompile("bad")},
+			want:    monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name:    "connection refused",
+			website: config.SiteElement{URL: closedServer.URL},
+			want:    monitor.ErrorClassConnectionRefused,
+		},
+		{
+			name:    "invalid request",
+			website: config.SiteElement{URL: "http://bad host"},
+			want:    monitor.ErrorClassInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			require.NoError(t, m.Monitor(context.Background(), tt.website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.want, msg.ErrorClass)
+		})
+	}
+}
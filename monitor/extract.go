@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+var (
+	// ErrNotFinite is returned when an extracted value is NaN or infinite, which strconv happily parses.
+	ErrNotFinite = errors.New("not a finite number")
+	// ErrMisplacedComma is returned when an extracted value has commas that are not thousands separators, eg. a decimal comma like 1,5, which would otherwise be read as 15.
+	ErrMisplacedComma = errors.New("commas can only be thousands separators")
+)
+
+// thousands matches the numbers with commas as thousands separators, eg. 1,234 or -1,234,567.89.
+var thousands = regexp.MustCompile(`^[+-]?\d{1,3}(,\d{3})+(\.\d*)?$`)
+
+// parseNumber parses an extracted value. Commas are only allowed as thousands separators (eg. 1,234), so 1,5 is an error and not 15.
+func parseNumber(value []byte) (float64, error) {
+	text := strings.TrimSpace(string(value))
+
+	if strings.Contains(text, ",") {
+		if !thousands.MatchString(text) {
+			return 0, fmt.Errorf("parsing %q as a number: %w", value, ErrMisplacedComma)
+		}
+
+		text = strings.ReplaceAll(text, ",", "")
+	}
+
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %q as a number: %w", value, err)
+	}
+
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, fmt.Errorf("parsing %q as a number: %w", value, ErrNotFinite)
+	}
+
+	return number, nil
+}
+
+// submatch returns the capture group with the given name, or the first one if there is none with that name.
+func submatch(re *regexp.Regexp, name string, body []byte) ([]byte, bool) {
+	match := re.FindSubmatch(body)
+	if match == nil || len(match) < 2 { //nolint:mnd // The whole match and at least one group.
+		return nil, false
+	}
+
+	if index := re.SubexpIndex(name); index > 0 {
+		return match[index], match[index] != nil
+	}
+
+	return match[1], match[1] != nil
+}
+
+// extractValues extracts the named capture groups of the site regexp and the extract list from the body, and checks the thresholds.
+// It returns the values and the failed assertions, eg. a value that was not found or that is over its maximum.
+func extractValues(website config.SiteElement, body []byte) (map[string]float64, []string) {
+	values := map[string]float64{}
+	unparsed := map[string]bool{} // values of the site regexp that were found but are not numbers, so they are not also "not found"
+	var failures []string
+
+	if website.Regexp != nil {
+		if match := website.Regexp.FindSubmatch(body); match != nil {
+			for i, name := range website.Regexp.SubexpNames() {
+				if name == "" || match[i] == nil {
+					continue
+				}
+
+				value, err := parseNumber(match[i])
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+					unparsed[name] = true
+
+					continue
+				}
+
+				values[name] = value
+			}
+		}
+	}
+
+	for _, extraction := range website.Extract {
+		if extraction.Regexp != nil {
+			match, ok := submatch(extraction.Regexp, extraction.Name, body)
+			if !ok {
+				failures = append(failures, extraction.Name+": not found")
+
+				continue
+			}
+
+			value, err := parseNumber(match)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", extraction.Name, err))
+
+				continue
+			}
+
+			values[extraction.Name] = value
+		}
+
+		value, ok := values[extraction.Name]
+		if !ok {
+			if unparsed[extraction.Name] {
+				continue
+			}
+
+			failures = append(failures, extraction.Name+": not found")
+
+			continue
+		}
+
+		if extraction.Min != nil && value < *extraction.Min {
+			failures = append(failures, fmt.Sprintf("%s: %g is below the minimum %g", extraction.Name, value, *extraction.Min))
+		}
+
+		if extraction.Max != nil && value > *extraction.Max {
+			failures = append(failures, fmt.Sprintf("%s: %g is above the maximum %g", extraction.Name, value, *extraction.Max))
+		}
+	}
+
+	return values, failures
+}
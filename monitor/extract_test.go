@@ -0,0 +1,137 @@
+package monitor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+func TestDefaultMonitorer_Monitor_Extract(t *testing.T) {
+	statusPage := "<p>Status: OK</p>\n<p>queue depth: 1,234</p>\n<p>workers: 8</p>\n<p>version: beta</p>\n<p>ratio: NaN</p>\n<p>load: 1,5</p>\n"
+
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(statusPage)) //nolint:errcheck // Test server.
+	}))
+	defer fakeServer.Close()
+
+	threshold := func(value float64) *float64 { return &value }
+
+	tests := []struct {
+		name                  string
+		website               config.SiteElement
+		wantValues            map[string]float64
+		wantAssertionFailures []string
+		wantErrorClass        monitor.ErrorClass
+	}{
+		{
+			name:    "nothing to extract",
+			website: config.SiteElement{Regexp: regexp.MustCompile("Status: OK")},
+		},
+		{
+			name:       "named groups of the site regexp",
+			website:    config.SiteElement{Regexp: regexp.MustCompile(`queue depth: (?P<queue_depth>[\d,]+)`)},
+			wantValues: map[string]float64{"queue_depth": 1234},
+		},
+		{
+			name: "separate regexps within thresholds",
+			website: config.SiteElement{
+				Regexp: regexp.MustCompile("Status: OK"),
+				Extract: []config.Extraction{
+					{Name: "queue_depth", Regexp: regexp.MustCompile(`queue depth: ([\d,]+)`), Max: threshold(5000)},
+					{Name: "workers", Regexp: regexp.MustCompile(`workers: (?P<workers>\d+)`), Min: threshold(2)},
+				},
+			},
+			wantValues: map[string]float64{"queue_depth": 1234, "workers": 8},
+		},
+		{
+			name: "thresholds on named groups of the site regexp",
+			website: config.SiteElement{
+				Regexp:  regexp.MustCompile(`queue depth: (?P<queue_depth>[\d,]+)`),
+				Extract: []config.Extraction{{Name: "queue_depth", Max: threshold(1000)}},
+			},
+			wantValues:            map[string]float64{"queue_depth": 1234},
+			wantAssertionFailures: []string{"queue_depth: 1234 is above the maximum 1000"},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "value below the minimum",
+			website: config.SiteElement{
+				Extract: []config.Extraction{{Name: "workers", Regexp: regexp.MustCompile(`workers: (\d+)`), Min: threshold(10)}},
+			},
+			wantValues:            map[string]float64{"workers": 8},
+			wantAssertionFailures: []string{"workers: 8 is below the minimum 10"},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "value not found",
+			website: config.SiteElement{
+				Extract: []config.Extraction{{Name: "errors", Regexp: regexp.MustCompile(`errors: (\d+)`)}},
+			},
+			wantValues:            map[string]float64{},
+			wantAssertionFailures: []string{"errors: not found"},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "value is not a number",
+			website: config.SiteElement{
+				Extract: []config.Extraction{{Name: "version", Regexp: regexp.MustCompile(`version: (\w+)`)}},
+			},
+			wantValues:            map[string]float64{},
+			wantAssertionFailures: []string{`version: parsing "beta" as a number: strconv.ParseFloat: parsing "beta": invalid syntax`},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "named group of the site regexp is not a number",
+			website: config.SiteElement{
+				Regexp:  regexp.MustCompile(`version: (?P<version>\w+)`),
+				Extract: []config.Extraction{{Name: "version", Min: threshold(1)}},
+			},
+			wantValues:            map[string]float64{},
+			wantAssertionFailures: []string{`version: parsing "beta" as a number: strconv.ParseFloat: parsing "beta": invalid syntax`},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "decimal comma",
+			website: config.SiteElement{
+				Extract: []config.Extraction{{Name: "load", Regexp: regexp.MustCompile(`load: ([\d,.]+)`), Max: threshold(2)}},
+			},
+			wantValues:            map[string]float64{},
+			wantAssertionFailures: []string{`load: parsing "1,5" as a number: commas can only be thousands separators`},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name: "value is not finite",
+			website: config.SiteElement{
+				Extract: []config.Extraction{{Name: "ratio", Regexp: regexp.MustCompile(`ratio: (\w+)`)}},
+			},
+			wantValues:            map[string]float64{},
+			wantAssertionFailures: []string{`ratio: parsing "NaN" as a number: not a finite number`},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			tt.website.URL = fakeServer.URL
+
+			require.NoError(t, m.Monitor(context.Background(), tt.website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.wantValues, msg.Values)
+			assert.Equal(t, tt.wantAssertionFailures, msg.AssertionFailures)
+			assert.Equal(t, tt.wantErrorClass, msg.ErrorClass)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// grpcCredentials returns the transport credentials for the configured gRPC check.
+func grpcCredentials(check config.GRPCCheck) credentials.TransportCredentials {
+	if !check.TLS {
+		return insecure.NewCredentials()
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: check.InsecureSkipVerify, //nolint:gosec // Opt-in per site, for self-signed internal services.
+	})
+}
+
+// monitorGRPC calls the standard grpc.health.v1.Health/Check for the configured service and fills the message with the serving status.
+//
+// A connection is created for every check. It's more expensive than keeping it around, but it means that we actually check that we can connect every time.
+func monitorGRPC(ctx context.Context, website config.SiteElement, message *Message) {
+	conn, err := grpc.NewClient(website.URL, grpc.WithTransportCredentials(grpcCredentials(website.GRPC)))
+	if err != nil {
+		message.Err = fmt.Errorf("creating grpc client for %v: %w", website, err)
+		message.ErrorClass = ErrorClassInvalidRequest
+
+		return
+	}
+	defer conn.Close()
+
+	for name, value := range website.Headers {
+		ctx = metadata.AppendToOutgoingContext(ctx, strings.ToLower(name), value)
+	}
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: website.GRPC.Service})
+
+	message.Duration = time.Since(message.Timestamp)
+
+	if err != nil {
+		message.Err = fmt.Errorf("checking grpc health of %v: %w", website, err)
+
+		return
+	}
+
+	message.GRPCServingStatus = resp.GetStatus().String()
+}
@@ -0,0 +1,133 @@
+package monitor_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// newHealthServer starts an in-process gRPC server with the standard health service, and returns its address.
+func newHealthServer(t *testing.T, opts ...grpc.ServerOption) string {
+	t.Helper()
+
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("up", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("down", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	server := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	go server.Serve(listener) //nolint:errcheck // Returns when the server is stopped.
+
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+func TestDefaultMonitorer_Monitor_GRPC(t *testing.T) {
+	// The certificate of a TLS httptest server is reused so we don't need to generate one.
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	plainAddress := newHealthServer(t)
+	tlsAddress := newHealthServer(t, grpc.Creds(credentials.NewServerTLSFromCert(&tlsServer.TLS.Certificates[0])))
+
+	tests := []struct {
+		name              string
+		website           config.SiteElement
+		wantServingStatus string
+		wantErrorClass    monitor.ErrorClass
+		wantErr           bool
+	}{
+		{
+			name: "serving",
+			website: config.SiteElement{
+				URL:  plainAddress,
+				Type: config.CheckTypeGRPC,
+				GRPC: config.GRPCCheck{Service: "up"},
+			},
+			wantServingStatus: "SERVING",
+		},
+		{
+			name: "overall server health",
+			website: config.SiteElement{
+				URL:  plainAddress,
+				Type: config.CheckTypeGRPC,
+			},
+			wantServingStatus: "SERVING",
+		},
+		{
+			name: "not serving",
+			website: config.SiteElement{
+				URL:  plainAddress,
+				Type: config.CheckTypeGRPC,
+				GRPC: config.GRPCCheck{Service: "down"},
+			},
+			wantServingStatus: "NOT_SERVING",
+			wantErrorClass:    monitor.ErrorClassNotServing,
+		},
+		{
+			name: "unknown service",
+			website: config.SiteElement{
+				URL:  plainAddress,
+				Type: config.CheckTypeGRPC,
+				GRPC: config.GRPCCheck{Service: "does-not-exist"},
+			},
+			wantErrorClass: monitor.ErrorClassGRPCStatus,
+			wantErr:        true,
+		},
+		{
+			name: "tls",
+			website: config.SiteElement{
+				URL:  tlsAddress,
+				Type: config.CheckTypeGRPC,
+				GRPC: config.GRPCCheck{Service: "up", TLS: true, InsecureSkipVerify: true},
+			},
+			wantServingStatus: "SERVING",
+		},
+		{
+			name: "tls with unverified certificate",
+			website: config.SiteElement{
+				URL:            tlsAddress,
+				TimeoutSeconds: 1,
+				Type:           config.CheckTypeGRPC,
+				GRPC:           config.GRPCCheck{Service: "up", TLS: true},
+			},
+			wantErrorClass: monitor.ErrorClassTLS,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(&http.Client{}, messageQueue)
+
+			require.NoError(t, m.Monitor(context.Background(), tt.website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.website.URL, msg.URL)
+			assert.Equal(t, config.CheckTypeGRPC, msg.CheckType)
+			assert.Equal(t, tt.wantServingStatus, msg.GRPCServingStatus)
+			assert.Equal(t, tt.wantErrorClass, msg.ErrorClass)
+			assert.Truef(t, msg.Err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, msg.Err)
+			assert.Positive(t, msg.Duration)
+		})
+	}
+}
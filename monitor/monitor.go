@@ -2,72 +2,90 @@ package monitor
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/pbabbicola/go-monitor/config"
 )
 
-const minTimerSeconds = 5
-
-const maxTimerSeconds = 300
-
 // Monitorer is an interface that is used for passing to Ticks how we want to monitor a certain website.
 type Monitorer func(context.Context, config.SiteElement) error
 
-func adjustTimers(ctx context.Context, website config.SiteElement) config.SiteElement {
-	if website.IntervalSeconds < minTimerSeconds {
-		slog.InfoContext(
-			ctx,
-			"Interval too small. Will use 5 seconds.",
-			slog.String("url", website.URL),
-			slog.Int("interval", website.IntervalSeconds),
-		)
-		website.IntervalSeconds = minTimerSeconds
+// adjustTimers clamps the intervals of the site within the limits. Sites are already clamped by [config.Normalize], so this only matters for sites that didn't go through it.
+func adjustTimers(ctx context.Context, website config.SiteElement, limits config.Limits) config.SiteElement {
+	if website.Schedule != nil && website.IntervalSeconds == 0 { // the schedule is used instead
+		return website
 	}
 
-	if website.IntervalSeconds > maxTimerSeconds {
+	if clamped := limits.Clamp(website.IntervalSeconds); clamped != website.IntervalSeconds {
 		slog.InfoContext(
 			ctx,
-			"Interval too big. Will use 300 seconds.",
-			slog.String("url", website.URL),
+			"Interval out of limits. Will use the closest limit.",
+			slog.String("url", website.String()),
 			slog.Int("interval", website.IntervalSeconds),
+			slog.Int("clamped_interval", clamped),
 		)
-		website.IntervalSeconds = maxTimerSeconds
+		website.IntervalSeconds = clamped
+	}
+
+	website.Intervals = slices.Clone(website.Intervals)
+	for i := range website.Intervals {
+		website.Intervals[i].IntervalSeconds = limits.Clamp(website.Intervals[i].IntervalSeconds)
 	}
 
 	return website
 }
 
-// Ticks creates a ticker that controls the interval for a certain monitor, and will execute the monitorer when the time has passed.
-// Adapted from [time.NewTicker] example.
-func Ticks(ctx context.Context, website config.SiteElement, monitorer Monitorer) {
-	website = adjustTimers(ctx, website)
+// Ticks creates a timer that controls the interval for a certain monitor, and will execute the monitorer when the time has passed.
+// The next check is scheduled from the previous one (not from when it finished), so slow checks don't make the site drift. If a check takes longer than the interval, the missed ones are skipped.
+// If the site is adaptive, the interval also depends on whether the previous check failed, as [DefaultMonitorer] reports.
+func Ticks(ctx context.Context, website config.SiteElement, limits config.Limits, monitorer Monitorer) {
+	website = adjustTimers(ctx, website, limits)
+	schedule := newScheduler(website, limits)
 
-	ticker := time.NewTicker(time.Duration(website.IntervalSeconds) * time.Second)
-	defer ticker.Stop()
+	next, mode := schedule.next(time.Now())
+	if next.IsZero() { // eg. a schedule for February 30th
+		slog.WarnContext(ctx, "The schedule never happens, the site is not checked.", slog.String("url", website.String()), slog.String("schedule", website.Schedule.String()))
+
+		return
+	}
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			slog.DebugContext(ctx, "Done!", slog.String("url", website.URL))
+			slog.DebugContext(ctx, "Done!", slog.String("url", website.String()))
 			return
-		case t := <-ticker.C:
-			err := monitorer(ctx, website)
+		case t := <-timer.C:
+			current := &tick{mode: mode, deadline: schedule.nextIfFailed(next)}
+
+			err := monitorer(withTick(ctx, current), website)
 			if err != nil {
 				slog.InfoContext(
 					ctx,
 					"Failed to monitor",
-					slog.String("url", website.URL),
+					slog.String("url", website.String()),
 					slog.String("error", err.Error()),
 				)
 			}
 
-			slog.DebugContext(ctx, "Monitored", slog.String("url", website.URL), slog.Time("ticked_time", t))
+			slog.DebugContext(ctx, "Monitored", slog.String("url", website.String()), slog.Time("ticked_time", t))
+
+			schedule.adapt(next, current)
+
+			next, mode = schedule.next(next)
+			if now := time.Now(); next.Before(now) {
+				next, mode = schedule.next(now)
+			}
+
+			timer.Reset(time.Until(next))
 		}
 	}
 }
@@ -76,16 +94,23 @@ func Ticks(ctx context.Context, website config.SiteElement, monitorer Monitorer)
 type DefaultMonitorer struct {
 	client       *http.Client
 	messageQueue chan Message
+	changes      *changeDetector
 }
 
-// NewDefaultMonitorer creates a new default monitorer with an http client.
+// NewDefaultMonitorer creates a new default monitorer with an http client. It's safe to use for many sites at once, and it should be kept across reloads, as it remembers the bodies of the sites that detect changes.
 func NewDefaultMonitorer(client *http.Client, messageQueue chan Message) *DefaultMonitorer {
 	return &DefaultMonitorer{
 		client:       client,
 		messageQueue: messageQueue,
+		changes:      newChangeDetector(),
 	}
 }
 
+// LoadFingerprints remembers the hash of the last stored body of the sites that detect changes, so the first check after a restart is compared with it. There is no diff for that check, as the body itself is not stored.
+func (m *DefaultMonitorer) LoadFingerprints(ctx context.Context, db *sql.DB, sites []config.SiteElement) error {
+	return m.changes.load(ctx, db, sites)
+}
+
 var (
 	ErrNilMonitorer = errors.New("monitorer is nil")
 	ErrNilClient    = errors.New("client is nil")
@@ -94,15 +119,42 @@ var (
 // Message is a monitoring message. It adds all the possible data that a monitor may want to show.
 // Here I could have created two message types, and two queues, but I am running out of time.
 type Message struct {
-	URL           string
-	Duration      time.Duration
-	Timestamp     time.Time
-	StatusCode    int
-	RegexpMatches bool
-	Err           error
+	URL               string
+	CheckType         config.CheckType
+	Labels            map[string]string // Labels, Group and Owner are copied from the site, so every sink can use them.
+	Group             string
+	Owner             string
+	Duration          time.Duration
+	Timestamp         time.Time
+	StatusCode        int
+	RegexpMatches     bool
+	GRPCServingStatus string             // Only set for gRPC checks, eg. SERVING or NOT_SERVING.
+	HandshakeDuration time.Duration      // Only set for WebSocket checks.
+	RoundTripDuration time.Duration      // Only set for WebSocket checks that wait for a reply.
+	BodyBytes         int64              // How much of the body was read. The reading stops when the regexp matches, so it may be less than the body.
+	BodyTruncated     bool               // Whether the body was longer than the limit of the site.
+	BodyHash          string             // Hash of the normalized body. Only set for sites that detect changes.
+	ContentChanged    bool               // Whether the body changed since the previous check.
+	ContentDiff       string             // A short diff of the change, if the body changed.
+	Values            map[string]float64 // Numbers extracted from the body, by name.
+	AssertionFailures []string           // Which assertions failed, eg. an extracted value above its maximum or too many redirects.
+	Redirects         []Hop              // Every request that was made, if there was at least one redirect. The last one is the final response.
+	Attempts          int                // How many attempts it took to get this result.
+	AttemptErrors     []error            // Errors of the attempts that were retried, in order. The error of the last attempt is in Err.
+	ErrorClass        ErrorClass         // Why the check failed, if it did. It's set even if Err is nil, eg. for a 500 status code.
+	SuppressedBy      string             // The site this one depends on that was down when this check failed, so it's not alerted on. Set by the alert evaluator.
+	InMaintenance     bool               // Whether the site was in a maintenance window, so it's not alerted on nor counted for the uptime. Set by the alert evaluator.
+	ScheduleMode      ScheduleMode       // Why the check was done when it was, eg. because the site was failing. Empty if it was not scheduled by Ticks.
+	Anomalous         bool               // Whether the check was much slower than usual for the site at that hour of the week. Set by the anomaly detector.
+	Baseline          time.Duration      // The usual duration of the site at that hour of the week, if it's known. Set by the anomaly detector.
+	Flapping          bool               // Whether the site keeps going up and down, so its changes are not notified one by one. Set by the alert evaluator.
+	Notified          bool               // Whether someone was told that the site is down because of this result. Set by the alert evaluator.
+	Err               error
 }
 
-// Monitor monitors one website and prints in debug the monitoring information.
+// Monitor monitors one website and sends the monitoring information to the message queue.
+//
+// If the website has a retry policy, retryable failures are attempted again before sending the message, as long as the backoff and the timeout of another attempt fit before the next check is due. The message is the result of the last attempt, but keeps the timestamp of the first one.
 func (m *DefaultMonitorer) Monitor(ctx context.Context, website config.SiteElement) error {
 	if m == nil {
 		return ErrNilMonitorer
@@ -114,45 +166,118 @@ func (m *DefaultMonitorer) Monitor(ctx context.Context, website config.SiteEleme
 
 	start := time.Now() // theoretically I should do this after creating the request but I've written myself into a corner since I would have to go back and figure out how to modify the logs table, as I set start time as part of the primary key.
 
+	// All the attempts share one deadline, the next check as Ticks scheduled it, so retrying can't make the scheduler skip ticks.
+	attemptsCtx := ctx
+
+	next, ok := tickDeadline(ctx)
+	if !ok {
+		next = website.NextCheck(start)
+	}
+
+	if next.After(start) {
+		var cancel context.CancelFunc
+
+		attemptsCtx, cancel = context.WithDeadline(ctx, next)
+		defer cancel()
+	}
+
+	var attemptErrors []error
+
+	for attempt := 1; ; attempt++ {
+		message := m.check(attemptsCtx, website)
+
+		if attempt < website.Retry.Attempts && shouldRetry(website.Retry, message) {
+			delay := backoff(website.Retry, attempt)
+
+			// a retry that can't finish before the deadline would only fail as a timeout, and hide why this attempt failed. Then this is the last attempt, and its error is in Err only.
+			if fits(attemptsCtx, delay+website.Timeout()) && wait(attemptsCtx, delay) == nil {
+				attemptErrors = append(attemptErrors, attemptError(message))
+
+				continue
+			}
+		}
+
+		message.Timestamp = start
+		message.Attempts = attempt
+		message.AttemptErrors = attemptErrors
+		message.ScheduleMode = reportTick(ctx, message)
+
+		redactMessage(website, &message)
+
+		m.messageQueue <- message
+
+		return nil
+	}
+}
+
+// check does a single attempt of checking the website, according to its type.
+func (m *DefaultMonitorer) check(ctx context.Context, website config.SiteElement) Message {
+	if timeout := website.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// We build the message so we can send partial results as logs if we don't have the complete result.
 	message := Message{
 		URL:       website.URL,
-		Timestamp: start,
+		CheckType: website.Type,
+		Labels:    website.Labels,
+		Group:     website.Group,
+		Owner:     website.Owner,
+		Timestamp: time.Now(),
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, website.URL, http.NoBody)
-	if err != nil {
-		message.Err = fmt.Errorf("creating request to %v: %w", website, err)
-		m.messageQueue <- message
+	switch website.Type {
+	case config.CheckTypeGRPC:
+		monitorGRPC(ctx, website, &message)
+	case config.CheckTypeWebSocket:
+		m.monitorWebSocket(ctx, website, &message)
+	default:
+		message.CheckType = config.CheckTypeHTTP
+		m.monitorHTTP(ctx, website, &message)
+	}
 
-		return nil
+	if message.ErrorClass == ErrorClassNone { // the check may already know better, eg. when reading the body fails.
+		message.ErrorClass = classifyResult(website, message)
 	}
 
-	resp, err := m.client.Do(req)
-	if err != nil {
-		message.Err = fmt.Errorf("making request to %v: %w", website, err)
-		m.messageQueue <- message
+	return message
+}
 
-		return nil
+// monitorHTTP makes a GET request to the website, following its redirects, and fills the message with the result.
+func (m *DefaultMonitorer) monitorHTTP(ctx context.Context, website config.SiteElement, message *Message) {
+	resp := m.followRedirects(ctx, website, message)
+	if resp == nil {
+		return
 	}
 	defer resp.Body.Close()
 
-	message.Duration = time.Since(start)
+	message.Duration = time.Since(message.Timestamp)
 	message.StatusCode = resp.StatusCode
 
-	responseBody, err := io.ReadAll(resp.Body)
+	body, err := readBody(resp.Body, website.Regexp, website.BodyLimit(), website.DetectChanges || website.ExtractsValues())
+
+	message.BodyBytes = body.read
+	message.BodyTruncated = body.truncated
+	message.RegexpMatches = body.matches
+
 	if err != nil {
 		message.Err = fmt.Errorf("reading response body for %v: %w", website, err)
-		m.messageQueue <- message
+		message.ErrorClass = ErrorClassBodyRead
 
-		return nil
+		return
 	}
 
-	if website.Regexp != nil {
-		message.RegexpMatches = website.Regexp.Match(responseBody)
-	}
+	if website.ExtractsValues() {
+		values, failures := extractValues(website, body.content)
 
-	m.messageQueue <- message
+		message.Values = values
+		message.AssertionFailures = append(message.AssertionFailures, failures...)
+	}
 
-	return nil
+	if website.DetectChanges && resp.StatusCode < http.StatusBadRequest { // error pages are not content changes.
+		m.changes.detect(website, body.content, message)
+	}
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/pbabbicola/go-monitor/config"
 	"github.com/pbabbicola/go-monitor/monitor"
@@ -33,11 +34,21 @@ func (m *mockedMonitorer) monitor(context.Context, config.SiteElement) error {
 	return m.err
 }
 
+func mustParseCron(t *testing.T, expression string) *config.Cron {
+	t.Helper()
+
+	schedule, err := config.ParseCron(expression)
+	require.NoError(t, err)
+
+	return schedule
+}
+
 func TestTicks(t *testing.T) {
 	tests := []struct {
 		name string // Description of this test case
 		// Named input parameters for target function.
 		website   config.SiteElement
+		limits    config.Limits // defaults to config.DefaultLimits
 		monitorer *mockedMonitorer
 		// Other needed parameters
 		expectedAmountOfCalls int
@@ -65,6 +76,48 @@ func TestTicks(t *testing.T) {
 			expectedAmountOfCalls: 0,
 			timeout:               1 * time.Second,
 		},
+		{
+			name: "interval below the limit",
+			website: config.SiteElement{
+				URL:             "test-url",
+				IntervalSeconds: 1,
+			},
+			monitorer:             NewMockedMonitorer(nil),
+			expectedAmountOfCalls: 2,
+			timeout:               12 * time.Second,
+		},
+		{
+			name: "configured limits",
+			website: config.SiteElement{
+				URL:             "test-url",
+				IntervalSeconds: 1,
+			},
+			limits:                config.Limits{MinIntervalSeconds: 1, MaxIntervalSeconds: 300},
+			monitorer:             NewMockedMonitorer(nil),
+			expectedAmountOfCalls: 3,
+			timeout:               3500 * time.Millisecond,
+		},
+		{
+			name: "schedule",
+			website: config.SiteElement{
+				URL:      "test-url",
+				Schedule: mustParseCron(t, "* * * * *"),
+			},
+			monitorer:             NewMockedMonitorer(nil),
+			expectedAmountOfCalls: 2,
+			timeout:               150 * time.Second,
+		},
+		{
+			name: "interval window", // the fake clock starts at midnight
+			website: config.SiteElement{
+				URL:             "test-url",
+				IntervalSeconds: 60,
+				Intervals:       []config.IntervalWindow{{From: 0, To: 60, IntervalSeconds: 10}},
+			},
+			monitorer:             NewMockedMonitorer(nil),
+			expectedAmountOfCalls: 5,
+			timeout:               55 * time.Second,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -72,7 +125,12 @@ func TestTicks(t *testing.T) {
 				ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
 				defer cancel()
 
-				monitor.Ticks(ctx, tt.website, tt.monitorer.monitor)
+				limits := tt.limits
+				if limits == (config.Limits{}) {
+					limits = config.DefaultLimits
+				}
+
+				monitor.Ticks(ctx, tt.website, limits, tt.monitorer.monitor)
 
 				assert.Equal(t, tt.expectedAmountOfCalls, tt.monitorer.monitored)
 			})
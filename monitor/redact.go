@@ -0,0 +1,52 @@
+package monitor
+
+import (
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// redactedError hides the secrets of a site in the message of an error, but keeps the error chain, so it's still classified and compared the same way.
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns the error with the secrets of the site redacted from its message. Errors without secrets are returned as they are.
+func redactError(website config.SiteElement, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := website.Redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	return &redactedError{err: err, message: message}
+}
+
+// redactMessage removes the secrets of the site from everything in the message that is logged or stored. The body is not kept, so only what was taken from it (eg. the diff) is redacted.
+func redactMessage(website config.SiteElement, message *Message) {
+	message.URL = website.Redact(message.URL)
+	message.ContentDiff = website.Redact(message.ContentDiff)
+	message.Err = redactError(website, message.Err)
+
+	for i := range message.AttemptErrors {
+		message.AttemptErrors[i] = redactError(website, message.AttemptErrors[i])
+	}
+
+	for i := range message.AssertionFailures {
+		message.AssertionFailures[i] = website.Redact(message.AssertionFailures[i])
+	}
+
+	for i := range message.Redirects {
+		message.Redirects[i].URL = website.Redact(message.Redirects[i].URL)
+	}
+}
@@ -0,0 +1,152 @@
+package monitor_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// parseSite parses a single site from JSON, so it goes through interpolation like a real site list.
+func parseSite(t *testing.T, contents string) config.SiteElement {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "sites.json")
+	require.NoError(t, os.WriteFile(filename, []byte("["+contents+"]"), 0o600))
+
+	sites, err := config.Parse(filename)
+	require.NoError(t, err)
+	require.Len(t, sites, 1)
+
+	return sites[0]
+}
+
+func TestDefaultMonitorer_Monitor_Headers(t *testing.T) {
+	t.Setenv("GOMONITOR_TEST_TOKEN", "s3cr3t-t0k3n")
+
+	otherHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "credentials were sent to another host")
+	}))
+	defer otherHost.Close()
+
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t-t0k3n" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		if r.URL.Path == "/elsewhere" {
+			http.Redirect(w, r, otherHost.URL, http.StatusFound)
+
+			return
+		}
+
+		w.Write([]byte("ok")) //nolint:errcheck // Test server.
+	}))
+	defer fakeServer.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		wantStatusCode int
+	}{
+		{
+			name:           "same host",
+			path:           "/health",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "redirect to another host",
+			path:           "/elsewhere",
+			wantStatusCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			website := parseSite(t, `{"url": "`+fakeServer.URL+tt.path+`", "headers": {"Authorization": "Bearer ${GOMONITOR_TEST_TOKEN}"}}`)
+
+			messageQueue := make(chan monitor.Message, 1)
+
+			require.NoError(t, monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue).Monitor(context.Background(), website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.wantStatusCode, msg.StatusCode)
+			require.NoError(t, msg.Err)
+		})
+	}
+}
+
+// redirectingTransport redirects the https requests to http on the same host, and keeps the Authorization header of every request. It doesn't use the network, so both schemes can be on the same host.
+type redirectingTransport struct {
+	authorizations map[string]string // by URL
+}
+
+func (r *redirectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.authorizations[req.URL.String()] = req.Header.Get("Authorization")
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok")), Request: req}
+
+	if req.URL.Scheme == "https" {
+		resp.StatusCode = http.StatusFound
+		resp.Header.Set("Location", "http://"+req.URL.Host+req.URL.Path)
+	}
+
+	return resp, nil
+}
+
+func TestDefaultMonitorer_Monitor_Headers_Downgrade(t *testing.T) {
+	t.Setenv("GOMONITOR_TEST_TOKEN", "s3cr3t-t0k3n")
+
+	transport := &redirectingTransport{authorizations: map[string]string{}}
+	website := parseSite(t, `{"url": "https://example.org/health", "headers": {"Authorization": "Bearer ${GOMONITOR_TEST_TOKEN}"}}`)
+
+	messageQueue := make(chan monitor.Message, 1)
+
+	require.NoError(t, monitor.NewDefaultMonitorer(&http.Client{Transport: transport}, messageQueue).Monitor(context.Background(), website))
+
+	msg := <-messageQueue
+	require.NoError(t, msg.Err)
+	assert.Equal(t, http.StatusOK, msg.StatusCode)
+
+	assert.Equal(t, map[string]string{
+		"https://example.org/health": "Bearer s3cr3t-t0k3n",
+		"http://example.org/health":  "",
+	}, transport.authorizations, "credentials were sent in cleartext")
+}
+
+func TestDefaultMonitorer_Monitor_Redaction(t *testing.T) {
+	t.Setenv("GOMONITOR_TEST_TOKEN", "s3cr3t-t0k3n")
+
+	// Nothing listens there, so the error has the whole URL in it.
+	listener := httptest.NewServer(http.NotFoundHandler())
+	address := listener.URL
+	listener.Close()
+
+	website := parseSite(t, `{"url": "`+address+`/health?token=${GOMONITOR_TEST_TOKEN}", "retry": {"attempts": 2}}`)
+
+	messageQueue := make(chan monitor.Message, 1)
+
+	require.NoError(t, monitor.NewDefaultMonitorer(&http.Client{}, messageQueue).Monitor(context.Background(), website))
+
+	msg := <-messageQueue
+
+	assert.Equal(t, address+"/health?token=[REDACTED]", msg.URL)
+	require.Error(t, msg.Err)
+	assert.NotContains(t, msg.Err.Error(), "s3cr3t-t0k3n")
+	assert.Contains(t, msg.Err.Error(), "token=[REDACTED]")
+	assert.Equal(t, monitor.ErrorClassConnectionRefused, msg.ErrorClass, "redacting should not change the classification")
+	require.Len(t, msg.AttemptErrors, 1)
+	assert.NotContains(t, msg.AttemptErrors[0].Error(), "s3cr3t-t0k3n")
+}
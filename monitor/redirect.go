@@ -0,0 +1,148 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// maxRedirects is how many redirects are followed at most, the same as the default of [http.Client].
+const maxRedirects = 10
+
+// redirectDrainBytes is how much of the body of a redirect is read, so the connection can be reused.
+const redirectDrainBytes = 4 << 10
+
+var (
+	ErrRedirectLoop       = errors.New("redirect loop")
+	ErrTooManyRedirects   = errors.New("too many redirects")
+	ErrMissingRedirectURL = errors.New("redirect without location")
+)
+
+// Hop is one request of a redirect chain.
+type Hop struct {
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+}
+
+// isRedirect returns whether the status code is a redirect that we follow.
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// followRedirects makes a GET request to the website and follows the redirects itself, instead of letting the client do it, so every hop is recorded.
+//
+// It returns the final response, or nil if it failed, in which case the message already has the error.
+func (m *DefaultMonitorer) followRedirects(ctx context.Context, website config.SiteElement, message *Message) *http.Response {
+	client := *m.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	url := website.URL
+	origin := ""
+	visited := map[string]bool{}
+	chain := []Hop{}
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		if err != nil {
+			message.Err = fmt.Errorf("creating request to %v: %w", website, err)
+			message.ErrorClass = ErrorClassInvalidRequest
+
+			return nil
+		}
+
+		if origin == "" {
+			origin = req.URL.Scheme + "://" + req.URL.Host
+		}
+
+		// The headers may have credentials, so they are only sent to the host of the site, like the client does with Authorization when it follows redirects.
+		// The scheme has to be the same too, or a redirect from https to http would send them in cleartext.
+		if req.URL.Scheme+"://"+req.URL.Host == origin {
+			for name, value := range website.Headers {
+				req.Header.Set(name, value)
+			}
+		}
+
+		visited[url] = true
+		hopStart := time.Now()
+
+		resp, err := client.Do(req)
+		if err != nil {
+			message.Err = fmt.Errorf("making request to %v: %w", website, err)
+
+			return nil
+		}
+
+		chain = append(chain, Hop{URL: url, StatusCode: resp.StatusCode, Duration: time.Since(hopStart)})
+
+		if len(chain) > 1 { // only record it if there was a redirect at all
+			message.Redirects = chain
+		}
+
+		if !isRedirect(resp.StatusCode) {
+			message.AssertionFailures = append(message.AssertionFailures, redirectAssertions(website.Redirects, chain)...)
+
+			return resp
+		}
+
+		location, err := resp.Location()
+
+		io.Copy(io.Discard, io.LimitReader(resp.Body, redirectDrainBytes)) //nolint:errcheck // We are only draining it.
+		resp.Body.Close()
+
+		switch {
+		case err != nil:
+			message.StatusCode = resp.StatusCode
+			message.Err = fmt.Errorf("following redirect of %v: %w", website, ErrMissingRedirectURL)
+			message.ErrorClass = ErrorClassRedirect
+
+			return nil
+		case visited[location.String()]:
+			message.StatusCode = resp.StatusCode
+			message.Err = fmt.Errorf("following redirect of %v to %v: %w", website, location, ErrRedirectLoop)
+			message.ErrorClass = ErrorClassRedirectLoop
+
+			return nil
+		case len(chain) > maxRedirects:
+			message.StatusCode = resp.StatusCode
+			message.Err = fmt.Errorf("following redirects of %v: %w", website, ErrTooManyRedirects)
+			message.ErrorClass = ErrorClassRedirect
+
+			return nil
+		}
+
+		url = location.String()
+	}
+}
+
+// redirectAssertions checks the redirect chain against the policy, and returns the failed assertions.
+func redirectAssertions(policy config.RedirectPolicy, chain []Hop) []string {
+	var failures []string
+
+	redirects := len(chain) - 1
+
+	if policy.MaxRedirects != nil && redirects > *policy.MaxRedirects {
+		failures = append(failures, fmt.Sprintf("redirects: %d, but at most %d are allowed", redirects, *policy.MaxRedirects))
+	}
+
+	finalURL := chain[len(chain)-1].URL
+
+	if policy.FinalURLPrefix != "" && !strings.HasPrefix(finalURL, policy.FinalURLPrefix) {
+		failures = append(failures, fmt.Sprintf("redirects: ended at %v, which does not start with %v", finalURL, policy.FinalURLPrefix))
+	}
+
+	return failures
+}
@@ -0,0 +1,151 @@
+package monitor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+func newRedirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle("/first", http.RedirectHandler("/second", http.StatusMovedPermanently))
+	mux.Handle("/second", http.RedirectHandler("/final", http.StatusFound))
+	mux.HandleFunc("/final", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("you made it")) //nolint:errcheck // Test server.
+	})
+	mux.Handle("/loop", http.RedirectHandler("/loop-back", http.StatusTemporaryRedirect))
+	mux.Handle("/loop-back", http.RedirectHandler("/loop", http.StatusTemporaryRedirect))
+	mux.HandleFunc("/endless", func(w http.ResponseWriter, r *http.Request) {
+		hops, _ := strconv.Atoi(r.URL.Query().Get("hops")) //nolint:errcheck // It's zero on the first request.
+		http.Redirect(w, r, "/endless?hops="+strconv.Itoa(hops+1), http.StatusFound)
+	})
+	mux.HandleFunc("/nowhere", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDefaultMonitorer_Monitor_Redirects(t *testing.T) {
+	fakeServer := newRedirectServer(t)
+
+	allowed := func(redirects int) *int { return &redirects }
+
+	tests := []struct {
+		name                  string
+		path                  string
+		policy                config.RedirectPolicy
+		wantStatusCode        int
+		wantHops              []string
+		wantHopStatusCodes    []int
+		wantAssertionFailures []string
+		wantErrorClass        monitor.ErrorClass
+	}{
+		{
+			name:           "no redirects",
+			path:           "/final",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:               "chain is recorded",
+			path:               "/first",
+			policy:             config.RedirectPolicy{MaxRedirects: allowed(2)},
+			wantStatusCode:     http.StatusOK,
+			wantHops:           []string{"/first", "/second", "/final"},
+			wantHopStatusCodes: []int{http.StatusMovedPermanently, http.StatusFound, http.StatusOK},
+		},
+		{
+			name:                  "too many redirects for the policy",
+			path:                  "/first",
+			policy:                config.RedirectPolicy{MaxRedirects: allowed(1)},
+			wantStatusCode:        http.StatusOK,
+			wantHops:              []string{"/first", "/second", "/final"},
+			wantHopStatusCodes:    []int{http.StatusMovedPermanently, http.StatusFound, http.StatusOK},
+			wantAssertionFailures: []string{"redirects: 2, but at most 1 are allowed"},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name:                  "final url must be https",
+			path:                  "/final",
+			policy:                config.RedirectPolicy{FinalURLPrefix: "https://"},
+			wantStatusCode:        http.StatusOK,
+			wantAssertionFailures: []string{"redirects: ended at " + fakeServer.URL + "/final, which does not start with https://"},
+			wantErrorClass:        monitor.ErrorClassAssertionFailed,
+		},
+		{
+			name:               "loop",
+			path:               "/loop",
+			wantStatusCode:     http.StatusTemporaryRedirect,
+			wantHops:           []string{"/loop", "/loop-back"},
+			wantHopStatusCodes: []int{http.StatusTemporaryRedirect, http.StatusTemporaryRedirect},
+			wantErrorClass:     monitor.ErrorClassRedirectLoop,
+		},
+		{
+			name:           "redirect without location",
+			path:           "/nowhere",
+			wantStatusCode: http.StatusFound,
+			wantErrorClass: monitor.ErrorClassRedirect,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			require.NoError(t, m.Monitor(context.Background(), config.SiteElement{URL: fakeServer.URL + tt.path, Redirects: tt.policy}))
+
+			msg := <-messageQueue
+
+			hops := []string{}
+			hopStatusCodes := []int{}
+
+			for _, hop := range msg.Redirects {
+				hops = append(hops, hop.URL[len(fakeServer.URL):])
+				hopStatusCodes = append(hopStatusCodes, hop.StatusCode)
+
+				assert.Positive(t, hop.Duration)
+			}
+
+			if tt.wantHops == nil {
+				assert.Empty(t, msg.Redirects)
+			} else {
+				assert.Equal(t, tt.wantHops, hops)
+				assert.Equal(t, tt.wantHopStatusCodes, hopStatusCodes)
+			}
+
+			assert.Equal(t, tt.wantStatusCode, msg.StatusCode)
+			assert.Equal(t, tt.wantAssertionFailures, msg.AssertionFailures)
+			assert.Equal(t, tt.wantErrorClass, msg.ErrorClass)
+		})
+	}
+}
+
+func TestDefaultMonitorer_Monitor_TooManyRedirects(t *testing.T) {
+	fakeServer := newRedirectServer(t)
+
+	messageQueue := make(chan monitor.Message, 1)
+
+	m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+	require.NoError(t, m.Monitor(context.Background(), config.SiteElement{URL: fakeServer.URL + "/endless"}))
+
+	msg := <-messageQueue
+
+	assert.ErrorIs(t, msg.Err, monitor.ErrTooManyRedirects)
+	assert.Equal(t, monitor.ErrorClassRedirect, msg.ErrorClass)
+	assert.Len(t, msg.Redirects, 11)
+}
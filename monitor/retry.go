@@ -0,0 +1,78 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// ErrRetryableResult is recorded as the attempt error when an attempt without an error was retried, eg. because of its status code.
+var ErrRetryableResult = errors.New("retryable result")
+
+// defaultRetryOn are the error classes that are retried if the policy does not say otherwise.
+var defaultRetryOn = []ErrorClass{
+	ErrorClassDNS,
+	ErrorClassConnectionRefused,
+	ErrorClassConnectionReset,
+	ErrorClassTimeout,
+}
+
+// shouldRetry returns whether the result of an attempt can be retried according to the policy. It does not take into account the amount of attempts.
+func shouldRetry(policy config.RetryPolicy, message Message) bool {
+	if message.Err == nil && slices.Contains(policy.RetryStatusCodes, message.StatusCode) {
+		return true
+	}
+
+	switch {
+	case message.ErrorClass == ErrorClassNone:
+		return false
+	case message.ErrorClass == ErrorClassCanceled: // we are shutting down, there is no point.
+		return false
+	case len(policy.RetryOn) == 0:
+		return slices.Contains(defaultRetryOn, message.ErrorClass)
+	default:
+		return slices.Contains(policy.RetryOn, string(message.ErrorClass))
+	}
+}
+
+// attemptError returns the error that explains why an attempt was retried.
+func attemptError(message Message) error {
+	if message.Err != nil {
+		return message.Err
+	}
+
+	if message.ErrorClass == ErrorClassNone {
+		return fmt.Errorf("%w: status code %d", ErrRetryableResult, message.StatusCode)
+	}
+
+	return fmt.Errorf("%w: %s, status code %d", ErrRetryableResult, message.ErrorClass, message.StatusCode)
+}
+
+// backoff returns how long to wait after the given attempt. It doubles after every attempt.
+func backoff(policy config.RetryPolicy, attempt int) time.Duration {
+	return time.Duration(policy.BackoffMilliseconds) * time.Millisecond << (attempt - 1)
+}
+
+// fits returns whether something that takes the duration is done before the deadline of the context, if it has one.
+func fits(ctx context.Context, duration time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+
+	return !ok || !time.Now().Add(duration).After(deadline)
+}
+
+// wait waits for the duration, or returns the context error if it's done first.
+func wait(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("waiting to retry: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,139 @@
+package monitor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// flakyHandler fails the first failures requests, either with the status code or, if it's zero, by closing the connection. Then it returns 200.
+type flakyHandler struct {
+	failures   int32
+	statusCode int
+	requests   atomic.Int32
+}
+
+func (f *flakyHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	if f.requests.Add(1) > f.failures {
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	if f.statusCode != 0 {
+		w.WriteHeader(f.statusCode)
+
+		return
+	}
+
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+
+	conn.Close()
+}
+
+func TestDefaultMonitorer_Monitor_Retry(t *testing.T) {
+	tests := []struct {
+		name              string
+		handler           *flakyHandler
+		intervalSeconds   int
+		timeoutSeconds    int
+		retry             config.RetryPolicy
+		wantStatusCode    int
+		wantAttempts      int
+		wantAttemptErrors int
+		wantErr           bool
+	}{
+		{
+			name:           "no policy",
+			handler:        &flakyHandler{failures: 1, statusCode: http.StatusServiceUnavailable},
+			wantStatusCode: http.StatusServiceUnavailable,
+			wantAttempts:   1,
+		},
+		{
+			name:              "retryable status code recovers",
+			handler:           &flakyHandler{failures: 2, statusCode: http.StatusServiceUnavailable},
+			retry:             config.RetryPolicy{Attempts: 3, BackoffMilliseconds: 1, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatusCode:    http.StatusOK,
+			wantAttempts:      3,
+			wantAttemptErrors: 2,
+		},
+		{
+			name:              "retryable status code runs out of attempts",
+			handler:           &flakyHandler{failures: 5, statusCode: http.StatusServiceUnavailable},
+			retry:             config.RetryPolicy{Attempts: 2, BackoffMilliseconds: 1, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatusCode:    http.StatusServiceUnavailable,
+			wantAttempts:      2,
+			wantAttemptErrors: 1,
+		},
+		{
+			name:           "status code that is not retryable",
+			handler:        &flakyHandler{failures: 1, statusCode: http.StatusInternalServerError},
+			retry:          config.RetryPolicy{Attempts: 3, BackoffMilliseconds: 1, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatusCode: http.StatusInternalServerError,
+			wantAttempts:   1,
+		},
+		{
+			name:              "connection reset is retried by default",
+			handler:           &flakyHandler{failures: 1},
+			retry:             config.RetryPolicy{Attempts: 3, BackoffMilliseconds: 1},
+			wantStatusCode:    http.StatusOK,
+			wantAttempts:      2,
+			wantAttemptErrors: 1,
+		},
+		{
+			name:              "no time to retry before the next check",
+			handler:           &flakyHandler{failures: 5, statusCode: http.StatusServiceUnavailable},
+			intervalSeconds:   2,
+			timeoutSeconds:    1,
+			retry:             config.RetryPolicy{Attempts: 5, BackoffMilliseconds: 400, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatusCode:    http.StatusServiceUnavailable,
+			wantAttempts:      2, // 400ms and the timeout fit in the interval after the first one, but 800ms and the timeout don't after the second one
+			wantAttemptErrors: 1,
+		},
+		{
+			name:            "no time for the timeout of a retry",
+			handler:         &flakyHandler{failures: 5, statusCode: http.StatusServiceUnavailable},
+			intervalSeconds: 1, // the timeout is the interval
+			retry:           config.RetryPolicy{Attempts: 5, BackoffMilliseconds: 1, RetryStatusCodes: []int{http.StatusServiceUnavailable}},
+			wantStatusCode:  http.StatusServiceUnavailable,
+			wantAttempts:    1,
+		},
+		{
+			name:         "error class that is not retryable",
+			handler:      &flakyHandler{failures: 1},
+			retry:        config.RetryPolicy{Attempts: 3, BackoffMilliseconds: 1, RetryOn: []string{"timeout"}},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeServer := httptest.NewServer(tt.handler)
+			defer fakeServer.Close()
+
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			require.NoError(t, m.Monitor(context.Background(), config.SiteElement{URL: fakeServer.URL, IntervalSeconds: tt.intervalSeconds, TimeoutSeconds: tt.timeoutSeconds, Retry: tt.retry}))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, tt.wantStatusCode, msg.StatusCode)
+			assert.Equal(t, tt.wantAttempts, msg.Attempts)
+			assert.Len(t, msg.AttemptErrors, tt.wantAttemptErrors)
+			assert.Truef(t, msg.Err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, msg.Err)
+		})
+	}
+}
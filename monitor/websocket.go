@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/pbabbicola/go-monitor/config"
+)
+
+// monitorWebSocket performs the upgrade handshake against the website and, if configured, sends a message and waits for a reply that matches the regexp.
+func (m *DefaultMonitorer) monitorWebSocket(ctx context.Context, website config.SiteElement, message *Message) {
+	header := http.Header{}
+	for name, value := range website.Headers {
+		header.Set(name, value)
+	}
+
+	conn, resp, err := websocket.Dial(ctx, website.URL, &websocket.DialOptions{HTTPClient: m.client, HTTPHeader: header}) //nolint:bodyclose // The websocket package takes care of the handshake response body.
+
+	message.HandshakeDuration = time.Since(message.Timestamp)
+	message.Duration = message.HandshakeDuration
+
+	if resp != nil {
+		message.StatusCode = resp.StatusCode // Records eg. a 200 if the endpoint is not upgrading the connection.
+	}
+
+	if err != nil {
+		message.Err = fmt.Errorf("performing websocket handshake with %v: %w", website, err)
+
+		if message.StatusCode != 0 && message.StatusCode != http.StatusSwitchingProtocols {
+			message.ErrorClass = ErrorClassHTTPStatus
+		}
+
+		return
+	}
+	defer conn.CloseNow() //nolint:errcheck // Only matters if we didn't close normally.
+
+	if website.WebSocket.Send == "" && website.Regexp == nil {
+		conn.Close(websocket.StatusNormalClosure, "") //nolint:errcheck // The check is already done.
+
+		return
+	}
+
+	roundTripStart := time.Now()
+
+	if website.WebSocket.Send != "" {
+		err = conn.Write(ctx, websocket.MessageText, []byte(website.WebSocket.Send))
+		if err != nil {
+			message.Err = fmt.Errorf("sending websocket message to %v: %w", website, err)
+
+			return
+		}
+	}
+
+	for { // Servers may push other messages (eg. heartbeats), so we keep reading until we find the one we are waiting for or the context times out.
+		_, reply, err := conn.Read(ctx)
+		if err != nil {
+			message.Duration = time.Since(message.Timestamp)
+			message.Err = fmt.Errorf("waiting for websocket reply from %v: %w", website, err)
+
+			return
+		}
+
+		if website.Regexp == nil || website.Regexp.Match(reply) {
+			break
+		}
+	}
+
+	message.RoundTripDuration = time.Since(roundTripStart)
+	message.Duration = time.Since(message.Timestamp)
+	message.RegexpMatches = website.Regexp != nil
+
+	conn.Close(websocket.StatusNormalClosure, "") //nolint:errcheck // The check is already done.
+}
@@ -0,0 +1,134 @@
+package monitor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/monitor"
+)
+
+// echoHandler upgrades the connection and echoes every message back, prefixed with "echo: ".
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow() //nolint:errcheck // Test server.
+
+	for {
+		messageType, data, err := conn.Read(r.Context())
+		if err != nil {
+			return
+		}
+
+		err = conn.Write(r.Context(), messageType, append([]byte("echo: "), data...))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func TestDefaultMonitorer_Monitor_WebSocket(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", echoHandler)
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK) // The plain GET works, but the socket layer doesn't.
+	})
+
+	fakeServer := httptest.NewServer(mux)
+	defer fakeServer.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(fakeServer.URL, "http")
+
+	tests := []struct {
+		name              string
+		website           config.SiteElement
+		wantStatusCode    int
+		wantRegexpMatches bool
+		wantRoundTrip     bool
+		wantErrorClass    monitor.ErrorClass
+		wantErr           bool
+	}{
+		{
+			name: "handshake only",
+			website: config.SiteElement{
+				URL:  wsURL + "/ws",
+				Type: config.CheckTypeWebSocket,
+			},
+			wantStatusCode: http.StatusSwitchingProtocols,
+		},
+		{
+			name: "reply matches",
+			website: config.SiteElement{
+				URL:       wsURL + "/ws",
+				Regexp:    regexp.MustCompile("echo: ping"),
+				Type:      config.CheckTypeWebSocket,
+				WebSocket: config.WebSocketCheck{Send: "ping"},
+			},
+			wantStatusCode:    http.StatusSwitchingProtocols,
+			wantRegexpMatches: true,
+			wantRoundTrip:     true,
+		},
+		{
+			name: "any reply without regexp",
+			website: config.SiteElement{
+				URL:       wsURL + "/ws",
+				Type:      config.CheckTypeWebSocket,
+				WebSocket: config.WebSocketCheck{Send: "ping"},
+			},
+			wantStatusCode: http.StatusSwitchingProtocols,
+			wantRoundTrip:  true,
+		},
+		{
+			name: "reply does not match within the timeout",
+			website: config.SiteElement{
+				URL:            wsURL + "/ws",
+				Regexp:         regexp.MustCompile("pong"),
+				TimeoutSeconds: 1,
+				Type:           config.CheckTypeWebSocket,
+				WebSocket:      config.WebSocketCheck{Send: "ping"},
+			},
+			wantStatusCode: http.StatusSwitchingProtocols,
+			wantErrorClass: monitor.ErrorClassTimeout,
+			wantErr:        true,
+		},
+		{
+			name: "endpoint does not upgrade",
+			website: config.SiteElement{
+				URL:  wsURL + "/broken",
+				Type: config.CheckTypeWebSocket,
+			},
+			wantStatusCode: http.StatusOK,
+			wantErrorClass: monitor.ErrorClassHTTPStatus,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messageQueue := make(chan monitor.Message, 1)
+
+			m := monitor.NewDefaultMonitorer(fakeServer.Client(), messageQueue)
+
+			require.NoError(t, m.Monitor(context.Background(), tt.website))
+
+			msg := <-messageQueue
+
+			assert.Equal(t, config.CheckTypeWebSocket, msg.CheckType)
+			assert.Equal(t, tt.wantStatusCode, msg.StatusCode)
+			assert.Equal(t, tt.wantRegexpMatches, msg.RegexpMatches)
+			assert.Equal(t, tt.wantRoundTrip, msg.RoundTripDuration > 0)
+			assert.Positive(t, msg.HandshakeDuration)
+			assert.Equal(t, tt.wantErrorClass, msg.ErrorClass)
+			assert.Truef(t, msg.Err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, msg.Err)
+		})
+	}
+}
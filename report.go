@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/consumers/postgres"
+	"github.com/pbabbicola/go-monitor/report"
+)
+
+// Exit codes of generateReport.
+const (
+	reportOK     = 0
+	reportFailed = 1
+)
+
+// errInvalidDate is returned when a date of the report is neither a date nor RFC 3339.
+var errInvalidDate = errors.New("it must be a date (2006-01-02) or RFC 3339")
+
+// reportDate is a flag of a time, written as RFC 3339 or as a date, which is midnight in UTC.
+type reportDate struct {
+	time *time.Time
+}
+
+func (d reportDate) String() string {
+	if d.time == nil || d.time.IsZero() {
+		return ""
+	}
+
+	return d.time.Format(time.RFC3339)
+}
+
+func (d reportDate) Set(value string) error {
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		parsed, err = time.Parse(time.RFC3339, value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", value, errInvalidDate)
+	}
+
+	*d.time = parsed
+
+	return nil
+}
+
+// lastMonth returns the start of the previous month and the start of the current one, in UTC.
+func lastMonth(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return to.AddDate(0, -1, 0), to
+}
+
+// generateReport reads the results of a time range from Postgres and writes a report of them: uptime, incidents, latency percentiles and status codes of every site.
+// It returns the exit code.
+//
+// Usage: go-monitor report [-from date] [-to date] [-url url] [-group group] [-format csv|json|html] [-o file]. The range defaults to the previous month, and the database to DATABASE_URL.
+func generateReport(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var filter report.Filter
+
+	filter.From, filter.To = lastMonth(time.Now())
+
+	flags.Var(reportDate{&filter.From}, "from", "start of the range, included: a date or RFC 3339")
+	flags.Var(reportDate{&filter.To}, "to", "end of the range, excluded: a date or RFC 3339")
+	flags.StringVar(&filter.URL, "url", "", "only this site, as it's stored in the results")
+	flags.StringVar(&filter.Group, "group", "", "only the sites of this group")
+
+	format := report.FormatCSV
+	flags.Var(&format, "format", "csv, json or html")
+
+	output := flags.String("o", "", "file to write the report to, instead of stdout")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return reportFailed
+	}
+
+	if !filter.To.After(filter.From) {
+		fmt.Fprintln(stderr, "The end of the range must be after its start.") //nolint:errcheck // There is nowhere else to write it.
+
+		return reportFailed
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		fmt.Fprintln(stderr, "Nowhere to read the results from: set DATABASE_URL.") //nolint:errcheck // There is nowhere else to write it.
+
+		return reportFailed
+	}
+
+	db, err := postgres.NewConnection(ctx, databaseURL)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed connecting to the database: %v\n", err) //nolint:errcheck // There is nowhere else to write it.
+
+		return reportFailed
+	}
+	defer db.Close()
+
+	generated, err := report.Generate(ctx, db, filter)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed generating the report: %v\n", err) //nolint:errcheck // There is nowhere else to write it.
+
+		return reportFailed
+	}
+
+	if *output == "" {
+		err = report.Write(stdout, generated, format)
+	} else {
+		err = writeReportFile(*output, generated, format)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed writing the report: %v\n", err) //nolint:errcheck // There is nowhere else to write it.
+
+		return reportFailed
+	}
+
+	return reportOK
+}
+
+// writeReportFile writes the report to a file, replacing it if it's there.
+func writeReportFile(filename string, generated report.Report, format report.Format) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("creating %v: %w", filename, err)
+	}
+
+	err = report.Write(file, generated, format)
+	if err != nil {
+		file.Close() //nolint:errcheck // The write already failed.
+
+		return err //nolint:wrapcheck // It's already clear enough.
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("closing %v: %w", filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is how a report is written.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"  // A table of the sites, an empty line, and a table of the incidents. It's the default.
+	FormatJSON Format = "json" // The whole [Report].
+	FormatHTML Format = "html" // A standalone page, to send as it is.
+)
+
+// ErrUnknownFormat is returned when a format is not one of the known ones.
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// UnmarshalText validates the format, so a typo fails before querying anything.
+func (f *Format) UnmarshalText(text []byte) error {
+	switch format := Format(text); format {
+	case "":
+		*f = FormatCSV
+	case FormatCSV, FormatJSON, FormatHTML:
+		*f = format
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	return nil
+}
+
+// String returns the format, so it can be used as a flag.
+func (f Format) String() string {
+	return string(f)
+}
+
+// Set parses the format, so it can be used as a flag.
+func (f *Format) Set(value string) error {
+	return f.UnmarshalText([]byte(value))
+}
+
+// Write writes the report in a format.
+func Write(w io.Writer, report Report, format Format) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(report)
+		if err != nil {
+			return fmt.Errorf("writing json: %w", err)
+		}
+
+		return nil
+	case FormatHTML:
+		err := page.Execute(w, report)
+		if err != nil {
+			return fmt.Errorf("writing html: %w", err)
+		}
+
+		return nil
+	case "", FormatCSV:
+		return writeCSV(w, report)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// writeCSV writes the sites and the incidents as two tables, with an empty line between them.
+func writeCSV(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+
+	records := [][]string{{"url", "group", "checks", "failures", "uptime_percent", "incidents", "downtime_seconds", "flapping_checks", "p50_milliseconds", "p90_milliseconds", "p95_milliseconds", "p99_milliseconds", "status_codes"}}
+
+	for _, site := range report.Sites {
+		records = append(records, []string{
+			site.URL,
+			site.Group,
+			strconv.FormatInt(site.Checks, 10),
+			strconv.FormatInt(site.Failures, 10),
+			formatFloat(site.UptimePercent),
+			strconv.Itoa(site.Incidents),
+			formatFloat(site.DowntimeSeconds),
+			strconv.FormatInt(site.FlappingChecks, 10),
+			formatFloat(site.LatencyMilliseconds.P50),
+			formatFloat(site.LatencyMilliseconds.P90),
+			formatFloat(site.LatencyMilliseconds.P95),
+			formatFloat(site.LatencyMilliseconds.P99),
+			statusCodes(site.StatusCodes),
+		})
+	}
+
+	records = append(records, nil, []string{"url", "start", "end", "last_failure", "duration_seconds", "checks", "error_class"})
+
+	for _, incident := range report.Incidents {
+		records = append(records, []string{
+			incident.URL,
+			formatTime(incident.Start),
+			formatTime(incident.End),
+			formatTime(incident.LastFailure),
+			formatFloat(incident.DurationSeconds),
+			strconv.FormatInt(incident.Checks, 10),
+			incident.ErrorClass,
+		})
+	}
+
+	err := writer.WriteAll(records)
+	if err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+
+	return nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatTime formats a time as RFC 3339, or as nothing if it's zero.
+func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+
+	return value.UTC().Format(time.RFC3339)
+}
+
+// statusCodes formats the checks by status code, eg. 200:1440 503:2.
+func statusCodes(codes map[int]int64) string {
+	formatted := make([]string, 0, len(codes))
+
+	for _, code := range slices.Sorted(maps.Keys(codes)) {
+		formatted = append(formatted, strconv.Itoa(code)+":"+strconv.FormatInt(codes[code], 10))
+	}
+
+	return strings.Join(formatted, " ")
+}
+
+//go:embed report.html
+var pageTemplate string
+
+// page is the HTML report. Everything is inline, so it can be attached to an email.
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 3, 64) + "%" //nolint:mnd // Three decimals are enough to tell 99.9% from 99.95%.
+	},
+	"milliseconds": func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 0, 64) + " ms"
+	},
+	"duration": func(seconds float64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+	"time":        formatTime,
+	"statusCodes": statusCodes,
+}).Parse(pageTemplate))
@@ -0,0 +1,201 @@
+// Package report summarizes the results stored in Postgres over a time range: uptime, incidents, latency percentiles and status codes of every site, eg. for the monthly availability reports.
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/database"
+)
+
+// checksQuery has every check within the range ($1 and $2) of the sites that match the filter: a URL ($3) and a group ($4), unless they are empty.
+// Checks in maintenance windows are left out, so they don't count for the uptime. Rows from before there were error classes only have the error.
+const checksQuery = `select url, site_group, ts, duration_milliseconds, coalesce(status_code, 0) as status_code,
+		coalesce(error_class, '') <> '' or coalesce(error, '') <> '' as bad,
+		coalesce(nullif(error_class, ''), 'unknown') as error_class, flapping
+	from logs
+	where ts >= $1 and ts < $2 and ($3::text = '' or url = $3) and ($4::text = '' or site_group = $4) and not in_maintenance`
+
+// sitesQuery counts the checks, failures and checks while flapping of every site. Latency percentiles are only of the checks that succeeded, so timeouts don't skew them.
+const sitesQuery = `with checks as (` + checksQuery + `)
+select url, max(site_group), count(*), count(*) filter (where bad), count(*) filter (where flapping),
+	coalesce(percentile_cont(0.5) within group (order by duration_milliseconds) filter (where not bad), 0),
+	coalesce(percentile_cont(0.9) within group (order by duration_milliseconds) filter (where not bad), 0),
+	coalesce(percentile_cont(0.95) within group (order by duration_milliseconds) filter (where not bad), 0),
+	coalesce(percentile_cont(0.99) within group (order by duration_milliseconds) filter (where not bad), 0)
+from checks
+group by url
+order by url`
+
+// statusCodesQuery counts the checks of every site by status code. Checks that aren't HTTP, or that didn't get a response, have 0.
+const statusCodesQuery = `with checks as (` + checksQuery + `)
+select url, status_code, count(*)
+from checks
+group by url, status_code
+order by url, status_code`
+
+// incidentsQuery finds the incidents: consecutive failed checks of a site. Every check gets a streak, which is the same for consecutive checks that failed or not, and failed streaks are the incidents.
+// The end of an incident is the first check that succeeded after it, which is null if it's still failing at the end of the range.
+const incidentsQuery = `with checks as (` + checksQuery + `),
+streaks as (
+	select url, ts, bad, error_class,
+		lead(ts) over (partition by url order by ts) as next_ts,
+		row_number() over (partition by url order by ts) - row_number() over (partition by url, bad order by ts) as streak
+	from checks
+)
+select url, min(ts), max(ts), (array_agg(next_ts order by ts desc))[1], count(*), mode() within group (order by error_class)
+from streaks
+where bad
+group by url, streak
+order by min(ts), url`
+
+// Filter says which results go into a report. Sites are filtered by URL, as [config.SiteElement.String] shows it, and by group, unless they are empty.
+type Filter struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	URL   string    `json:"url,omitempty"`
+	Group string    `json:"group,omitempty"`
+}
+
+// Report is the summary of the results that match a filter.
+type Report struct {
+	Filter
+	GeneratedAt time.Time  `json:"generated_at"`
+	Sites       []Site     `json:"sites"`
+	Incidents   []Incident `json:"incidents"`
+}
+
+// Site is the summary of the results of a site.
+type Site struct {
+	URL                 string        `json:"url"`
+	Group               string        `json:"group,omitempty"`
+	Checks              int64         `json:"checks"`
+	Failures            int64         `json:"failures"`
+	FlappingChecks      int64         `json:"flapping_checks"` // Checks while the site kept going up and down.
+	UptimePercent       float64       `json:"uptime_percent"`
+	Incidents           int           `json:"incidents"`
+	DowntimeSeconds     float64       `json:"downtime_seconds"`
+	LatencyMilliseconds Percentiles   `json:"latency_milliseconds"`
+	StatusCodes         map[int]int64 `json:"status_codes"`
+}
+
+// Percentiles of the duration of the checks that succeeded.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// Incident is a time when a site was failing, from the first failed check to the first one that succeeded after it.
+// End is zero if it was still failing at the end of the range, and then the duration is until the last failed check.
+type Incident struct {
+	URL             string    `json:"url"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end,omitzero"`
+	LastFailure     time.Time `json:"last_failure"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Checks          int64     `json:"checks"`
+	ErrorClass      string    `json:"error_class"` // The most common one, if there was more than one.
+}
+
+// Generate queries the results that match the filter and summarizes them.
+func Generate(ctx context.Context, db *sql.DB, filter Filter) (Report, error) {
+	report := Report{Filter: filter, GeneratedAt: time.Now().UTC(), Sites: []Site{}, Incidents: []Incident{}}
+	args := []any{filter.From, filter.To, filter.URL, filter.Group}
+
+	indexes := map[string]int{} // index of every site in report.Sites
+
+	err := database.Query(ctx, db, sitesQuery, args, func(rows *sql.Rows) error {
+		var site Site
+
+		err := rows.Scan(&site.URL, &site.Group, &site.Checks, &site.Failures, &site.FlappingChecks, &site.LatencyMilliseconds.P50, &site.LatencyMilliseconds.P90, &site.LatencyMilliseconds.P95, &site.LatencyMilliseconds.P99)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		site.StatusCodes = map[int]int64{}
+		if site.Checks > 0 {
+			site.UptimePercent = 100 * float64(site.Checks-site.Failures) / float64(site.Checks) //nolint:mnd // It's a percentage.
+		}
+
+		indexes[site.URL] = len(report.Sites)
+		report.Sites = append(report.Sites, site)
+
+		return nil
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("querying sites: %w", err)
+	}
+
+	err = database.Query(ctx, db, statusCodesQuery, args, func(rows *sql.Rows) error {
+		var (
+			url        string
+			statusCode int
+			checks     int64
+		)
+
+		err := rows.Scan(&url, &statusCode, &checks)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		if index, ok := indexes[url]; ok {
+			report.Sites[index].StatusCodes[statusCode] = checks
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Report{}, fmt.Errorf("querying status codes: %w", err)
+	}
+
+	report.Incidents, err = Incidents(ctx, db, filter)
+	if err != nil {
+		return Report{}, err
+	}
+
+	for _, incident := range report.Incidents {
+		if index, ok := indexes[incident.URL]; ok {
+			report.Sites[index].Incidents++
+			report.Sites[index].DowntimeSeconds += incident.DurationSeconds
+		}
+	}
+
+	return report, nil
+}
+
+// Incidents queries the incidents of the sites that match the filter, sorted by start.
+func Incidents(ctx context.Context, db *sql.DB, filter Filter) ([]Incident, error) {
+	incidents := []Incident{}
+
+	err := database.Query(ctx, db, incidentsQuery, []any{filter.From, filter.To, filter.URL, filter.Group}, func(rows *sql.Rows) error {
+		var (
+			incident Incident
+			end      sql.NullTime
+		)
+
+		err := rows.Scan(&incident.URL, &incident.Start, &incident.LastFailure, &end, &incident.Checks, &incident.ErrorClass)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		incident.End = end.Time
+		if end.Valid {
+			incident.DurationSeconds = incident.End.Sub(incident.Start).Seconds()
+		} else {
+			incident.DurationSeconds = incident.LastFailure.Sub(incident.Start).Seconds()
+		}
+
+		incidents = append(incidents, incident)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying incidents: %w", err)
+	}
+
+	return incidents, nil
+}
@@ -0,0 +1,229 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/report"
+)
+
+var (
+	sitesQuery       = regexp.QuoteMeta("percentile_cont(0.5)")
+	statusCodesQuery = regexp.QuoteMeta("group by url, status_code")
+	incidentsQuery   = regexp.QuoteMeta("array_agg(next_ts order by ts desc)")
+)
+
+func TestGenerate(t *testing.T) {
+	from := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2026, time.September, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		filter         report.Filter
+		dbExpectations func(mock sqlmock.Sqlmock)
+		wantSites      []report.Site
+		wantIncidents  []report.Incident
+		wantErr        bool
+	}{
+		{
+			name:   "sites and incidents",
+			filter: report.Filter{From: from, To: to, Group: "website"},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sitesQuery).WithArgs(from, to, "", "website").WillReturnRows(
+					sqlmock.NewRows([]string{"url", "site_group", "checks", "failures", "flapping_checks", "p50", "p90", "p95", "p99"}).
+						AddRow("https://example.org", "website", 1000, 10, 40, 120.0, 250.0, 300.0, 900.5).
+						AddRow("https://example.org/blog", "website", 500, 0, 0, 80.0, 90.0, 95.0, 99.0),
+				)
+				mock.ExpectQuery(statusCodesQuery).WithArgs(from, to, "", "website").WillReturnRows(
+					sqlmock.NewRows([]string{"url", "status_code", "count"}).
+						AddRow("https://example.org", 0, 2).
+						AddRow("https://example.org", 200, 990).
+						AddRow("https://example.org", 503, 8).
+						AddRow("https://example.org/blog", 200, 500),
+				)
+				mock.ExpectQuery(incidentsQuery).WithArgs(from, to, "", "website").WillReturnRows(
+					sqlmock.NewRows([]string{"url", "start", "last_failure", "end", "checks", "error_class"}).
+						AddRow("https://example.org", start, start.Add(4*time.Minute), start.Add(5*time.Minute), 5, "http_status").
+						AddRow("https://example.org", to.Add(-time.Hour), to.Add(-time.Minute), nil, 5, "timeout"),
+				)
+			},
+			wantSites: []report.Site{
+				{
+					URL:                 "https://example.org",
+					Group:               "website",
+					Checks:              1000,
+					Failures:            10,
+					FlappingChecks:      40,
+					UptimePercent:       99,
+					Incidents:           2,
+					DowntimeSeconds:     5*60 + 59*60,
+					LatencyMilliseconds: report.Percentiles{P50: 120, P90: 250, P95: 300, P99: 900.5},
+					StatusCodes:         map[int]int64{0: 2, 200: 990, 503: 8},
+				},
+				{
+					URL:                 "https://example.org/blog",
+					Group:               "website",
+					Checks:              500,
+					UptimePercent:       100,
+					LatencyMilliseconds: report.Percentiles{P50: 80, P90: 90, P95: 95, P99: 99},
+					StatusCodes:         map[int]int64{200: 500},
+				},
+			},
+			wantIncidents: []report.Incident{
+				{URL: "https://example.org", Start: start, End: start.Add(5 * time.Minute), LastFailure: start.Add(4 * time.Minute), DurationSeconds: 300, Checks: 5, ErrorClass: "http_status"},
+				{URL: "https://example.org", Start: to.Add(-time.Hour), LastFailure: to.Add(-time.Minute), DurationSeconds: 59 * 60, Checks: 5, ErrorClass: "timeout"},
+			},
+		},
+		{
+			name:   "no results",
+			filter: report.Filter{From: from, To: to, URL: "https://nope.example.org"},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sitesQuery).WithArgs(from, to, "https://nope.example.org", "").WillReturnRows(sqlmock.NewRows([]string{"url", "site_group", "checks", "failures", "flapping_checks", "p50", "p90", "p95", "p99"}))
+				mock.ExpectQuery(statusCodesQuery).WillReturnRows(sqlmock.NewRows([]string{"url", "status_code", "count"}))
+				mock.ExpectQuery(incidentsQuery).WillReturnRows(sqlmock.NewRows([]string{"url", "start", "last_failure", "end", "checks", "error_class"}))
+			},
+			wantSites:     []report.Site{},
+			wantIncidents: []report.Incident{},
+		},
+		{
+			name:   "failed query",
+			filter: report.Filter{From: from, To: to},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sitesQuery).WillReturnRows(sqlmock.NewRows([]string{"url", "site_group", "checks", "failures", "flapping_checks", "p50", "p90", "p95", "p99"}))
+				mock.ExpectQuery(statusCodesQuery).WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			got, err := report.Generate(context.Background(), db, tt.filter)
+
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.Equal(t, tt.wantSites, got.Sites)
+			assert.Equal(t, tt.wantIncidents, got.Incidents)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func sampleReport() report.Report {
+	start := time.Date(2026, time.September, 10, 12, 0, 0, 0, time.UTC)
+
+	return report.Report{
+		Filter:      report.Filter{From: time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		GeneratedAt: time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC),
+		Sites: []report.Site{{
+			URL:                 "https://example.org",
+			Group:               "website",
+			Checks:              1000,
+			Failures:            10,
+			FlappingChecks:      40,
+			UptimePercent:       99,
+			Incidents:           2,
+			DowntimeSeconds:     3840,
+			LatencyMilliseconds: report.Percentiles{P50: 120, P90: 250, P95: 300, P99: 900.5},
+			StatusCodes:         map[int]int64{200: 990, 503: 10},
+		}},
+		Incidents: []report.Incident{
+			{URL: "https://example.org", Start: start, End: start.Add(5 * time.Minute), LastFailure: start.Add(4 * time.Minute), DurationSeconds: 300, Checks: 5, ErrorClass: "http_status"},
+			{URL: "https://example.org", Start: start.Add(time.Hour), LastFailure: start.Add(2 * time.Hour), DurationSeconds: 3540, Checks: 5, ErrorClass: "timeout"},
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  report.Format
+		want    string   // the whole output
+		partial []string // parts of the output, if want is empty
+		wantErr error
+	}{
+		{
+			name:   "csv",
+			format: report.FormatCSV,
+			want: "url,group,checks,failures,uptime_percent,incidents,downtime_seconds,flapping_checks,p50_milliseconds,p90_milliseconds,p95_milliseconds,p99_milliseconds,status_codes\n" +
+				"https://example.org,website,1000,10,99,2,3840,40,120,250,300,900.5,200:990 503:10\n" +
+				"\n" +
+				"url,start,end,last_failure,duration_seconds,checks,error_class\n" +
+				"https://example.org,2026-09-10T12:00:00Z,2026-09-10T12:05:00Z,2026-09-10T12:04:00Z,300,5,http_status\n" +
+				"https://example.org,2026-09-10T13:00:00Z,,2026-09-10T14:00:00Z,3540,5,timeout\n",
+		},
+		{
+			name:   "html",
+			format: report.FormatHTML,
+			partial: []string{
+				"<title>Availability report 2026-09-01T00:00:00Z to 2026-10-01T00:00:00Z</title>",
+				`<td class="bad">99.000%</td>`,
+				"<td>900 ms</td>",
+				"<td>1h4m0s</td>",
+				`<td class="bad">40</td>`,
+				`<td class="text">200:990 503:10</td>`,
+				`<span class="bad">still failing</span>`,
+			},
+		},
+		{
+			name:    "unknown",
+			format:  "pdf",
+			wantErr: report.ErrUnknownFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+
+			err := report.Write(&output, sampleReport(), tt.format)
+			require.ErrorIs(t, err, tt.wantErr)
+
+			if tt.want != "" {
+				assert.Equal(t, tt.want, output.String())
+			}
+
+			for _, part := range tt.partial {
+				assert.Contains(t, output.String(), part)
+			}
+		})
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	var output bytes.Buffer
+
+	require.NoError(t, report.Write(&output, sampleReport(), report.FormatJSON))
+
+	var got report.Report
+
+	require.NoError(t, json.Unmarshal(output.Bytes(), &got))
+	assert.Equal(t, sampleReport(), got)
+	assert.Contains(t, output.String(), `"status_codes": {`)
+	assert.NotContains(t, output.String(), `"end": "0001`, "ongoing incidents have no end")
+}
+
+func TestFormat_UnmarshalText(t *testing.T) {
+	var format report.Format
+
+	assert.NoError(t, format.UnmarshalText([]byte("")))
+	assert.Equal(t, report.FormatCSV, format)
+
+	assert.NoError(t, format.UnmarshalText([]byte("html")))
+	assert.Equal(t, report.FormatHTML, format)
+
+	assert.ErrorIs(t, format.UnmarshalText([]byte("pdf")), report.ErrUnknownFormat)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastMonth(t *testing.T) {
+	from, to := lastMonth(time.Date(2026, time.January, 15, 23, 0, 0, 0, time.FixedZone("CET", 3600)))
+
+	assert.Equal(t, time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), to)
+}
+
+func TestGenerateReport_Errors(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStderr string
+	}{
+		{
+			name:       "unknown format",
+			args:       []string{"-format", "pdf"},
+			wantStderr: `unknown report format: "pdf"`,
+		},
+		{
+			name:       "invalid date",
+			args:       []string{"-from", "yesterday"},
+			wantStderr: `parsing "yesterday": it must be a date (2006-01-02) or RFC 3339`,
+		},
+		{
+			name:       "empty range",
+			args:       []string{"-from", "2026-10-01", "-to", "2026-09-01"},
+			wantStderr: "The end of the range must be after its start.",
+		},
+		{
+			name:       "no database",
+			args:       []string{"-from", "2026-09-01", "-to", "2026-10-01T00:00:00+02:00"},
+			wantStderr: "Nowhere to read the results from: set DATABASE_URL.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			code := generateReport(context.Background(), tt.args, &stdout, &stderr)
+
+			assert.Equal(t, reportFailed, code)
+			assert.Contains(t, stderr.String(), tt.wantStderr)
+			assert.Empty(t, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Package slo computes the service level objectives of the sites from their results in Postgres: how much of the error budget is left, and how fast it's burning.
+package slo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/consumers/metrics"
+)
+
+// Alert says whether the error budget of an SLO is burning too fast.
+type Alert string
+
+const (
+	AlertNone     Alert = ""          // The budget is fine, or at least not burning fast.
+	AlertFastBurn Alert = "fast_burn" // 2% of a 30 day budget is gone in the last hour, and it's still burning.
+	AlertSlowBurn Alert = "slow_burn" // 5% of a 30 day budget is gone in the last 6 hours, and it's still burning.
+)
+
+// burnWindows are the windows the burn rate is computed over, shortest first.
+var burnWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// burnAlert fires when the burn rate is above the threshold in both windows. The short window makes it stop soon after the burning does, instead of waiting for the long one to catch up.
+// These are the ones of the Google SRE workbook, which assume a 30 day window, but they are good enough for the others.
+type burnAlert struct {
+	alert     Alert
+	long      time.Duration
+	short     time.Duration
+	threshold float64
+}
+
+var burnAlerts = []burnAlert{
+	{alert: AlertFastBurn, long: time.Hour, short: 5 * time.Minute, threshold: 14.4},   //nolint:mnd // See the comment of the alert.
+	{alert: AlertSlowBurn, long: 6 * time.Hour, short: 30 * time.Minute, threshold: 6}, //nolint:mnd // See the comment of the alert.
+}
+
+// countsQuery counts all the checks and the bad ones, within the window of the SLO ($3) and within every burn window ($4 to $7, the same as [burnWindows]).
+// A check is bad if it failed, or if it was slower than the latency threshold ($2), if there is one. Checks in maintenance windows don't count.
+// Rows from before there were error classes only have the error.
+const countsQuery = `with results as (
+	select ts, (coalesce(error_class, '') <> '' or coalesce(error, '') <> '' or ($2::bigint > 0 and duration_milliseconds > $2::bigint)) as bad
+	from logs
+	where %s = $1 and ts >= $3 and not in_maintenance
+)
+select
+	count(*), count(*) filter (where bad),
+	count(*) filter (where ts >= $4), count(*) filter (where ts >= $4 and bad),
+	count(*) filter (where ts >= $5), count(*) filter (where ts >= $5 and bad),
+	count(*) filter (where ts >= $6), count(*) filter (where ts >= $6 and bad),
+	count(*) filter (where ts >= $7), count(*) filter (where ts >= $7 and bad)
+from results`
+
+var (
+	siteQuery  = fmt.Sprintf(countsQuery, "url")
+	groupQuery = fmt.Sprintf(countsQuery, "site_group")
+)
+
+// Status is how an SLO is doing, as of the last update.
+type Status struct {
+	Name                         string          `json:"name"`
+	Scope                        config.SLOScope `json:"scope"`
+	URL                          string          `json:"url,omitempty"` // Only for site SLOs, as [config.SiteElement.String] shows it.
+	Group                        string          `json:"group,omitempty"`
+	Owner                        string          `json:"owner,omitempty"`
+	TargetPercent                float64         `json:"target_percent"`
+	LatencyThresholdMilliseconds int             `json:"latency_threshold_milliseconds,omitempty"`
+	WindowDays                   int             `json:"window_days"`
+	Checks                       int64           `json:"checks"`
+	BadChecks                    int64           `json:"bad_checks"`
+	AttainedPercent              float64         `json:"attained_percent"`
+	// ErrorBudgetRemaining is the part of the error budget that is left: 1 if there were no bad checks, 0 if it's all gone, and below 0 if the SLO was missed.
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRates are how fast the budget is burning, by window (eg. 1h). At 1, the budget lasts exactly the window of the SLO.
+	BurnRates map[string]float64 `json:"burn_rates"`
+	Alert     Alert              `json:"alert"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// objective is an SLO of the site list, with the defaults filled in.
+type objective struct {
+	config.SLO
+	url   string
+	group string
+	owner string
+}
+
+// key identifies the objective, so group SLOs that are in more than one site of the group are only computed once.
+func (o objective) key() string {
+	if o.Scope == config.SLOScopeGroup {
+		return string(o.Scope) + " " + o.group + " " + o.Name
+	}
+
+	return string(o.Scope) + " " + o.url + " " + o.Name
+}
+
+// Notifier is told about an SLO whenever its alert changes, eg. [alert.NotifySLO].
+type Notifier func(ctx context.Context, status Status)
+
+// Calculator computes the SLOs of the site list every now and then, and keeps their status for the API and the metrics.
+type Calculator struct {
+	db     *sql.DB
+	notify Notifier
+
+	mut        *sync.Mutex
+	objectives []objective
+	statuses   map[string]Status // by objective key
+}
+
+// New creates a new Calculator, without any SLO until [Calculator.SetSites] is called. The notifier can be nil if nobody wants to know.
+func New(db *sql.DB, notify Notifier) *Calculator {
+	return &Calculator{
+		db:       db,
+		notify:   notify,
+		mut:      &sync.Mutex{},
+		statuses: map[string]Status{},
+	}
+}
+
+// SetSites sets the SLOs of the site list, eg. after it's reloaded. If more than one site of a group has a group SLO with the same name, the first one is used.
+func (c *Calculator) SetSites(sites []config.SiteElement) {
+	var objectives []objective
+
+	seen := map[string]bool{}
+
+	for _, site := range sites {
+		for _, definition := range site.SLOs {
+			current := objective{SLO: definition, url: site.String(), group: site.Group, owner: site.Owner}
+
+			if current.Scope == "" {
+				current.Scope = config.SLOScopeSite
+			}
+
+			if current.WindowDays <= 0 {
+				current.WindowDays = config.DefaultSLOWindowDays
+			}
+
+			if current.Name == "" {
+				current.Name = "availability"
+				if current.LatencyThresholdMilliseconds > 0 {
+					current.Name = "latency"
+				}
+			}
+
+			if current.Scope == config.SLOScopeGroup {
+				current.url = ""
+			}
+
+			if seen[current.key()] {
+				continue
+			}
+
+			seen[current.key()] = true
+			objectives = append(objectives, current)
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.objectives = objectives
+}
+
+// Update computes every SLO again, and notifies the ones whose alert changed. If an SLO fails, its previous status is kept and the error is returned with the others.
+func (c *Calculator) Update(ctx context.Context) error {
+	c.mut.Lock()
+	objectives := slices.Clone(c.objectives)
+	previous := c.statuses
+	c.mut.Unlock()
+
+	now := time.Now()
+	statuses := make(map[string]Status, len(objectives))
+
+	var errs []error
+
+	for _, current := range objectives {
+		status, err := c.compute(ctx, current, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("computing slo %v: %w", current.key(), err))
+
+			if status, ok := previous[current.key()]; ok {
+				statuses[current.key()] = status
+			}
+
+			continue
+		}
+
+		statuses[current.key()] = status
+
+		if c.notify != nil && status.Alert != previous[current.key()].Alert {
+			c.notify(ctx, status)
+		}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.statuses = statuses
+
+	return errors.Join(errs...)
+}
+
+// compute queries the results of an SLO and computes its status.
+func (c *Calculator) compute(ctx context.Context, current objective, now time.Time) (Status, error) {
+	query, value := siteQuery, current.url
+	if current.Scope == config.SLOScopeGroup {
+		query, value = groupQuery, current.group
+	}
+
+	args := []any{value, current.LatencyThresholdMilliseconds, now.AddDate(0, 0, -current.WindowDays)}
+	for _, window := range burnWindows {
+		args = append(args, now.Add(-window))
+	}
+
+	var checks, bad int64
+
+	windowChecks := make([]int64, len(burnWindows))
+	windowBad := make([]int64, len(burnWindows))
+
+	dest := []any{&checks, &bad}
+	for i := range burnWindows {
+		dest = append(dest, &windowChecks[i], &windowBad[i])
+	}
+
+	err := c.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		return Status{}, fmt.Errorf("querying results: %w", err)
+	}
+
+	budget := 1 - current.TargetPercent/100 //nolint:mnd // It's a percentage.
+
+	status := Status{
+		Name:                         current.Name,
+		Scope:                        current.Scope,
+		URL:                          current.url,
+		Group:                        current.group,
+		Owner:                        current.owner,
+		TargetPercent:                current.TargetPercent,
+		LatencyThresholdMilliseconds: current.LatencyThresholdMilliseconds,
+		WindowDays:                   current.WindowDays,
+		Checks:                       checks,
+		BadChecks:                    bad,
+		AttainedPercent:              100 * (1 - ratio(bad, checks)), //nolint:mnd // It's a percentage.
+		ErrorBudgetRemaining:         1 - ratio(bad, checks)/budget,
+		BurnRates:                    make(map[string]float64, len(burnWindows)),
+		UpdatedAt:                    now,
+	}
+
+	for i, window := range burnWindows {
+		status.BurnRates[windowName(window)] = ratio(windowBad[i], windowChecks[i]) / budget
+	}
+
+	for _, candidate := range burnAlerts {
+		if status.BurnRates[windowName(candidate.long)] > candidate.threshold && status.BurnRates[windowName(candidate.short)] > candidate.threshold {
+			status.Alert = candidate.alert
+
+			break
+		}
+	}
+
+	return status, nil
+}
+
+// ratio returns the ratio of bad checks, which is zero if there were no checks.
+func ratio(bad, checks int64) float64 {
+	if checks == 0 {
+		return 0
+	}
+
+	return float64(bad) / float64(checks)
+}
+
+// windowName returns how a burn window is shown, eg. 5m or 1h.
+func windowName(window time.Duration) string {
+	if window%time.Hour == 0 {
+		return fmt.Sprintf("%dh", window/time.Hour)
+	}
+
+	return fmt.Sprintf("%dm", window/time.Minute)
+}
+
+// Run updates the SLOs right away and then every interval, until the context is done. Errors are logged, and the next update tries again.
+func (c *Calculator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.Update(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed updating SLOs.", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns the status of every SLO that was computed, sorted by scope, site or group, and name.
+func (c *Calculator) Statuses() []Status {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	statuses := make([]Status, 0, len(c.statuses))
+	for _, key := range slices.Sorted(maps.Keys(c.statuses)) {
+		statuses = append(statuses, c.statuses[key])
+	}
+
+	return statuses
+}
+
+// ServeHTTP lists the status of every SLO, eg. for GET /api/slos.
+func (c *Calculator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(c.Statuses())
+	if err != nil { // the client is most likely gone, there is nothing else we can do.
+		slog.DebugContext(r.Context(), "Failed writing response.", slog.String("error", err.Error()))
+	}
+}
+
+// Families returns the metrics of the SLOs, so the calculator is a [metrics.Collector].
+func (c *Calculator) Families() []*metrics.Family {
+	target := metrics.NewFamily("gomonitor_slo_target_ratio", "Target of the SLO.", "gauge")
+	attained := metrics.NewFamily("gomonitor_slo_attained_ratio", "Good checks within the window of the SLO.", "gauge")
+	remaining := metrics.NewFamily("gomonitor_slo_error_budget_remaining_ratio", "Error budget of the SLO that is left. Below 0, the SLO was missed.", "gauge")
+	burnRate := metrics.NewFamily("gomonitor_slo_burn_rate", "How fast the error budget of the SLO is burning, by window. At 1, it lasts exactly the window of the SLO.", "gauge")
+	alerting := metrics.NewFamily("gomonitor_slo_alert", "Whether the error budget of the SLO is burning too fast, by alert.", "gauge")
+
+	for _, status := range c.Statuses() {
+		pairs := []string{"slo", status.Name, "url", status.URL, "group", status.Group}
+
+		target.Add(metrics.Labels(pairs...), status.TargetPercent/100)     //nolint:mnd // It's a percentage.
+		attained.Add(metrics.Labels(pairs...), status.AttainedPercent/100) //nolint:mnd // It's a percentage.
+		remaining.Add(metrics.Labels(pairs...), status.ErrorBudgetRemaining)
+
+		for _, window := range burnWindows {
+			burnRate.Add(metrics.Labels(append(pairs, "window", windowName(window))...), status.BurnRates[windowName(window)])
+		}
+
+		for _, candidate := range burnAlerts {
+			value := 0.0
+			if status.Alert == candidate.alert {
+				value = 1
+			}
+
+			alerting.Add(metrics.Labels(append(pairs, "alert", string(candidate.alert))...), value)
+		}
+	}
+
+	return []*metrics.Family{target, attained, remaining, burnRate, alerting}
+}
@@ -0,0 +1,284 @@
+package slo_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/consumers/metrics"
+	"github.com/pbabbicola/go-monitor/slo"
+)
+
+var (
+	siteQuery  = regexp.QuoteMeta("where url = $1")
+	groupQuery = regexp.QuoteMeta("where site_group = $1")
+)
+
+// counts returns the row of the counts query: checks and bad checks within the window of the SLO, and then within 5m, 30m, 1h and 6h.
+func counts(values ...int64) *sqlmock.Rows {
+	row := make([]driver.Value, 0, len(values))
+	for _, value := range values {
+		row = append(row, value)
+	}
+
+	return sqlmock.NewRows([]string{"checks", "bad", "checks_5m", "bad_5m", "checks_30m", "bad_30m", "checks_1h", "bad_1h", "checks_6h", "bad_6h"}).AddRow(row...)
+}
+
+// rounded rounds the floats of the statuses and drops the update time, so they can be compared.
+func rounded(statuses []slo.Status) []slo.Status {
+	round := func(value float64) float64 {
+		return math.Round(value*1e6) / 1e6
+	}
+
+	for i := range statuses {
+		statuses[i].UpdatedAt = time.Time{}
+		statuses[i].AttainedPercent = round(statuses[i].AttainedPercent)
+		statuses[i].ErrorBudgetRemaining = round(statuses[i].ErrorBudgetRemaining)
+
+		for window, rate := range statuses[i].BurnRates {
+			statuses[i].BurnRates[window] = round(rate)
+		}
+	}
+
+	return statuses
+}
+
+func TestCalculator_Update(t *testing.T) {
+	tests := []struct {
+		name           string
+		sites          []config.SiteElement
+		dbExpectations func(mock sqlmock.Sqlmock)
+		want           []slo.Status
+		wantAlerts     []slo.Alert
+		wantErr        bool
+	}{
+		{
+			name: "site slo",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", Owner: "web-team", SLOs: []config.SLO{{TargetPercent: 87.5}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(siteQuery).
+					WithArgs("https://example.org", 0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(counts(800, 50, 8, 8, 48, 24, 96, 24, 576, 36))
+			},
+			want: []slo.Status{{
+				Name:                 "availability",
+				Scope:                config.SLOScopeSite,
+				URL:                  "https://example.org",
+				Owner:                "web-team",
+				TargetPercent:        87.5,
+				WindowDays:           config.DefaultSLOWindowDays,
+				Checks:               800,
+				BadChecks:            50,
+				AttainedPercent:      93.75,
+				ErrorBudgetRemaining: 0.5,
+				BurnRates:            map[string]float64{"5m": 8, "30m": 4, "1h": 2, "6h": 0.5},
+			}},
+		},
+		{
+			name: "fast burn",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", SLOs: []config.SLO{{Name: "contract", TargetPercent: 99.5}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(siteQuery).WillReturnRows(counts(1000, 20, 10, 5, 60, 5, 120, 10, 720, 20))
+			},
+			want: []slo.Status{{
+				Name:                 "contract",
+				Scope:                config.SLOScopeSite,
+				URL:                  "https://example.org",
+				TargetPercent:        99.5,
+				WindowDays:           config.DefaultSLOWindowDays,
+				Checks:               1000,
+				BadChecks:            20,
+				AttainedPercent:      98,
+				ErrorBudgetRemaining: -3,
+				BurnRates:            map[string]float64{"5m": 100, "30m": 16.666667, "1h": 16.666667, "6h": 5.555556},
+				Alert:                slo.AlertFastBurn,
+			}},
+			wantAlerts: []slo.Alert{slo.AlertFastBurn},
+		},
+		{
+			name: "slow burn",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", SLOs: []config.SLO{{TargetPercent: 99.5}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(siteQuery).WillReturnRows(counts(1000, 20, 10, 0, 60, 3, 120, 3, 720, 36))
+			},
+			want: []slo.Status{{
+				Name:                 "availability",
+				Scope:                config.SLOScopeSite,
+				URL:                  "https://example.org",
+				TargetPercent:        99.5,
+				WindowDays:           config.DefaultSLOWindowDays,
+				Checks:               1000,
+				BadChecks:            20,
+				AttainedPercent:      98,
+				ErrorBudgetRemaining: -3,
+				BurnRates:            map[string]float64{"5m": 0, "30m": 10, "1h": 5, "6h": 10},
+				Alert:                slo.AlertSlowBurn,
+			}},
+			wantAlerts: []slo.Alert{slo.AlertSlowBurn},
+		},
+		{
+			name: "group slo is only computed once",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", Group: "website", Owner: "web-team", SLOs: []config.SLO{{TargetPercent: 87.5, Scope: config.SLOScopeGroup}}},
+				{URL: "https://example.org/blog", Group: "website", SLOs: []config.SLO{{TargetPercent: 90, Scope: config.SLOScopeGroup}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(groupQuery).
+					WithArgs("website", 0, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(counts(0, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+			},
+			want: []slo.Status{{
+				Name:                 "availability",
+				Scope:                config.SLOScopeGroup,
+				Group:                "website",
+				Owner:                "web-team",
+				TargetPercent:        87.5,
+				WindowDays:           config.DefaultSLOWindowDays,
+				AttainedPercent:      100,
+				ErrorBudgetRemaining: 1,
+				BurnRates:            map[string]float64{"5m": 0, "30m": 0, "1h": 0, "6h": 0},
+			}},
+		},
+		{
+			name: "latency slo",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", SLOs: []config.SLO{{TargetPercent: 87.5, LatencyThresholdMilliseconds: 500, WindowDays: 7}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(siteQuery).
+					WithArgs("https://example.org", 500, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(counts(8, 1, 0, 0, 0, 0, 0, 0, 8, 1))
+			},
+			want: []slo.Status{{
+				Name:                         "latency",
+				Scope:                        config.SLOScopeSite,
+				URL:                          "https://example.org",
+				TargetPercent:                87.5,
+				LatencyThresholdMilliseconds: 500,
+				WindowDays:                   7,
+				Checks:                       8,
+				BadChecks:                    1,
+				AttainedPercent:              87.5,
+				ErrorBudgetRemaining:         0,
+				BurnRates:                    map[string]float64{"5m": 0, "30m": 0, "1h": 0, "6h": 1},
+			}},
+		},
+		{
+			name: "failed query",
+			sites: []config.SiteElement{
+				{URL: "https://example.org", SLOs: []config.SLO{{TargetPercent: 99.9}}},
+			},
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(siteQuery).WillReturnError(assert.AnError)
+			},
+			want:    []slo.Status{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			var alerts []slo.Alert
+
+			calculator := slo.New(db, func(_ context.Context, status slo.Status) {
+				alerts = append(alerts, status.Alert)
+			})
+			calculator.SetSites(tt.sites)
+
+			err = calculator.Update(context.Background())
+
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.Equal(t, tt.want, rounded(calculator.Statuses()))
+			assert.Equal(t, tt.wantAlerts, alerts)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCalculator_Update_Alerts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(siteQuery).WillReturnRows(counts(1000, 20, 10, 5, 60, 5, 120, 10, 720, 20))
+	mock.ExpectQuery(siteQuery).WillReturnRows(counts(1000, 20, 10, 5, 60, 5, 120, 10, 720, 20))
+	mock.ExpectQuery(siteQuery).WillReturnError(assert.AnError)
+	mock.ExpectQuery(siteQuery).WillReturnRows(counts(1000, 20, 10, 0, 60, 0, 120, 5, 720, 20))
+
+	var alerts []slo.Alert
+
+	calculator := slo.New(db, func(_ context.Context, status slo.Status) {
+		alerts = append(alerts, status.Alert)
+	})
+	calculator.SetSites([]config.SiteElement{{URL: "https://example.org", SLOs: []config.SLO{{TargetPercent: 99.5}}}})
+
+	require.NoError(t, calculator.Update(context.Background()))
+	require.NoError(t, calculator.Update(context.Background()))
+	require.Error(t, calculator.Update(context.Background()))
+
+	assert.Equal(t, slo.AlertFastBurn, calculator.Statuses()[0].Alert, "the previous status is kept if the query fails")
+
+	require.NoError(t, calculator.Update(context.Background()))
+
+	assert.Equal(t, []slo.Alert{slo.AlertFastBurn, slo.AlertNone}, alerts, "only changes are notified")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCalculator_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	mock.ExpectQuery(siteQuery).WillReturnRows(counts(800, 50, 8, 8, 48, 24, 96, 24, 576, 36))
+
+	calculator := slo.New(db, nil)
+	calculator.SetSites([]config.SiteElement{{URL: "https://example.org", SLOs: []config.SLO{{TargetPercent: 87.5}}}})
+
+	require.NoError(t, calculator.Update(context.Background()))
+
+	recorder := httptest.NewRecorder()
+	calculator.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/slos", http.NoBody))
+
+	var statuses []slo.Status
+
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "https://example.org", statuses[0].URL)
+	assert.InDelta(t, 0.5, statuses[0].ErrorBudgetRemaining, 1e-9)
+
+	m := metrics.New()
+	m.Register(calculator)
+
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	assert.Contains(t, recorder.Body.String(), `gomonitor_slo_target_ratio{slo="availability",url="https://example.org",group=""} 0.875`)
+	assert.Contains(t, recorder.Body.String(), `gomonitor_slo_error_budget_remaining_ratio{slo="availability",url="https://example.org",group=""} 0.5`)
+	assert.Contains(t, recorder.Body.String(), `gomonitor_slo_burn_rate{slo="availability",url="https://example.org",group="",window="1h"} 2`)
+	assert.Contains(t, recorder.Body.String(), `gomonitor_slo_alert{slo="availability",url="https://example.org",group="",alert="fast_burn"} 0`)
+}
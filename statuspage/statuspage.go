@@ -0,0 +1,464 @@
+// Package statuspage serves a public status page of the sites that opt in, from their results in Postgres. It's rendered on the server and it needs nothing else, no scripts nor external styles, so it can be served from anywhere.
+package statuspage
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/database"
+)
+
+const (
+	uptimeDays     = 90 // days of the uptime bars
+	incidentDays   = 14 // days of the recent incidents
+	sparklineHours = 24 // hours of the response time sparkline
+)
+
+// bad is whether a check failed. Rows from before there were error classes only have the error.
+const bad = `(coalesce(error_class, '') <> '' or coalesce(error, '') <> '')`
+
+// latestQuery has the last check of every site ($1) since a time ($2). Sites that weren't checked since then have an unknown status.
+const latestQuery = `select distinct on (url) url, ` + bad + `, in_maintenance
+from logs
+where url = any($1) and ts >= $2
+order by url, ts desc`
+
+// daysQuery counts the checks and the failed ones of every site ($1) by day since a time ($2). Checks in maintenance windows are left out, like they are for the uptime everywhere else.
+const daysQuery = `select url, date_trunc('day', ts at time zone 'UTC'), count(*), count(*) filter (where ` + bad + `)
+from logs
+where url = any($1) and ts >= $2 and not in_maintenance
+group by 1, 2`
+
+// sparklineQuery averages the duration of the checks of every site ($1) that succeeded by hour since a time ($2).
+const sparklineQuery = `select url, date_trunc('hour', ts at time zone 'UTC'), avg(duration_milliseconds)
+from logs
+where url = any($1) and ts >= $2 and not ` + bad + `
+group by 1, 2`
+
+// incidentsQuery has the incidents of every site ($1) that were still open since a time ($2), newest first. They are the ones that on-call works with, so failures that were
+// suppressed by an upstream site or in maintenance windows are not there, and a flapping site has a single incident.
+const incidentsQuery = `select url, opened_at, closed_at, last_failure_at
+from incidents
+where url = any($1) and (closed_at is null or closed_at >= $2)
+order by opened_at desc, id desc`
+
+// Status is how a site or a group is doing.
+type Status string
+
+const (
+	StatusUp          Status = "up"
+	StatusDown        Status = "down"
+	StatusMaintenance Status = "maintenance"
+	StatusUnknown     Status = "unknown" // The site wasn't checked lately.
+)
+
+// worst is the order of the statuses for a group, which has the first one that any of its sites has.
+var worst = []Status{StatusDown, StatusMaintenance, StatusUp, StatusUnknown}
+
+// site is a public site of the site list.
+type site struct {
+	url   string
+	name  string
+	group string
+}
+
+// Page keeps the public sites and what the status page shows of them. It's refreshed every now and then with [Page.Run], so requests never reach the database.
+type Page struct {
+	db      *sql.DB
+	title   string
+	refresh time.Duration
+
+	mut   *sync.Mutex
+	sites []site
+	view  view
+}
+
+// view is everything the status page shows.
+type view struct {
+	Title          string
+	RefreshSeconds int // how often browsers load the page again, which is how often it's updated
+	UpdatedAt      time.Time
+	Groups         []groupView
+	Incidents      []incidentView
+}
+
+type groupView struct {
+	Name   string
+	Status Status
+	Sites  []siteView
+}
+
+type siteView struct {
+	Name          string
+	Status        Status
+	UptimePercent float64 // of all the days of the bars, or -1 if there were no checks
+	Days          []dayView
+	Sparkline     string  // points of an SVG polyline
+	Latency       float64 // average duration of the last hour with checks, in milliseconds
+}
+
+type dayView struct {
+	Date          time.Time
+	Checks        int64
+	UptimePercent float64
+}
+
+type incidentView struct {
+	Name     string
+	Start    time.Time
+	End      time.Time // zero if it's still failing
+	Duration time.Duration
+}
+
+// New creates a new Page, without any site until [Page.SetSites] is called. [Page.Run] updates it every refresh, and browsers load it again just as often.
+func New(db *sql.DB, title string, refresh time.Duration) *Page {
+	refresh = max(refresh, time.Second)
+
+	return &Page{
+		db:      db,
+		title:   title,
+		refresh: refresh,
+		mut:     &sync.Mutex{},
+		view:    view{Title: title, RefreshSeconds: int(refresh.Seconds())},
+	}
+}
+
+// SetSites sets the public sites of the site list, eg. after it's reloaded. The rest are ignored.
+func (p *Page) SetSites(sites []config.SiteElement) {
+	public := []site{}
+
+	for _, element := range sites {
+		if !element.Public {
+			continue
+		}
+
+		name := element.PublicName
+		if name == "" {
+			name = element.String()
+		}
+
+		public = append(public, site{url: element.String(), name: name, group: element.Group})
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.sites = public
+}
+
+// Update queries the results of the public sites again.
+func (p *Page) Update(ctx context.Context) error {
+	p.mut.Lock()
+	sites := slices.Clone(p.sites)
+	p.mut.Unlock()
+
+	now := time.Now().UTC()
+
+	if len(sites) == 0 { // nothing to query
+		p.mut.Lock()
+		defer p.mut.Unlock()
+
+		p.view = view{Title: p.title, RefreshSeconds: int(p.refresh.Seconds()), UpdatedAt: now}
+
+		return nil
+	}
+
+	today := now.Truncate(24 * time.Hour) //nolint:mnd // A day.
+
+	urls := make([]string, 0, len(sites))
+	names := make(map[string]string, len(sites))
+
+	for _, public := range sites {
+		urls = append(urls, public.url)
+		names[public.url] = public.name
+	}
+
+	statuses := map[string]Status{}
+
+	err := database.Query(ctx, p.db, latestQuery, []any{pq.Array(urls), now.Add(-24 * time.Hour)}, func(rows *sql.Rows) error { //nolint:mnd // A day.
+		var (
+			url                 string
+			failed, maintenance bool
+		)
+
+		err := rows.Scan(&url, &failed, &maintenance)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		switch {
+		case maintenance:
+			statuses[url] = StatusMaintenance
+		case failed:
+			statuses[url] = StatusDown
+		default:
+			statuses[url] = StatusUp
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("querying latest checks: %w", err)
+	}
+
+	type key struct {
+		url  string
+		time time.Time
+	}
+
+	days := map[key]dayView{}
+
+	err = database.Query(ctx, p.db, daysQuery, []any{pq.Array(urls), today.AddDate(0, 0, 1-uptimeDays)}, func(rows *sql.Rows) error {
+		var (
+			url    string
+			day    dayView
+			failed int64
+		)
+
+		err := rows.Scan(&url, &day.Date, &day.Checks, &failed)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		if day.Checks > 0 {
+			day.UptimePercent = 100 * float64(day.Checks-failed) / float64(day.Checks) //nolint:mnd // It's a percentage.
+		}
+
+		day.Date = day.Date.UTC()
+		days[key{url: url, time: day.Date}] = day
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("querying uptime: %w", err)
+	}
+
+	latencies := map[key]float64{}
+	thisHour := now.Truncate(time.Hour)
+
+	err = database.Query(ctx, p.db, sparklineQuery, []any{pq.Array(urls), thisHour.Add((1 - sparklineHours) * time.Hour)}, func(rows *sql.Rows) error {
+		var (
+			url     string
+			hour    time.Time
+			latency float64
+		)
+
+		err := rows.Scan(&url, &hour, &latency)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		latencies[key{url: url, time: hour.UTC()}] = latency
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("querying response times: %w", err)
+	}
+
+	var incidents []incidentView
+
+	err = database.Query(ctx, p.db, incidentsQuery, []any{pq.Array(urls), today.AddDate(0, 0, 1-incidentDays)}, func(rows *sql.Rows) error {
+		var (
+			url                     string
+			incident                incidentView
+			closedAt, lastFailureAt sql.NullTime
+		)
+
+		err := rows.Scan(&url, &incident.Start, &closedAt, &lastFailureAt)
+		if err != nil {
+			return err //nolint:wrapcheck // It's wrapped by database.Query.
+		}
+
+		incident.Name = names[url]
+		incident.End = closedAt.Time
+		incident.Duration = max(lastFailureAt.Time.Sub(incident.Start), 0) // it's still failing, as far as we know
+		if closedAt.Valid {
+			incident.Duration = incident.End.Sub(incident.Start)
+		}
+
+		incidents = append(incidents, incident)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("querying incidents: %w", err)
+	}
+
+	updated := view{Title: p.title, RefreshSeconds: int(p.refresh.Seconds()), UpdatedAt: now, Incidents: incidents}
+
+	for _, public := range sites {
+		siteView := siteView{Name: public.name, Status: StatusUnknown, UptimePercent: -1}
+		if status, ok := statuses[public.url]; ok {
+			siteView.Status = status
+		}
+
+		var checks, good float64
+
+		for i := range uptimeDays {
+			date := today.AddDate(0, 0, i+1-uptimeDays)
+
+			day, ok := days[key{url: public.url, time: date}]
+			if !ok {
+				day = dayView{Date: date}
+			}
+
+			checks += float64(day.Checks)
+			good += float64(day.Checks) * day.UptimePercent / 100 //nolint:mnd // It's a percentage.
+			siteView.Days = append(siteView.Days, day)
+		}
+
+		if checks > 0 {
+			siteView.UptimePercent = 100 * good / checks //nolint:mnd // It's a percentage.
+		}
+
+		hourly := make([]float64, sparklineHours)
+		for i := range hourly {
+			hour := thisHour.Add(time.Duration(i+1-sparklineHours) * time.Hour)
+
+			latency, ok := latencies[key{url: public.url, time: hour}]
+			if !ok {
+				latency = -1
+			}
+
+			hourly[i] = latency
+			if ok {
+				siteView.Latency = latency
+			}
+		}
+
+		siteView.Sparkline = sparkline(hourly)
+		updated.Groups = addToGroup(updated.Groups, public.group, siteView)
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.view = updated
+
+	return nil
+}
+
+// addToGroup adds a site to its group, which is added if it's not there yet, and updates the status of the group.
+func addToGroup(groups []groupView, name string, site siteView) []groupView {
+	index := slices.IndexFunc(groups, func(group groupView) bool { return group.Name == name })
+	if index < 0 {
+		index = len(groups)
+		groups = append(groups, groupView{Name: name, Status: StatusUnknown})
+	}
+
+	groups[index].Sites = append(groups[index].Sites, site)
+
+	if slices.Index(worst, site.Status) < slices.Index(worst, groups[index].Status) {
+		groups[index].Status = site.Status
+	}
+
+	return groups
+}
+
+const (
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+// sparkline returns the points of an SVG polyline of the values, scaled to fit the sparkline. Negative values are hours without checks, which are left out.
+func sparkline(values []float64) string {
+	highest := slices.Max(values)
+	if highest <= 0 {
+		return ""
+	}
+
+	step := float64(sparklineWidth) / float64(max(len(values)-1, 1))
+	points := make([]string, 0, len(values))
+
+	for i, value := range values {
+		if value < 0 {
+			continue
+		}
+
+		x := float64(i) * step
+		y := sparklineHeight - value/highest*sparklineHeight
+
+		points = append(points, strconv.FormatFloat(x, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
+	}
+
+	return strings.Join(points, " ")
+}
+
+// Run updates the page right away and then every refresh, until the context is done. Errors are logged, and the page keeps what it had.
+func (p *Page) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.refresh)
+	defer ticker.Stop()
+
+	for {
+		err := p.Update(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed updating the status page.", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//go:embed statuspage.html
+var pageTemplate string
+
+var page = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%" //nolint:mnd // Two decimals are enough for a status page.
+	},
+	"date": func(value time.Time) string {
+		return value.Format(time.DateOnly)
+	},
+	"time": func(value time.Time) string {
+		return value.UTC().Format("2006-01-02 15:04 UTC")
+	},
+	"level": func(day dayView) string {
+		switch {
+		case day.Checks == 0:
+			return "none"
+		case day.UptimePercent >= 99.9: //nolint:mnd // Three nines is fine.
+			return "up"
+		case day.UptimePercent >= 99: //nolint:mnd // Two nines is not that bad.
+			return "degraded"
+		default:
+			return "down"
+		}
+	},
+	"milliseconds": func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 0, 64) + " ms"
+	},
+	"width":  func() int { return sparklineWidth },
+	"height": func() int { return sparklineHeight },
+}).Parse(pageTemplate))
+
+// ServeHTTP renders the status page, eg. for GET /.
+func (p *Page) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mut.Lock()
+	current := p.view
+	p.mut.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	err := page.Execute(w, current)
+	if err != nil { // the client is most likely gone, there is nothing else we can do.
+		slog.DebugContext(r.Context(), "Failed writing the status page.", slog.String("error", err.Error()))
+	}
+}
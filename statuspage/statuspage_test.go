@@ -0,0 +1,133 @@
+package statuspage_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pbabbicola/go-monitor/config"
+	"github.com/pbabbicola/go-monitor/statuspage"
+)
+
+// urls matches the array of URLs of the queries, so private sites are never queried.
+type urls string
+
+func (u urls) Match(value driver.Value) bool {
+	return value == string(u)
+}
+
+func TestPage(t *testing.T) {
+	sites := []config.SiteElement{
+		{URL: "https://shop.example.org/health", Group: "shop", Public: true, PublicName: "Shop"},
+		{URL: "https://api.example.org", Group: "shop", Public: true},
+		{URL: "https://internal.example.org/admin"},
+	}
+	public := urls(`{"https://shop.example.org/health","https://api.example.org"}`)
+
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+
+	tests := []struct {
+		name           string
+		sites          []config.SiteElement
+		dbExpectations func(mock sqlmock.Sqlmock)
+		want           []string
+		notWant        []string
+		wantErr        bool
+	}{
+		{
+			name:  "public sites",
+			sites: sites,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("select distinct on (url)")).WithArgs(public, sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows([]string{"url", "bad", "in_maintenance"}).
+						AddRow("https://shop.example.org/health", true, false).
+						AddRow("https://api.example.org", false, false),
+				)
+				mock.ExpectQuery(regexp.QuoteMeta("date_trunc('day'")).WithArgs(public, today.AddDate(0, 0, -89)).WillReturnRows(
+					sqlmock.NewRows([]string{"url", "day", "checks", "failures"}).
+						AddRow("https://shop.example.org/health", today, 100, 2).
+						AddRow("https://api.example.org", today.AddDate(0, 0, -1), 100, 0),
+				)
+				mock.ExpectQuery(regexp.QuoteMeta("date_trunc('hour'")).WithArgs(public, sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows([]string{"url", "hour", "avg"}).
+						AddRow("https://shop.example.org/health", now.Truncate(time.Hour).Add(-time.Hour), 100.0).
+						AddRow("https://shop.example.org/health", now.Truncate(time.Hour), 200.0),
+				)
+				mock.ExpectQuery(regexp.QuoteMeta("from incidents")).WithArgs(public, today.AddDate(0, 0, -13)).WillReturnRows(
+					sqlmock.NewRows([]string{"url", "opened_at", "closed_at", "last_failure_at"}).
+						AddRow("https://shop.example.org/health", now.Add(-time.Hour), nil, now).
+						AddRow("https://api.example.org", now.Add(-48*time.Hour), now.Add(-46*time.Hour), now.Add(-47*time.Hour)),
+				)
+			},
+			want: []string{
+				`<h2 class="row"><span>shop</span><span class="down">down</span></h2>`,
+				"<strong>Shop</strong>",
+				"<strong>https://api.example.org</strong>",
+				`title="` + today.Format(time.DateOnly) + `: 98.00%"`,
+				"98.00% uptime",
+				"100.00% uptime",
+				`<polyline points="114.8,12.0 120.0,0.0"`,
+				"200 ms",
+				`<span class="down">ongoing</span>`,
+				"resolved after 2h0m0s",
+			},
+			notWant: []string{"internal.example.org"},
+		},
+		{
+			name:           "no public sites",
+			sites:          sites[2:],
+			dbExpectations: func(sqlmock.Sqlmock) {},
+			want:           []string{"No public sites.", "No incidents in the last 14 days."},
+		},
+		{
+			name:  "failed query",
+			sites: sites,
+			dbExpectations: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("select distinct on (url)")).WillReturnError(assert.AnError)
+			},
+			want:    []string{"Nothing to show yet."},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+
+			defer db.Close()
+
+			tt.dbExpectations(mock)
+
+			page := statuspage.New(db, "Example status", 30*time.Second)
+			page.SetSites(tt.sites)
+
+			err = page.Update(context.Background())
+			assert.Truef(t, err != nil == tt.wantErr, "wanted err to be %v, but got error %v", tt.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			recorder := httptest.NewRecorder()
+			page.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+			assert.Contains(t, recorder.Body.String(), "<title>Example status</title>")
+			assert.Contains(t, recorder.Body.String(), `<meta http-equiv="refresh" content="30">`, "browsers load it again when it's updated")
+
+			for _, part := range tt.want {
+				assert.Contains(t, recorder.Body.String(), part)
+			}
+
+			for _, part := range tt.notWant {
+				assert.NotContains(t, recorder.Body.String(), part)
+			}
+		})
+	}
+}